@@ -1,34 +1,47 @@
 package routeros
 
-import "github.com/swoga/go-routeros/proto"
+import (
+	"time"
+
+	"github.com/swoga/go-routeros/proto"
+)
 
 type sentenceProcessor interface {
 	processSentence(sen *proto.Sentence) (bool, error)
+	base() *chanReply
 }
 
 type replyCloser interface {
 	close(err error)
 }
 
-// Async starts asynchronous mode and returns immediately.
+// Async starts asynchronous mode and returns immediately. The returned
+// channel receives the error that ended the read loop, if any, and is
+// closed afterwards. The same error is reported by Err and Wait, so the
+// channel does not need to be drained.
 func (c *Client) Async() <-chan error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
 	errC := make(chan error, 1)
 	if c.async {
+		c.mu.Unlock()
 		errC <- errAlreadyAsync
 		close(errC)
 		return errC
 	}
 	c.async = true
 	c.tags = make(map[string]sentenceProcessor)
+	c.trailing = make(map[string]struct{})
+	c.loopDone = make(chan struct{})
+	c.mu.Unlock()
+
+	c.setState(StateAsync)
 	go c.asyncLoopChan(errC)
 	return errC
 }
 
 func (c *Client) asyncLoopChan(errC chan<- error) {
 	defer close(errC)
+	defer close(c.loopDone)
 	// If c.Close() has been called, c.closing will be true, and
 	// err will be “use of closed network connection”. Ignore that error.
 	err := c.asyncLoop()
@@ -37,6 +50,7 @@ func (c *Client) asyncLoopChan(errC chan<- error) {
 		closing := c.closing
 		c.mu.Unlock()
 		if !closing {
+			c.end(err)
 			errC <- err
 		}
 	}
@@ -44,25 +58,47 @@ func (c *Client) asyncLoopChan(errC chan<- error) {
 
 func (c *Client) asyncLoop() error {
 	for {
+		c.delivering.Store(false)
 		sen, err := c.r.ReadSentence(false)
 		if err != nil {
 			c.closeTags(err)
 			return err
 		}
+		c.lastRead.Store(time.Now().UnixNano())
+		c.delivering.Store(true)
 
 		c.mu.Lock()
 		r, ok := c.tags[sen.Tag]
-		c.mu.Unlock()
 		if !ok {
+			_, trailing := c.trailing[sen.Tag]
+			if trailing && sen.Word == "!done" {
+				delete(c.trailing, sen.Tag)
+				c.mu.Unlock()
+				continue
+			}
+			funcs := c.unknownTagFuncs
+			c.mu.Unlock()
+			for _, f := range funcs {
+				f(sen)
+			}
 			continue
 		}
+		c.mu.Unlock()
+		r.base().sentences.Add(1)
 
 		done, err := r.processSentence(sen)
 		if done || err != nil {
 			c.mu.Lock()
 			delete(c.tags, sen.Tag)
+			if sen.Word == "!trap" {
+				// the device still sends !done for the tag
+				c.trailing[sen.Tag] = struct{}{}
+			}
 			c.mu.Unlock()
 			closeReply(r, err)
+			if _, ok := r.(*ListenReply); ok {
+				c.finish()
+			}
 		}
 	}
 }
@@ -71,10 +107,21 @@ func (c *Client) closeTags(err error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	for _, r := range c.tags {
-		closeReply(r, err)
-	}
+	tags := c.tags
 	c.tags = nil
+	// Listens are closed first, so a failed /cancel can tell that its listen
+	// has ended.
+	for _, r := range tags {
+		if _, ok := r.(*ListenReply); ok {
+			closeReply(r, err)
+			c.finishLocked()
+		}
+	}
+	for _, r := range tags {
+		if _, ok := r.(*ListenReply); !ok {
+			closeReply(r, err)
+		}
+	}
 }
 
 func closeReply(r sentenceProcessor, err error) {
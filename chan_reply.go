@@ -1,12 +1,26 @@
 package routeros
 
-import "github.com/swoga/go-routeros/proto"
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/swoga/go-routeros/proto"
+)
 
 // chanReply is shared between ListenReply and AsyncReply.
 type chanReply struct {
 	tag string
 	err error
 	reC chan *proto.Sentence
+
+	// command, started and sentences are reported by Client.InFlight.
+	command   string
+	started   time.Time
+	sentences atomic.Int64
+}
+
+func (a *chanReply) base() *chanReply {
+	return a
 }
 
 // Err returns the first error that happened processing sentences with tag.
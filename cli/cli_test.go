@@ -0,0 +1,292 @@
+package cli_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/swoga/go-routeros/cli"
+)
+
+var parseTests = []struct {
+	line  string
+	words []string
+}{
+	// paths
+	{"/system resource print", []string{"/system/resource/print"}},
+	{"/system/resource/print", []string{"/system/resource/print"}},
+	{"/ip/firewall filter print", []string{"/ip/firewall/filter/print"}},
+	{"/ip address .. route print", []string{"/ip/route/print"}},
+	{"/system reboot", []string{"/system/reboot"}},
+	{"/ping address=1.1.1.1 count=3", []string{"/ping", "=address=1.1.1.1", "=count=3"}},
+
+	// attributes
+	{"/ip firewall filter add chain=input action=drop src-address=1.2.3.4 place-before=0",
+		[]string{"/ip/firewall/filter/add", "=chain=input", "=action=drop", "=src-address=1.2.3.4", "=place-before=0"}},
+	{"/ip address add address=10.0.0.1/24 interface=ether1 comment=\"uplink 1\"",
+		[]string{"/ip/address/add", "=address=10.0.0.1/24", "=interface=ether1", "=comment=uplink 1"}},
+	{`/system identity set name=a"b c"d`, []string{"/system/identity/set", "=name=ab cd"}},
+	{`/interface set ether1 comment=""`, []string{"/interface/set", "=comment=", "=numbers=ether1"}},
+	{"/interface set ether1 mtu=1500 disabled=yes", []string{"/interface/set", "=mtu=1500", "=disabled=yes", "=numbers=ether1"}},
+	{"/interface ethernet set mac-address=02:00:00:00:00:01 numbers=ether1",
+		[]string{"/interface/ethernet/set", "=mac-address=02:00:00:00:00:01", "=numbers=ether1"}},
+	{"/ip address set *1 address=::1/128", []string{"/ip/address/set", "=address=::1/128", "=numbers=*1"}},
+	{"/ip firewall filter add chain=forward comment=(legacy)", []string{"/ip/firewall/filter/add", "=chain=forward", "=comment=(legacy)"}},
+	{"/system script add source=\":log info \\\"hi\\\"\"", []string{"/system/script/add", `=source=:log info "hi"`}},
+	{"/system note set note=\"line 1\\nline 2\\ttab\"", []string{"/system/note/set", "=note=line 1\nline 2\ttab"}},
+	{`/system identity set name="\$name\?"`, []string{"/system/identity/set", "=name=$name?"}},
+	{`/system identity set name=router\_1`, []string{"/system/identity/set", "=name=router 1"}},
+	{`/system identity set name="\41\62"`, []string{"/system/identity/set", "=name=Ab"}},
+	{"/interface remove ether1 ether2", []string{"/interface/remove", "=numbers=ether1,ether2"}},
+	{"/interface get ether1 mtu", []string{"/interface/get", "=numbers=ether1", "=value-name=mtu"}},
+	{"/interface get ether1", []string{"/interface/get", "=numbers=ether1"}},
+	{"/interface disable \"my bridge\"", []string{"/interface/disable", "=numbers=my bridge"}},
+	{"/interface print detail", []string{"/interface/print", "=detail="}},
+	{"/interface print proplist=name,type", []string{"/interface/print", "=.proplist=name,type"}},
+	{"/interface print .proplist=name", []string{"/interface/print", "=.proplist=name"}},
+	{"/interface listen .tag=ifaces", []string{"/interface/listen", ".tag=ifaces"}},
+	{"/ip/address/add =address=10.0.0.1/24 ?x", []string{"/ip/address/add", "=address=10.0.0.1/24", "?x"}},
+	{"\t/ip  address   print\t", []string{"/ip/address/print"}},
+
+	// where clauses
+	{"/ip address print where interface=ether1", []string{"/ip/address/print", "?interface=ether1"}},
+	{"/ip address print where interface=ether1 and disabled=no", []string{"/ip/address/print", "?interface=ether1", "?disabled=no"}},
+	{"/ip address print where interface=ether1 disabled=no", []string{"/ip/address/print", "?interface=ether1", "?disabled=no"}},
+	{"/ip address print where interface=ether1 or interface=ether2",
+		[]string{"/ip/address/print", "?interface=ether1", "?interface=ether2", "?#|"}},
+	{"/ip address print where a=1 or b=2 and c=3",
+		[]string{"/ip/address/print", "?a=1", "?b=2", "?c=3", "?#&", "?#|"}},
+	{"/ip address print where (a=1 or b=2) and c=3",
+		[]string{"/ip/address/print", "?a=1", "?b=2", "?#|", "?c=3"}},
+	{"/ip address print where interface=ether1 and (disabled=no or !dynamic)",
+		[]string{"/ip/address/print", "?interface=ether1", "?disabled=no", "?dynamic=true", "?#!", "?#|"}},
+	{"/ip address print where not disabled", []string{"/ip/address/print", "?disabled=true", "?#!"}},
+	{"/ip address print where !(a=1 and b=2)", []string{"/ip/address/print", "?a=1", "?b=2", "?#&", "?#!"}},
+	{"/ip address print where not not a=1", []string{"/ip/address/print", "?a=1", "?#!", "?#!"}},
+	{"/ip address print where comment!=\"\"", []string{"/ip/address/print", "?comment=", "?#!"}},
+	{"/interface print where mtu>1500", []string{"/interface/print", "?>mtu=1500"}},
+	{"/interface print where mtu<1500", []string{"/interface/print", "?<mtu=1500"}},
+	{"/interface print where mtu<=1500", []string{"/interface/print", "?>mtu=1500", "?#!"}},
+	{"/interface print where mtu>=1500", []string{"/interface/print", "?<mtu=1500", "?#!"}},
+	{"/ip firewall filter print where comment=\"block (old) and new\"",
+		[]string{"/ip/firewall/filter/print", "?comment=block (old) and new"}},
+	{"/ip firewall filter print where (comment=x)", []string{"/ip/firewall/filter/print", "?comment=x"}},
+	{"/ip firewall filter print where ((a=1)or(b=2))", []string{"/ip/firewall/filter/print", "?a=1", "?b=2", "?#|"}},
+	{"/interface print proplist=name where running", []string{"/interface/print", "=.proplist=name", "?running=true"}},
+	{"/ip address print where ?comment and (?-disabled or disabled=no)",
+		[]string{"/ip/address/print", "?comment", "?-disabled", "?disabled=no", "?#|"}},
+	{"/ip address print where !?comment", []string{"/ip/address/print", "?comment", "?#!"}},
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range parseTests {
+		words, err := cli.Parse(tc.line)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.line, err)
+			continue
+		}
+		if !slices.Equal(words, tc.words) {
+			t.Errorf("Parse(%q)=%q; want %q", tc.line, words, tc.words)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		line   string
+		offset int
+	}{
+		{"", 0},
+		{"/ip address", 11},
+		{"/ip address=1", 4},
+		{`/ip "address" print`, 4},
+		{"/ip address print where", 23},
+		{"/ip address print where and", 24},
+		{"/ip address print where a=1 or", 30},
+		{"/ip address print where (a=1", 24},
+		{"/ip address print where a=1)", 27},
+		{"/ip address print where not", 27},
+		{"/ip address print where comment~foo", 24},
+		{"/ip address print where \"quoted\"", 24},
+		{"/ip address print mtu>1", 18},
+		{"/ip address print (a=1)", 18},
+		{"/ip address print !a", 18},
+		{"/ip address add comment=\"open", 24},
+		{"/ip address add comment=a\\", 25},
+		{"/ip address add comment=\\q", 24},
+		{"/ip address add comment=$x", 24},
+		{"/ip address add comment=\"$x\"", 25},
+		{"/ip address remove [find]", 19},
+		{"/ip address print; /quit", 17},
+		{":put 1", 0},
+		{"/ip address print/x", 12},
+		{`/interface set ""`, 15},
+		{`/interface set ether1 ""`, 22},
+	} {
+		_, err := cli.Parse(tc.line)
+		var syntaxErr *cli.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Parse(%q): %v; want SyntaxError", tc.line, err)
+			continue
+		}
+		if syntaxErr.Offset != tc.offset {
+			t.Errorf("Parse(%q): %v; want offset %d", tc.line, err, tc.offset)
+		}
+	}
+}
+
+func TestParser(t *testing.T) {
+	// a parser knowing the menus of the device
+	menus := map[string][]string{
+		"":          {"ip", "interface", "tool"},
+		"ip":        {"address", "route"},
+		"interface": {"bridge"},
+	}
+	p := &cli.Parser{IsCommand: func(menu []string, name string) bool {
+		if slices.Contains(menus[strings.Join(menu, " ")], name) {
+			return false
+		}
+		return name == "fetch" || slices.Contains(cli.Commands, name)
+	}}
+	for _, tc := range []struct {
+		cur   string
+		line  string
+		menu  string
+		words []string
+	}{
+		{"", "", "", nil},
+		{"", "/ip address", "ip address", nil},
+		{"ip address", "", "ip address", nil},
+		{"ip address", "print", "ip address", []string{"/ip/address/print"}},
+		{"ip address", "..", "ip", nil},
+		{"ip address", "../..", "", nil},
+		{"ip address", ".. ..", "", nil},
+		{"ip address", "/", "", nil},
+		{"ip address", ".. route print", "ip route", []string{"/ip/route/print"}},
+		{"ip address", "/interface bridge print", "interface bridge", []string{"/interface/bridge/print"}},
+		{"", "/tool fetch url=http://example.com/", "tool", []string{"/tool/fetch", "=url=http://example.com/"}},
+		{"tool", "fetch", "tool", []string{"/tool/fetch"}},
+		{"", "/ip/address/print", "ip address", []string{"/ip/address/print"}},
+	} {
+		var cur []string
+		if tc.cur != "" {
+			cur = strings.Split(tc.cur, " ")
+		}
+		l, err := p.Parse(cur, tc.line)
+		if err != nil {
+			t.Errorf("Parse(%q, %q): %v", tc.cur, tc.line, err)
+			continue
+		}
+		if strings.Join(l.Menu, " ") != tc.menu || !slices.Equal(l.Sentence, tc.words) {
+			t.Errorf("Parse(%q, %q)=%q %q; want %q %q", tc.cur, tc.line, l.Menu, l.Sentence, tc.menu, tc.words)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for _, tc := range []struct {
+		words []string
+		line  string
+	}{
+		{[]string{"/system/resource/print"}, "/system resource print"},
+		{[]string{"/ping", "=address=1.1.1.1"}, "/ping address=1.1.1.1"},
+		{[]string{"/interface/set", "=.id=*1", "=mtu=1500"}, "/interface set *1 mtu=1500"},
+		{[]string{"/interface/set", "=mtu=1500", "=numbers=ether1,ether2"}, "/interface set ether1,ether2 mtu=1500"},
+		{[]string{"/interface/print", "=.id=*1"}, "/interface print .id=*1"},
+		{[]string{"/interface/print", "=detail=", "=.proplist=name,type"}, "/interface print detail proplist=name,type"},
+		{[]string{"/ip/address/set", "=comment=", "=numbers=*1"}, `/ip address set *1 comment=""`},
+		{[]string{"/system/identity/set", "=name=a \"b\" $c?"}, `/system identity set name="a \"b\" \$c\?"`},
+		{[]string{"/system/note/set", "=note=a\nb\tc\\d\x01"}, `/system note set note="a\nb\tc\\d\01"`},
+		{[]string{"/interface/listen", ".tag=x"}, "/interface listen .tag=x"},
+		{[]string{"/ip/address/print", "?interface=ether1", "?disabled=false"}, "/ip address print where interface=ether1 and disabled=false"},
+		{[]string{"/ip/address/print", "?a=1", "?b=2", "?#|", "?c=3", "?#&"}, "/ip address print where (a=1 or b=2) and c=3"},
+		{[]string{"/ip/address/print", "?a=1", "?b=2", "?c=3", "?#&|"}, "/ip address print where a=1 or b=2 and c=3"},
+		{[]string{"/ip/address/print", "?a=1", "?b=2", "?c=3", "?#||"}, "/ip address print where a=1 or (b=2 or c=3)"},
+		{[]string{"/ip/address/print", "?a=1", "?b=2", "?#|", "?#!"}, "/ip address print where !(a=1 or b=2)"},
+		{[]string{"/ip/address/print", "?a=1", "?#!!"}, "/ip address print where !a!=1"},
+		{[]string{"/interface/print", "?>mtu=1500", "?#!", "?<mtu=9000"}, "/interface print where mtu<=1500 and mtu<9000"},
+		{[]string{"/interface/print", "?<mtu=1500", "?#!"}, "/interface print where mtu>=1500"},
+		{[]string{"/interface/print", "?type=ether", "?#."}, "/interface print where type=ether and type=ether"},
+		{[]string{"/ip/route/print", "?comment=a b"}, `/ip route print where comment="a b"`},
+		{[]string{"/ip/address/print", "?interface", "?-comment"}, "/ip address print where ?interface and ?-comment"},
+		{[]string{"/ip/address/print", "?comment", "?#!"}, "/ip address print where !?comment"},
+		{[]string{"/interface/get", "=numbers=ether1", "=value-name=mtu"}, "/interface get ether1 mtu"},
+		{[]string{"/system/identity/get", "=value-name=name"}, "/system identity get value-name=name"},
+	} {
+		line, err := cli.Format(tc.words)
+		if err != nil {
+			t.Errorf("Format(%q): %v", tc.words, err)
+			continue
+		}
+		if line != tc.line {
+			t.Errorf("Format(%q)=%q; want %q", tc.words, line, tc.line)
+		}
+	}
+
+	for _, words := range [][]string{
+		nil,
+		{"print"},
+		{"/"},
+		{"/ip/address/print", "?-"},
+		{"/ip/address/print", "?<mtu"},
+		{"/ip/address/print", "?#|"},
+		{"/ip/address/print", "?a=1", "?#0"},
+		{"/ip/address/add", "address=1"},
+		{"/ip/address/add", "=address"},
+	} {
+		line, err := cli.Format(words)
+		if err == nil {
+			t.Errorf("Format(%q)=%q; want error", words, line)
+		}
+	}
+}
+
+// TestRoundTrip checks that formatting the parsed corpus yields sentences
+// that parse the same.
+func TestRoundTrip(t *testing.T) {
+	for _, tc := range parseTests {
+		if strings.Contains(tc.line, " =") {
+			continue
+		}
+		line, err := cli.Format(tc.words)
+		if err != nil {
+			t.Errorf("Format(%q): %v", tc.words, err)
+			continue
+		}
+		words, err := cli.Parse(line)
+		if err != nil {
+			t.Errorf("Parse(Format(%q)=%q): %v", tc.words, line, err)
+			continue
+		}
+		if !slices.Equal(words, tc.words) {
+			t.Errorf("Parse(Format(%q)=%q)=%q", tc.words, line, words)
+		}
+	}
+}
+
+func TestQuoteSplit(t *testing.T) {
+	values := []string{"", "a", "a b", `say "hi"`, `C:\dir`, "two\nlines", "tab\there", "$x", "(x)", "a=b", "!x", "\x7f", "ünïcode"}
+	for _, v := range values {
+		words, err := cli.Split("k=" + cli.Quote(v) + " " + cli.Quote(v))
+		if err != nil {
+			t.Errorf("Split(Quote(%q)): %v", v, err)
+			continue
+		}
+		if !slices.Equal(words, []string{"k=" + v, v}) {
+			t.Errorf("Split(Quote(%q))=%q", v, words)
+		}
+		sentence, err := cli.Parse("/system identity set name=" + cli.Quote(v))
+		if err != nil || sentence[1] != "=name="+v {
+			t.Errorf("Parse of Quote(%q)=%q, %v", v, sentence, err)
+		}
+	}
+	words, err := cli.Split(`/ip/address/add "=comment=a b" =interface=ether1`)
+	if err != nil || !slices.Equal(words, []string{"/ip/address/add", "=comment=a b", "=interface=ether1"}) {
+		t.Errorf("Split=%q, %v", words, err)
+	}
+	_, err = cli.Split(`"open`)
+	if err == nil {
+		t.Error("Split of unterminated quote succeeded")
+	}
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Format formats the API sentence as absolute command line. It is the
+// inverse of Parse: the line parses to an equivalent sentence.
+func Format(sentence []string) (string, error) {
+	if len(sentence) == 0 || !strings.HasPrefix(sentence[0], "/") || len(sentence[0]) == 1 {
+		return "", fmt.Errorf("RouterOS CLI: invalid command in %q", sentence)
+	}
+	path := strings.Split(sentence[0][1:], "/")
+	cmd := path[len(path)-1]
+	var b strings.Builder
+	b.WriteString("/" + strings.Join(path[:len(path)-1], " "))
+	if len(path) > 1 {
+		b.WriteString(" ")
+	}
+	b.WriteString(cmd)
+
+	// get takes the property as bare word after the item
+	bareValueName := cmd == "get" && slices.ContainsFunc(sentence[1:], func(w string) bool {
+		return strings.HasPrefix(w, "=numbers=") || strings.HasPrefix(w, "=.id=")
+	})
+	var args, query []string
+	for _, w := range sentence[1:] {
+		switch {
+		case strings.HasPrefix(w, "?"):
+			query = append(query, w)
+		case strings.HasPrefix(w, ".tag="):
+			args = append(args, ".tag="+Quote(w[len(".tag="):]))
+		case strings.HasPrefix(w, "="):
+			name, value, ok := strings.Cut(w[1:], "=")
+			if !ok || name == "" {
+				return "", fmt.Errorf("RouterOS CLI: invalid attribute %q", w)
+			}
+			switch {
+			case (name == "numbers" || name == ".id") && slices.Contains(itemCommands, cmd):
+				// items go first, e.g. "set ether1 mtu=1500"
+				args = slices.Insert(args, 0, Quote(value))
+			case name == "value-name" && bareValueName:
+				args = append(args, Quote(value))
+			case name == ".proplist":
+				args = append(args, "proplist="+Quote(value))
+			case value == "" && slices.Contains(Flags, name):
+				args = append(args, name)
+			default:
+				args = append(args, name+"="+Quote(value))
+			}
+		default:
+			return "", fmt.Errorf("RouterOS CLI: invalid word %q", w)
+		}
+	}
+	for _, a := range args {
+		b.WriteString(" " + a)
+	}
+	if len(query) > 0 {
+		e, err := decompile(query)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(" where ")
+		format(&b, e)
+	}
+	return b.String(), nil
+}
+
+// Quote returns v as a single word of a command line, in double quotes if
+// needed.
+func Quote(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t\r\n\"\\$?()[]{};!=<>~") {
+		return v
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(v); i++ {
+		switch ch := v[i]; ch {
+		case '"', '\\', '$', '?':
+			b.WriteString(`\` + string(ch))
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if ch < ' ' || ch == 0x7f {
+				fmt.Fprintf(&b, `\%02X`, ch)
+			} else {
+				b.WriteByte(ch)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,431 @@
+/*
+Package cli translates between the syntax of the RouterOS console and API
+sentences.
+
+	words, err := cli.Parse(`/ip firewall filter add chain=input action=drop src-address=1.2.3.4 place-before=0`)
+	// [/ip/firewall/filter/add =chain=input =action=drop =src-address=1.2.3.4 =place-before=0]
+
+	words, err = cli.Parse(`/ip address print where interface=ether1 and (disabled=no or !dynamic)`)
+	// [/ip/address/print ?interface=ether1 ?disabled=no ?dynamic=true ?#! ?#|]
+
+	s, err := cli.Format(words)
+	// /ip address print where interface=ether1 and (disabled=no or dynamic!=true)
+
+A line starts with the menu path, which is absolute when it starts with a
+slash and may use ".." to go up. Path elements can be separated by spaces or
+slashes. The first path element that is a command, see Parser.IsCommand,
+ends the path. It is followed by the arguments: name=value pairs, bare words
+naming the items of commands like set or remove, flags like detail and a
+where clause. The second bare word of get names the property to get.
+
+Values may be quoted with double quotes and use the escapes of the console:
+\", \\, \n, \r, \t, \$, \?, \_ for a space and \ followed by two hexadecimal
+digits.
+
+Where clauses combine conditions with and, or, not (or !) and parentheses;
+adjacent conditions are joined with and. A condition compares a property
+with =, !=, <, >, <= or >=; a bare property name is true if the property is
+true. The API query words ?name and ?-name, which test whether an item has
+the property, may be used as conditions as well; Format writes them that
+way, as they have no console syntax. The API cannot match regular
+expressions, so ~ is rejected, as are scripting constructs like [find] and
+$variables.
+*/
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Commands are the names taken as commands by a Parser without IsCommand.
+var Commands = []string{
+	"add", "comment", "disable", "edit", "enable", "export", "find", "get",
+	"getall", "listen", "monitor", "monitor-traffic", "move", "ping", "print",
+	"reboot", "remove", "reset", "reset-counters", "set", "shutdown", "torch",
+	"unset",
+}
+
+// itemCommands take the items they act on as bare words, e.g. "set ether1".
+var itemCommands = []string{
+	"comment", "disable", "edit", "enable", "get", "move", "remove",
+	"reset-counters", "set", "unset",
+}
+
+// Flags are arguments without value that Format writes as bare words.
+var Flags = []string{
+	"brief", "count-only", "detail", "follow", "follow-only", "once",
+	"stats", "terse", "value-list", "without-paging",
+}
+
+// SyntaxError is returned for lines that cannot be translated.
+type SyntaxError struct {
+	// Offset is the byte offset in the line where the error was found.
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("RouterOS CLI: %s at offset %d", e.Msg, e.Offset)
+}
+
+// Line is a parsed command line.
+type Line struct {
+	// Menu is the menu of the command, or the menu the line changes to.
+	Menu []string
+	// Sentence holds the API words of the command. It is nil for lines that
+	// only change the menu, e.g. "/ip address".
+	Sentence []string
+}
+
+// Parser parses command lines.
+type Parser struct {
+	// IsCommand reports whether name is a command of menu. Without it the
+	// names in Commands are commands.
+	IsCommand func(menu []string, name string) bool
+}
+
+// Parse parses an absolute command line with the default Parser and returns
+// its API sentence.
+func Parse(line string) ([]string, error) {
+	l, err := (&Parser{}).Parse(nil, line)
+	if err != nil {
+		return nil, err
+	}
+	if l.Sentence == nil {
+		return nil, &SyntaxError{len(line), "missing command"}
+	}
+	return l.Sentence, nil
+}
+
+// Parse parses line relative to the menu cur. An empty line yields a Line
+// for cur without sentence.
+func (p *Parser) Parse(cur []string, line string) (*Line, error) {
+	tokens, err := lex(line)
+	if err != nil {
+		return nil, err
+	}
+	isCommand := p.IsCommand
+	if isCommand == nil {
+		isCommand = func(menu []string, name string) bool {
+			return slices.Contains(Commands, name)
+		}
+	}
+
+	menu := slices.Clone(cur)
+	cmd := ""
+	i := 0
+	for ; i < len(tokens) && cmd == ""; i++ {
+		t := tokens[i]
+		if t.kind != tokWord || t.quoted {
+			return nil, &SyntaxError{t.offset, "expected menu or command"}
+		}
+		s := t.text
+		if strings.HasPrefix(s, "/") {
+			menu = menu[:0]
+		}
+		elems := strings.Split(s, "/")
+		for _, e := range elems {
+			switch {
+			case cmd != "":
+				if e != "" {
+					return nil, &SyntaxError{t.offset, fmt.Sprintf("unexpected %q after command", e)}
+				}
+			case e == "":
+			case e == "..":
+				if len(menu) > 0 {
+					menu = menu[:len(menu)-1]
+				}
+			case isCommand(menu, e):
+				cmd = e
+			default:
+				menu = append(menu, e)
+			}
+		}
+	}
+	if cmd == "" {
+		return &Line{Menu: menu}, nil
+	}
+
+	sentence := []string{"/" + strings.Join(append(slices.Clone(menu), cmd), "/")}
+	var items []string
+	valueName := ""
+	for ; i < len(tokens); i++ {
+		t := tokens[i]
+		switch t.kind {
+		case tokRaw:
+			sentence = append(sentence, t.text)
+		case tokWord:
+			if t.text == "where" && !t.quoted {
+				query, err := parseWhere(tokens[i+1:], len(line))
+				if err != nil {
+					return nil, err
+				}
+				sentence = append(sentence, query...)
+				i = len(tokens)
+				break
+			}
+			if t.text == "" {
+				return nil, &SyntaxError{t.offset, "empty argument"}
+			}
+			if cmd == "get" && len(items) == 1 {
+				// get names the item and then the property
+				valueName = t.text
+			} else if slices.Contains(itemCommands, cmd) {
+				items = append(items, t.text)
+			} else {
+				sentence = append(sentence, "="+t.text+"=")
+			}
+		case tokCond:
+			if t.op != "=" {
+				return nil, &SyntaxError{t.offset, fmt.Sprintf("unexpected %q outside where", t.op)}
+			}
+			key := t.text
+			if key == "proplist" {
+				key = ".proplist"
+			}
+			if key == ".tag" {
+				sentence = append(sentence, ".tag="+t.value)
+			} else {
+				sentence = append(sentence, "="+key+"="+t.value)
+			}
+		default:
+			return nil, &SyntaxError{t.offset, "unexpected " + t.String() + " outside where"}
+		}
+	}
+	if len(items) > 0 {
+		sentence = append(sentence, "=numbers="+strings.Join(items, ","))
+	}
+	if valueName != "" {
+		sentence = append(sentence, "=value-name="+valueName)
+	}
+	return &Line{Menu: menu, Sentence: sentence}, nil
+}
+
+type tokenKind int
+
+const (
+	tokWord   tokenKind = iota // bare word
+	tokCond                    // name, operator and value
+	tokRaw                     // API word starting with = or ?
+	tokLParen                  // (
+	tokRParen                  // )
+	tokNot                     // !
+)
+
+type token struct {
+	kind   tokenKind
+	offset int
+	text   string // word or name
+	quoted bool   // whether the word was quoted
+	op     string
+	value  string
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokLParen:
+		return `"("`
+	case tokRParen:
+		return `")"`
+	case tokNot:
+		return `"!"`
+	case tokCond:
+		return strconv.Quote(t.text + t.op + t.value)
+	}
+	return strconv.Quote(t.text)
+}
+
+// operators in the order they are matched.
+var operators = []string{"!=", "<=", ">=", "=", "<", ">", "~"}
+
+type lexer struct {
+	s   string
+	pos int
+	// where is set after the word where. Inside a where clause
+	// unquoted values end at ")".
+	where bool
+}
+
+func lex(s string) ([]token, error) {
+	l := &lexer{s: s}
+	var tokens []token
+	for {
+		for l.pos < len(s) && (s[l.pos] == ' ' || s[l.pos] == '\t') {
+			l.pos++
+		}
+		if l.pos == len(s) {
+			return tokens, nil
+		}
+		start := l.pos
+		switch ch := s[l.pos]; {
+		case ch == '(':
+			l.pos++
+			tokens = append(tokens, token{kind: tokLParen, offset: start})
+			continue
+		case ch == ')':
+			l.pos++
+			tokens = append(tokens, token{kind: tokRParen, offset: start})
+			continue
+		case ch == '!' && !strings.HasPrefix(s[l.pos:], "!="):
+			l.pos++
+			tokens = append(tokens, token{kind: tokNot, offset: start})
+			continue
+		case ch == '=' || ch == '?':
+			word, _, err := l.read(false)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokRaw, offset: start, text: word})
+			continue
+		case ch == '[' || ch == ';' || ch == '{' || ch == ':':
+			return nil, &SyntaxError{start, fmt.Sprintf("scripting with %q is not supported", ch)}
+		case ch == '$':
+			return nil, &SyntaxError{start, "variables are not supported"}
+		}
+
+		name, quoted, err := l.read(true)
+		if err != nil {
+			return nil, err
+		}
+		op := ""
+		for _, o := range operators {
+			if strings.HasPrefix(s[l.pos:], o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			tokens = append(tokens, token{kind: tokWord, offset: start, text: name, quoted: quoted})
+			l.where = l.where || name == "where" && !quoted
+			continue
+		}
+		if quoted || name == "" {
+			return nil, &SyntaxError{start, "invalid property name"}
+		}
+		l.pos += len(op)
+		value, _, err := l.read(false)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token{kind: tokCond, offset: start, text: name, op: op, value: value})
+	}
+}
+
+// read reads a word up to a space, inside where clauses also up to ")".
+// Quoted parts and escapes are unquoted. With name set it also stops at
+// operators and parentheses.
+func (l *lexer) read(name bool) (string, bool, error) {
+	var b strings.Builder
+	quoted := false
+	for l.pos < len(l.s) {
+		ch := l.s[l.pos]
+		switch {
+		case ch == ' ' || ch == '\t':
+			return b.String(), quoted, nil
+		case ch == ')' && (l.where || name):
+			return b.String(), quoted, nil
+		case ch == '$':
+			return "", false, &SyntaxError{l.pos, "variables are not supported"}
+		case ch == '[' || ch == ';':
+			return "", false, &SyntaxError{l.pos, fmt.Sprintf("scripting with %q is not supported", ch)}
+		case name && (ch == '(' || strings.IndexByte("!<>=~", ch) >= 0):
+			return b.String(), quoted, nil
+		case ch == '"':
+			quoted = true
+			err := l.quoted(&b)
+			if err != nil {
+				return "", false, err
+			}
+		case ch == '\\':
+			err := l.escape(&b)
+			if err != nil {
+				return "", false, err
+			}
+		default:
+			b.WriteByte(ch)
+			l.pos++
+		}
+	}
+	return b.String(), quoted, nil
+}
+
+// quoted reads a quoted string starting at the opening quote.
+func (l *lexer) quoted(b *strings.Builder) error {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.s) {
+		switch l.s[l.pos] {
+		case '"':
+			l.pos++
+			return nil
+		case '\\':
+			err := l.escape(b)
+			if err != nil {
+				return err
+			}
+		case '$':
+			return &SyntaxError{l.pos, "variables are not supported"}
+		default:
+			b.WriteByte(l.s[l.pos])
+			l.pos++
+		}
+	}
+	return &SyntaxError{start, "unterminated quote"}
+}
+
+// escape reads an escape sequence starting at the backslash.
+func (l *lexer) escape(b *strings.Builder) error {
+	start := l.pos
+	l.pos++
+	if l.pos == len(l.s) {
+		return &SyntaxError{start, "backslash at end of line"}
+	}
+	ch := l.s[l.pos]
+	l.pos++
+	switch ch {
+	case 'n':
+		b.WriteByte('\n')
+	case 'r':
+		b.WriteByte('\r')
+	case 't':
+		b.WriteByte('\t')
+	case '_':
+		b.WriteByte(' ')
+	case '"', '\\', '$', '?':
+		b.WriteByte(ch)
+	default:
+		if l.pos < len(l.s) {
+			v, err := strconv.ParseUint(l.s[l.pos-1:l.pos+1], 16, 8)
+			if err == nil {
+				l.pos++
+				b.WriteByte(byte(v))
+				return nil
+			}
+		}
+		return &SyntaxError{start, fmt.Sprintf(`invalid escape "\%c"`, ch)}
+	}
+	return nil
+}
+
+// Split splits line into words at spaces outside quotes and resolves quotes
+// and escapes, e.g. for lines of API words.
+func Split(line string) ([]string, error) {
+	l := &lexer{s: line}
+	var words []string
+	for {
+		for l.pos < len(line) && (line[l.pos] == ' ' || line[l.pos] == '\t') {
+			l.pos++
+		}
+		if l.pos == len(line) {
+			return words, nil
+		}
+		w, _, err := l.read(false)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, w)
+	}
+}
@@ -0,0 +1,310 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// expr is a node of a where clause.
+type expr interface {
+	// words appends the query words of the node in postfix order.
+	words(dst []string) []string
+	// prec is the precedence of the node, higher binds tighter.
+	prec() int
+}
+
+type (
+	// cmp compares property name with value, op is =, < or >. With op ""
+	// it tests that the item has the property, with op "-" that it lacks it.
+	cmp struct{ name, op, value string }
+	not struct{ x expr }
+	and struct{ x, y expr }
+	or  struct{ x, y expr }
+)
+
+func (e cmp) words(dst []string) []string {
+	switch e.op {
+	case "=":
+		return append(dst, "?"+e.name+"="+e.value)
+	case "", "-":
+		return append(dst, "?"+e.op+e.name)
+	}
+	return append(dst, "?"+e.op+e.name+"="+e.value)
+}
+
+func (e not) words(dst []string) []string { return append(e.x.words(dst), "?#!") }
+func (e and) words(dst []string) []string { return append(e.y.words(e.x.words(dst)), "?#&") }
+func (e or) words(dst []string) []string  { return append(e.y.words(e.x.words(dst)), "?#|") }
+
+func (cmp) prec() int { return 4 }
+func (not) prec() int { return 3 }
+func (and) prec() int { return 2 }
+func (or) prec() int  { return 1 }
+
+// whereParser is a recursive descent parser of where clauses.
+type whereParser struct {
+	tokens []token
+	pos    int
+	// end is the offset of the end of the line.
+	end int
+}
+
+// parseWhere parses the tokens after "where" into query words.
+func parseWhere(tokens []token, end int) ([]string, error) {
+	p := &whereParser{tokens: tokens, end: end}
+	if len(tokens) == 0 {
+		return nil, &SyntaxError{end, "missing condition after where"}
+	}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(tokens) {
+		t := tokens[p.pos]
+		return nil, &SyntaxError{t.offset, "unexpected " + t.String()}
+	}
+	// the conditions of a top level and stay on the stack, which the
+	// device ands
+	var conds []expr
+	for {
+		a, ok := e.(and)
+		if !ok {
+			conds = append(conds, e)
+			break
+		}
+		conds = append(conds, a.y)
+		e = a.x
+	}
+	var words []string
+	for i := len(conds) - 1; i >= 0; i-- {
+		words = conds[i].words(words)
+	}
+	return words, nil
+}
+
+func (p *whereParser) peek() *token {
+	if p.pos == len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+func (p *whereParser) keyword(k string) bool {
+	t := p.peek()
+	if t != nil && t.kind == tokWord && !t.quoted && t.text == k {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *whereParser) or() (expr, error) {
+	x, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		y, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		x = or{x, y}
+	}
+	return x, nil
+}
+
+func (p *whereParser) and() (expr, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if !p.keyword("and") {
+			// conditions without operator between them are anded
+			t := p.peek()
+			if t == nil || t.kind == tokRParen || t.kind == tokWord && t.text == "or" && !t.quoted {
+				return x, nil
+			}
+		}
+		y, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		x = and{x, y}
+	}
+}
+
+func (p *whereParser) unary() (expr, error) {
+	t := p.peek()
+	if t != nil && t.kind == tokNot || p.keyword("not") {
+		if t.kind == tokNot {
+			p.pos++
+		}
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return not{x}, nil
+	}
+	return p.primary()
+}
+
+func (p *whereParser) primary() (expr, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, &SyntaxError{p.end, "missing condition"}
+	}
+	p.pos++
+	switch t.kind {
+	case tokLParen:
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		r := p.peek()
+		if r == nil || r.kind != tokRParen {
+			return nil, &SyntaxError{t.offset, `missing ")"`}
+		}
+		p.pos++
+		return x, nil
+	case tokWord:
+		if t.quoted || t.text == "and" || t.text == "or" || t.text == "not" {
+			break
+		}
+		// a bare property name is true if the property is
+		return cmp{t.text, "=", "true"}, nil
+	case tokRaw:
+		if c, ok := presence(t.text); ok {
+			return c, nil
+		}
+	case tokCond:
+		switch t.op {
+		case "=", "<", ">":
+			return cmp{t.text, t.op, t.value}, nil
+		case "!=":
+			return not{cmp{t.text, "=", t.value}}, nil
+		case "<=":
+			return not{cmp{t.text, ">", t.value}}, nil
+		case ">=":
+			return not{cmp{t.text, "<", t.value}}, nil
+		case "~":
+			return nil, &SyntaxError{t.offset, "the API cannot match regular expressions"}
+		}
+	}
+	return nil, &SyntaxError{t.offset, fmt.Sprintf("expected condition, got %s", t)}
+}
+
+// presence returns the condition of the query word ?name or ?-name, which
+// test whether an item has the property.
+func presence(word string) (cmp, bool) {
+	q, ok := strings.CutPrefix(word, "?")
+	if !ok || strings.ContainsAny(q, "=#<>") {
+		return cmp{}, false
+	}
+	op := ""
+	if name, ok := strings.CutPrefix(q, "-"); ok {
+		op, q = "-", name
+	}
+	return cmp{q, op, ""}, q != ""
+}
+
+// decompile rebuilds the where clause of the query words.
+func decompile(words []string) (expr, error) {
+	var stack []expr
+	pop := func() expr {
+		x := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return x
+	}
+	for _, w := range words {
+		q := w[1:]
+		if ops, ok := strings.CutPrefix(q, "#"); ok {
+			for _, op := range ops {
+				need := 2
+				if op == '!' || op == '.' {
+					need = 1
+				}
+				if len(stack) < need {
+					return nil, fmt.Errorf("RouterOS CLI: query %q lacks operands", w)
+				}
+				switch op {
+				case '!':
+					stack = append(stack, not{pop()})
+				case '.':
+					stack = append(stack, stack[len(stack)-1])
+				case '&':
+					y, x := pop(), pop()
+					stack = append(stack, and{x, y})
+				case '|':
+					y, x := pop(), pop()
+					stack = append(stack, or{x, y})
+				default:
+					return nil, fmt.Errorf("RouterOS CLI: query operation %q has no console syntax", op)
+				}
+			}
+			continue
+		}
+		op := "="
+		if strings.HasPrefix(q, "<") || strings.HasPrefix(q, ">") {
+			op, q = q[:1], q[1:]
+		}
+		if c, ok := presence(w); ok && op == "=" {
+			stack = append(stack, c)
+			continue
+		}
+		name, value, ok := strings.Cut(q, "=")
+		if !ok || name == "" || strings.HasPrefix(name, "-") {
+			return nil, fmt.Errorf("RouterOS CLI: query %q has no console syntax", w)
+		}
+		stack = append(stack, cmp{name, op, value})
+	}
+	if len(stack) == 0 {
+		return nil, nil
+	}
+	x := stack[0]
+	for _, y := range stack[1:] {
+		x = and{x, y}
+	}
+	return x, nil
+}
+
+// format writes e in console syntax.
+func format(b *strings.Builder, e expr) {
+	switch e := e.(type) {
+	case cmp:
+		if e.op == "" || e.op == "-" {
+			// no console syntax, written as the API query word
+			b.WriteString("?" + e.op + e.name)
+			return
+		}
+		b.WriteString(e.name + e.op + Quote(e.value))
+	case not:
+		if c, ok := e.x.(cmp); ok {
+			if op, ok := map[string]string{"=": "!=", ">": "<=", "<": ">="}[c.op]; ok {
+				b.WriteString(c.name + op + Quote(c.value))
+				return
+			}
+		}
+		b.WriteString("!")
+		group(b, e.x, e.prec())
+	case and:
+		group(b, e.x, e.prec())
+		b.WriteString(" and ")
+		group(b, e.y, e.prec()+1)
+	case or:
+		group(b, e.x, e.prec())
+		b.WriteString(" or ")
+		group(b, e.y, e.prec()+1)
+	}
+}
+
+// group formats e, in parentheses if it binds weaker than prec.
+func group(b *strings.Builder, e expr, prec int) {
+	if e.prec() >= prec {
+		format(b, e)
+		return
+	}
+	b.WriteString("(")
+	format(b, e)
+	b.WriteString(")")
+}
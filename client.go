@@ -22,6 +22,9 @@ import (
 // Client is a RouterOS API client.
 type Client struct {
 	Queue int
+	// TagPrefix is prepended to the generated tags of asynchronous
+	// commands.
+	TagPrefix string
 
 	conn    net.Conn
 	r       proto.Reader
@@ -32,6 +35,37 @@ type Client struct {
 	tags    map[string]sentenceProcessor
 	mu      sync.Mutex
 	timeout time.Duration
+
+	// trailing holds the tags ended by !trap whose !done is outstanding.
+	trailing        map[string]struct{}
+	unknownTagFuncs []func(*proto.Sentence)
+
+	shuttingDown bool
+	pending      int
+	drained      chan struct{}
+	loopDone     chan struct{}
+
+	state      atomic.Int32
+	stateFuncs []func(State)
+
+	done     chan struct{}
+	endOnce  sync.Once
+	endErr   error
+	lastRead atomic.Int64
+	latency  atomic.Int64
+	// delivering is set while the read loop hands a sentence to its
+	// command, which blocks while the queue of a listen is full.
+	delivering  atomic.Bool
+	keepaliveOn atomic.Bool
+
+	metrics atomic.Pointer[Metrics]
+
+	infoMu sync.Mutex
+	info   *Info
+
+	// address and opts are set by Connect for Redial.
+	address string
+	opts    []Option
 }
 
 func (c *Client) nextTag() uint64 {
@@ -40,69 +74,44 @@ func (c *Client) nextTag() uint64 {
 
 // NewClient returns a new Client over rwc. Login must be called.
 func NewClient(conn net.Conn, timeout time.Duration) (*Client, error) {
-	return &Client{
+	return newClient(conn, timeout, timeout), nil
+}
+
+func newClient(conn net.Conn, readTimeout, writeTimeout time.Duration) *Client {
+	c := &Client{
 		conn:    conn,
-		r:       proto.NewReader(conn, timeout),
-		w:       proto.NewWriter(conn, timeout),
-		timeout: timeout,
-	}, nil
+		timeout: readTimeout,
+		done:    make(chan struct{}),
+	}
+	mc := &meteredConn{conn, c}
+	c.r = &meteredReader{proto.NewReader(mc, readTimeout), c}
+	c.w = &meteredWriter{proto.NewWriter(mc, writeTimeout), c}
+	c.state.Store(int32(StateConnected))
+	return c
 }
 
 // Dial connects and logs in to a RouterOS device.
 func Dial(address, username, password string) (*Client, error) {
-	conn, err := net.Dial("tcp", address)
-	if err != nil {
-		return nil, err
-	}
-	return newClientAndLogin(conn, username, password, time.Minute)
+	return Connect(context.Background(), address, WithCredentials(username, password))
 }
 
 // DialContext connects and logs in to a RouterOS device.
 func DialContext(ctx context.Context, address, username, password string, timeout time.Duration) (*Client, error) {
-	dialer := net.Dialer{Timeout: timeout}
-	conn, err := dialer.DialContext(ctx, "tcp", address)
-	if err != nil {
-		return nil, err
-	}
-	return newClientAndLogin(conn, username, password, timeout)
+	return Connect(ctx, address, WithCredentials(username, password), WithTimeout(timeout))
 }
 
 // DialTLS connects and logs in to a RouterOS device using TLS.
 func DialTLS(address, username, password string, tlsConfig *tls.Config) (*Client, error) {
-	conn, err := tls.Dial("tcp", address, tlsConfig)
-	if err != nil {
-		return nil, err
-	}
-	return newClientAndLogin(conn, username, password, time.Minute)
+	return Connect(context.Background(), address, WithCredentials(username, password), WithTLS(tlsConfig))
 }
 
 // DialContextTls connects and logs in to a RouterOS device using TLS.
 func DialContextTLS(ctx context.Context, address, username, password string, tlsConfig *tls.Config, timeout time.Duration) (*Client, error) {
-	dialer := net.Dialer{Timeout: timeout}
-	tlsDialer := tls.Dialer{NetDialer: &dialer, Config: tlsConfig}
-
-	conn, err := tlsDialer.DialContext(ctx, "tcp", address)
-	if err != nil {
-		return nil, err
-	}
-	return newClientAndLogin(conn, username, password, timeout)
-}
-
-func newClientAndLogin(conn net.Conn, username, password string, timeout time.Duration) (*Client, error) {
-	c, err := NewClient(conn, timeout)
-	if err != nil {
-		conn.Close()
-		return nil, err
-	}
-	err = c.Login(username, password)
-	if err != nil {
-		c.Close()
-		return nil, err
-	}
-	return c, nil
+	return Connect(ctx, address, WithCredentials(username, password), WithTLS(tlsConfig), WithTimeout(timeout))
 }
 
-// Close closes the connection to the RouterOS device.
+// Close closes the connection to the RouterOS device immediately, see
+// Shutdown for a graceful close.
 func (c *Client) Close() {
 	c.mu.Lock()
 	if c.closing {
@@ -111,6 +120,7 @@ func (c *Client) Close() {
 	}
 	c.closing = true
 	c.mu.Unlock()
+	c.end(ErrClosed)
 	c.conn.Close()
 }
 
@@ -124,6 +134,7 @@ func (c *Client) Login(username, password string) error {
 	if !ok {
 		// Login method post-6.43 one stage, cleartext and no challenge
 		if r.Done != nil {
+			c.setState(StateLoggedIn)
 			return nil
 		}
 		return errors.New("RouterOS: /login: no ret (challenge) received")
@@ -140,6 +151,7 @@ func (c *Client) Login(username, password string) error {
 		return err
 	}
 
+	c.setState(StateLoggedIn)
 	return nil
 }
 
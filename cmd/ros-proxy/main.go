@@ -0,0 +1,138 @@
+/*
+Command ros-proxy is a RouterOS API gateway. It accepts API clients,
+authenticates them against its own user list and multiplexes their commands
+over a small number of upstream connections to one device.
+
+Usage:
+
+	ros-proxy -config proxy.json
+
+The configuration file looks like:
+
+	{
+		"listen": ":8728",
+		"upstream": {
+			"address": "192.168.88.1:8728",
+			"username": "api",
+			"password": "secret",
+			"connections": 2,
+			"tls": false,
+			"fingerprint": "",
+			"pin_store": ""
+		},
+		"audit_log": "/var/log/ros-proxy.log",
+		"users": [
+			{"name": "monitoring", "password": "x", "allow": ["/interface/print", "/ip/address/**"]},
+			{"name": "ops", "password": "y", "deny": ["/system/**"]}
+		]
+	}
+
+With tls the upstream certificate is verified against the system roots,
+unless fingerprint pins its public key, see routeros.Fingerprint, or
+pin_store names a file of fingerprints trusted on first use, as needed for
+the self-signed certificates of RouterOS. Either implies tls.
+
+Allow and deny rules are path.Match patterns on the command path where a
+trailing "/**" matches any number of path elements. Deny rules win over allow
+rules and a user without allow rules may run any command that is not denied.
+
+Replies are relayed to each client at its own pace. A client falling more
+than 100 sentences behind, e.g. on a busy listen, has its command cancelled
+with a trap, so it cannot stall the others sharing its upstream connection.
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net"
+	"os"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/server"
+)
+
+type config struct {
+	Listen   string `json:"listen"`
+	Upstream struct {
+		Address     string `json:"address"`
+		Username    string `json:"username"`
+		Password    string `json:"password"`
+		Connections int    `json:"connections"`
+		TLS         bool   `json:"tls"`
+		Fingerprint string `json:"fingerprint"`
+		PinStore    string `json:"pin_store"`
+	} `json:"upstream"`
+	AuditLog string `json:"audit_log"`
+	Users    []User `json:"users"`
+}
+
+var configFile = flag.String("config", "ros-proxy.json", "Configuration file")
+
+func loadConfig(name string) (*config, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config{Listen: ":8728"}
+	err = json.Unmarshal(b, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Upstream.Connections <= 0 {
+		cfg.Upstream.Connections = 1
+	}
+	return cfg, nil
+}
+
+func main() {
+	flag.Parse()
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var audit io.Writer = os.Stderr
+	if cfg.AuditLog != "" {
+		f, err := os.OpenFile(cfg.AuditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		audit = f
+	}
+
+	up := cfg.Upstream
+	opts := []routeros.Option{routeros.WithCredentials(up.Username, up.Password)}
+	if up.TLS {
+		opts = append(opts, routeros.WithTLS(nil))
+	}
+	if up.Fingerprint != "" {
+		opts = append(opts, routeros.WithPinnedFingerprints(up.Fingerprint))
+	}
+	if up.PinStore != "" {
+		store, err := routeros.OpenPinStore(up.PinStore)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, routeros.WithPinStore(store))
+	}
+	dial := func() (*routeros.Client, error) {
+		return routeros.Connect(context.Background(), up.Address, opts...)
+	}
+
+	p := NewProxy(cfg.Users, up.Connections, dial, log.New(audit, "", log.LstdFlags))
+	defer p.Close()
+
+	l, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv := &server.Server{Handler: p, Auth: p}
+	log.Printf("ros-proxy: listening on %s, upstream %s", l.Addr(), up.Address)
+	log.Fatal(srv.Serve(l))
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/proto"
+	"github.com/swoga/go-routeros/server"
+)
+
+// User is a client account of the proxy.
+type User struct {
+	Name     string   `json:"name"`
+	Password string   `json:"password"`
+	Allow    []string `json:"allow"`
+	Deny     []string `json:"deny"`
+}
+
+// permits reports whether u may run the command at cmdPath. Deny rules take
+// precedence over allow rules, and an empty allow list allows everything.
+func (u *User) permits(cmdPath string) bool {
+	for _, pattern := range u.Deny {
+		if matchRule(pattern, cmdPath) {
+			return false
+		}
+	}
+	if len(u.Allow) == 0 {
+		return true
+	}
+	for _, pattern := range u.Allow {
+		if matchRule(pattern, cmdPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRule matches cmdPath against pattern using path.Match, where a
+// trailing "/**" matches any number of path elements.
+func matchRule(pattern, cmdPath string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return cmdPath == prefix || strings.HasPrefix(cmdPath, prefix+"/")
+	}
+	ok, _ := path.Match(pattern, cmdPath)
+	return ok
+}
+
+// Proxy relays the commands of its clients over a pool of upstream
+// connections. The upstream Client tags every command with its own tag, so
+// the commands of many clients share a connection without colliding.
+type Proxy struct {
+	users map[string]*User
+	pool  *pool
+	audit *log.Logger
+	queue int
+}
+
+// NewProxy returns a Proxy for users relaying to the connections of dial.
+// Audit records are written to audit if it is not nil.
+func NewProxy(users []User, size int, dial func() (*routeros.Client, error), audit *log.Logger) *Proxy {
+	p := &Proxy{
+		users: make(map[string]*User),
+		pool:  &pool{dial: dial, conns: make([]*routeros.Client, size), dialing: make([]chan struct{}, size)},
+		audit: audit,
+		queue: 100,
+	}
+	for i := range users {
+		p.users[users[i].Name] = &users[i]
+	}
+	return p
+}
+
+// Authenticate implements server.Authenticator.
+func (p *Proxy) Authenticate(name, password string) bool {
+	u, ok := p.users[name]
+	return ok && subtle.ConstantTimeCompare([]byte(u.Password), []byte(password)) == 1
+}
+
+// Password implements server.ChallengeAuthenticator.
+func (p *Proxy) Password(name string) (string, bool) {
+	u, ok := p.users[name]
+	if !ok {
+		return "", false
+	}
+	return u.Password, true
+}
+
+// Close closes all upstream connections.
+func (p *Proxy) Close() {
+	p.pool.close()
+}
+
+// ServeAPI implements server.Handler.
+func (p *Proxy) ServeAPI(w server.ResponseWriter, cmd *server.Command) {
+	start := time.Now()
+	result := p.relay(w, cmd)
+	p.auditf("user=%s addr=%s cmd=%s result=%q duration=%s", cmd.User, cmd.RemoteAddr, cmd.Word, result, time.Since(start).Round(time.Millisecond))
+}
+
+func (p *Proxy) auditf(format string, args ...any) {
+	if p.audit != nil {
+		p.audit.Printf(format, args...)
+	}
+}
+
+// relay forwards cmd upstream and its reply back to w. It returns a short
+// description of the outcome for the audit log.
+func (p *Proxy) relay(w server.ResponseWriter, cmd *server.Command) string {
+	u := p.users[cmd.User]
+	if u == nil || !u.permits(cmd.Word) {
+		w.Trap(server.NoCategory, "not enough permissions (9)")
+		w.Done()
+		return "denied"
+	}
+
+	c, err := p.pool.get()
+	if err != nil {
+		w.Trap(server.NoCategory, "upstream unavailable: "+err.Error())
+		w.Done()
+		return "upstream error: " + err.Error()
+	}
+
+	sentence := []string{cmd.Word}
+	for _, pair := range cmd.List {
+		sentence = append(sentence, "="+pair.Key+"="+pair.Value)
+	}
+	for _, q := range cmd.Query {
+		sentence = append(sentence, "?"+q)
+	}
+
+	// ListenArgsQueue streams the reply of any command, not only listen.
+	l, err := c.ListenArgsQueue(sentence, p.queue)
+	if err != nil {
+		w.Trap(server.NoCategory, "upstream error: "+err.Error())
+		w.Done()
+		return "upstream error: " + err.Error()
+	}
+
+	// The sentences are written to the client by another goroutine, so a
+	// client reading slowly does not stall the upstream connection shared
+	// with others. If it falls behind by more than the queue, its command is
+	// cancelled.
+	out := make(chan []proto.Pair, p.queue)
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		for pairs := range out {
+			w.Re(pairs...)
+		}
+	}()
+
+	slow := false
+	cancelled := cmd.Context().Done()
+	reC := l.Chan()
+	for reC != nil {
+		select {
+		case sen, ok := <-reC:
+			if !ok {
+				reC = nil
+				break
+			}
+			if slow {
+				break
+			}
+			select {
+			case out <- sen.List:
+			default:
+				slow = true
+				l.Cancel()
+			}
+		case <-cancelled:
+			l.Cancel()
+			cancelled = nil
+		}
+	}
+	close(out)
+	<-written
+
+	var devErr *routeros.DeviceError
+	switch err := l.Err(); {
+	case slow:
+		w.Trap(server.NoCategory, "client too slow")
+		w.Done()
+		return "client too slow"
+	case errors.As(err, &devErr):
+		return relayDeviceError(w, devErr.Sentence)
+	case err != nil:
+		w.Trap(server.NoCategory, "upstream error: "+err.Error())
+		w.Done()
+		return "upstream error: " + err.Error()
+	case l.Done != nil && l.Done.Word == "!trap":
+		if cmd.Context().Err() == nil {
+			return relayDeviceError(w, l.Done)
+		}
+		// cancelled by the client, the server reports the interruption
+		return "cancelled"
+	case l.Done != nil:
+		w.Done(l.Done.List...)
+	default:
+		w.Done()
+	}
+	return "done"
+}
+
+func relayDeviceError(w server.ResponseWriter, sen *proto.Sentence) string {
+	if sen.Word == "!fatal" {
+		w.Trap(server.NoCategory, sen.Map["message"])
+		w.Done()
+		return "fatal: " + sen.Map["message"]
+	}
+	category := server.NoCategory
+	if c, ok := sen.Map["category"]; ok {
+		fmt.Sscan(c, &category)
+	}
+	w.Trap(category, sen.Map["message"])
+	w.Done()
+	return "trap: " + sen.Map["message"]
+}
+
+// pool is a fixed size set of upstream connections used round-robin. Broken
+// connections are redialed on their next use. A slot being dialed is waited
+// for by its users only, other slots stay usable.
+type pool struct {
+	dial func() (*routeros.Client, error)
+
+	mu    sync.Mutex
+	conns []*routeros.Client
+	// dialing holds per slot a channel closed when its dial ends
+	dialing []chan struct{}
+	next    int
+	closed  bool
+}
+
+func (p *pool) get() (*routeros.Client, error) {
+	p.mu.Lock()
+	i := p.next
+	p.next = (p.next + 1) % len(p.conns)
+	for p.dialing[i] != nil {
+		dialing := p.dialing[i]
+		p.mu.Unlock()
+		<-dialing
+		p.mu.Lock()
+	}
+	if p.closed {
+		p.mu.Unlock()
+		return nil, routeros.ErrClosed
+	}
+	c := p.conns[i]
+	if c != nil && c.Healthy() {
+		p.mu.Unlock()
+		return c, nil
+	}
+	if c != nil {
+		c.Close()
+		p.conns[i] = nil
+	}
+	dialing := make(chan struct{})
+	p.dialing[i] = dialing
+	p.mu.Unlock()
+
+	c, err := p.dial()
+	if err == nil {
+		c.Async()
+		go func() {
+			if err := c.Wait(); !errors.Is(err, routeros.ErrClosed) {
+				log.Printf("ros-proxy: upstream connection failed: %s", err)
+			}
+		}()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dialing[i] = nil
+	close(dialing)
+	if err != nil {
+		return nil, err
+	}
+	if p.closed {
+		c.Close()
+		return nil, routeros.ErrClosed
+	}
+	p.conns[i] = c
+	return c, nil
+}
+
+func (p *pool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for i, c := range p.conns {
+		if c != nil {
+			c.Close()
+			p.conns[i] = nil
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/proto"
+	"github.com/swoga/go-routeros/server"
+	"github.com/swoga/go-routeros/server/servertest"
+	"github.com/swoga/go-routeros/sim"
+)
+
+// syncBuffer is a bytes.Buffer safe for the concurrent audit log writes.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+// startProxy starts a simulated device and a proxy in front of it. It
+// returns the device, the proxy address, the audit log and the number of
+// upstream dials.
+func startProxy(t *testing.T, users []User) (*sim.Device, string, *syncBuffer, *atomic.Int32) {
+	d := sim.New()
+	d.Users = map[string]string{"upstream": "up"}
+	upstream := servertest.Start(t, d)
+
+	var dials atomic.Int32
+	audit := &syncBuffer{}
+	p := NewProxy(users, 1, func() (*routeros.Client, error) {
+		dials.Add(1)
+		return routeros.Dial(upstream, "upstream", "up")
+	}, log.New(audit, "", 0))
+	t.Cleanup(p.Close)
+
+	srv := &server.Server{Handler: p, Auth: p}
+	t.Cleanup(func() { srv.Close() })
+	return d, servertest.Start(t, srv), audit, &dials
+}
+
+func dial(t *testing.T, addr, user, password string) *routeros.Client {
+	c, err := routeros.Dial(addr, user, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestProxy(t *testing.T) {
+	d, addr, audit, dials := startProxy(t, []User{
+		{Name: "ops", Password: "secret"},
+		{Name: "monitoring", Password: "ro", Allow: []string{"/interface/print", "/ip/**"}, Deny: []string{"/ip/address/remove"}},
+	})
+
+	_, err := routeros.Dial(addr, "ops", "wrong")
+	if err == nil {
+		t.Fatal("Dial with wrong password succeeded; want error")
+	}
+
+	ops := dial(t, addr, "ops", "secret")
+	mon := dial(t, addr, "monitoring", "ro")
+
+	r, err := ops.Run("/ip/address/add", "=address=192.0.2.1/24", "=interface=ether1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := r.Done.Map["ret"]
+	if len(d.Items("/ip/address")) != 1 {
+		t.Fatal("add was not relayed to the device")
+	}
+
+	r, err = mon.Run("/interface/print", "?name=ether1", "=.proplist=name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Re) != 1 || r.Re[0].Map["name"] != "ether1" {
+		t.Fatalf("print=%s; want ether1", r)
+	}
+
+	for _, cmd := range []string{"/ip/address/remove", "/system/identity/set"} {
+		_, err = mon.Run(cmd, "=.id="+id)
+		if err == nil || err.Error() != "from RouterOS device: not enough permissions (9)" {
+			t.Fatalf("%s=%v; want not enough permissions", cmd, err)
+		}
+	}
+
+	_, err = ops.Run("/ip/address/add", "=address=192.0.2.1/24", "=interface=ether1")
+	if err == nil || err.Error() != "from RouterOS device: failure: already have such address" {
+		t.Fatalf("duplicate add=%v; want device trap", err)
+	}
+
+	if dials.Load() != 1 {
+		t.Errorf("upstream dials=%d; want 1", dials.Load())
+	}
+	log := audit.String()
+	for _, want := range []string{
+		`user=ops addr=127.0.0.1:`,
+		`cmd=/ip/address/add result="done"`,
+		`user=monitoring addr=127.0.0.1:`,
+		`cmd=/ip/address/remove result="denied"`,
+		`result="trap: failure: already have such address"`,
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("audit log misses %q:\n%s", want, log)
+		}
+	}
+}
+
+func TestProxyListen(t *testing.T) {
+	d, addr, audit, _ := startProxy(t, []User{{Name: "ops", Password: "secret"}})
+	c := dial(t, addr, "ops", "secret")
+
+	l, err := c.Listen("/ip/firewall/address-list/listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; d.Listeners("/ip/firewall/address-list") == 0; i++ {
+		if i == 100 {
+			t.Fatal("listen not relayed to the device")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// A second client sharing the upstream connection is served meanwhile.
+	_, err = dial(t, addr, "ops", "secret").Run("/ip/firewall/address-list/add", "=list=block", "=address=192.0.2.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sen := <-l.Chan()
+	if sen.Map["address"] != "192.0.2.7" {
+		t.Fatalf("listen=%s; want added entry", sen)
+	}
+	_, err = l.Cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range l.Chan() {
+	}
+	if l.Err() != nil {
+		t.Fatal(l.Err())
+	}
+	if l.Done.Map["category"] != "2" {
+		t.Fatalf("Done=%s; want interrupted", l.Done)
+	}
+	if len(d.Items("/ip/firewall/address-list")) != 1 {
+		t.Fatal("add was not relayed to the device")
+	}
+	c.Close()
+	if !strings.Contains(audit.String(), `cmd=/ip/firewall/address-list/listen result="cancelled"`) {
+		t.Errorf("audit log misses cancelled listen:\n%s", audit)
+	}
+}
+
+func TestProxySlowClient(t *testing.T) {
+	d := sim.New()
+	upstream := servertest.Start(t, d)
+	audit := &syncBuffer{}
+	p := NewProxy([]User{{Name: "ops", Password: "secret"}}, 1, func() (*routeros.Client, error) {
+		return routeros.Dial(upstream, "admin", "")
+	}, log.New(audit, "", 0))
+	p.queue = 4
+	t.Cleanup(p.Close)
+	srv := &server.Server{Handler: p, Auth: p}
+	t.Cleanup(func() { srv.Close() })
+	addr := servertest.Start(t, srv)
+
+	// a client that starts a listen and never reads
+	slow, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Close()
+	w := proto.NewWriter(slow, time.Second)
+	for _, sentence := range [][]string{
+		{"/login", "=name=ops", "=password=secret"},
+		{"/ip/firewall/address-list/listen"},
+	} {
+		w.BeginSentence()
+		for _, word := range sentence {
+			w.WriteWord(word)
+		}
+		if err := w.EndSentence(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; d.Listeners("/ip/firewall/address-list") == 0; i++ {
+		if i == 100 {
+			t.Fatal("listen not relayed to the device")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the other clients sharing the upstream connection are not stalled
+	c := dial(t, addr, "ops", "secret")
+	comment := "=comment=" + strings.Repeat("x", 64<<10)
+	errC := make(chan error, 1)
+	go func() {
+		for i := range 200 {
+			_, err := c.Run("/ip/firewall/address-list/add", "=list=block", fmt.Sprintf("=address=10.0.%d.%d", i/256, i%256), comment)
+			if err != nil {
+				errC <- err
+				return
+			}
+		}
+		errC <- nil
+	}()
+	select {
+	case err := <-errC:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("commands stalled behind a slow client")
+	}
+	if n := d.Listeners("/ip/firewall/address-list"); n != 0 {
+		t.Fatalf("%d listens left on the device; want the slow one cancelled", n)
+	}
+}
+
+func TestPoolSlowDial(t *testing.T) {
+	upstream := servertest.Start(t, sim.New())
+
+	release := make(chan struct{})
+	var dials atomic.Int32
+	p := &pool{
+		dial: func() (*routeros.Client, error) {
+			if dials.Add(1) == 1 {
+				// the first upstream is slow to answer
+				<-release
+			}
+			return routeros.Dial(upstream, "admin", "")
+		},
+		conns:   make([]*routeros.Client, 2),
+		dialing: make([]chan struct{}, 2),
+	}
+	defer p.close()
+
+	errC := make(chan error, 1)
+	go func() {
+		_, err := p.get()
+		errC <- err
+	}()
+	for dials.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// the other slot is usable while the first one is dialed
+	_, err := p.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if err := <-errC; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMatchRule(t *testing.T) {
+	for _, test := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/ip/address/print", "/ip/address/print", true},
+		{"/ip/*/print", "/ip/address/print", true},
+		{"/ip/*/print", "/ip/firewall/filter/print", false},
+		{"/ip/**", "/ip/firewall/filter/print", true},
+		{"/ip/**", "/ipv6/address/print", false},
+		{"/**", "/system/reboot", true},
+	} {
+		if got := matchRule(test.pattern, test.path); got != test.want {
+			t.Errorf("matchRule(%q, %q)=%v; want %v", test.pattern, test.path, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// initialisms are written in upper case in Go names.
+var initialisms = map[string]bool{
+	"api": true, "arp": true, "bgp": true, "cpu": true, "dhcp": true,
+	"dns": true, "id": true, "ip": true, "ipv6": true, "l2tp": true,
+	"lte": true, "mac": true, "mpls": true, "mtu": true, "nat": true,
+	"ntp": true, "ospf": true, "ppp": true, "pppoe": true, "radius": true,
+	"sntp": true, "snmp": true, "ssh": true, "tcp": true, "tls": true,
+	"ttl": true, "udp": true, "upnp": true, "url": true, "usb": true,
+	"vlan": true, "vrrp": true, "vrf": true,
+}
+
+// goName returns the exported Go name of a menu path or property, e.g.
+// IPFirewallFilter for /ip/firewall/filter and MACAddress for mac-address.
+func goName(s string) string {
+	name := camel(s)
+	if name == "" || !unicode.IsLetter(rune(name[0])) {
+		name = "X" + name
+	}
+	return name
+}
+
+// camel joins the words of s in camel case.
+func camel(s string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if initialisms[part] {
+			b.WriteString(strings.ToUpper(part))
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// skipProps are arguments of add and set that are no properties of items.
+var skipProps = []string{"copy-from", "numbers", "place-before", ".id"}
+
+// field is a property of the items of a menu.
+type field struct {
+	name, prop, typ string
+}
+
+// generate returns Go source for package pkg with an item struct and a menu
+// accessor for every menu of s with a print command. The properties of the
+// items are the arguments of add and set.
+func generate(s *Schema, pkg string) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by ros-schema from RouterOS %s; DO NOT EDIT.\n\n", s.RouterOS)
+	fmt.Fprintf(&b, "// Package %s provides typed access to the menus of RouterOS %s.\n", pkg, s.RouterOS)
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+
+	var body bytes.Buffer
+	usesTime := false
+	names := make(map[string]string)
+	for _, m := range s.Menus {
+		if m.Command("print") == nil {
+			continue
+		}
+		name := goName(m.Path)
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("menus %s and %s are both named %s", other, m.Path, name)
+		}
+		names[name] = m.Path
+
+		// menus with items, unlike e.g. /system/identity, address them
+		// by .id
+		set := m.Command("set")
+		items := m.Command("add") != nil || set != nil && set.Arg("numbers") != nil
+		var fields []field
+		// properties and Go names already taken
+		seen := map[string]bool{"ID": items}
+		for _, c := range []*Command{m.Command("add"), set} {
+			if c == nil {
+				continue
+			}
+			for _, a := range c.Args {
+				if seen[a.Name] || seen[goName(a.Name)] || slices.Contains(skipProps, a.Name) {
+					continue
+				}
+				seen[a.Name] = true
+				seen[goName(a.Name)] = true
+				typ := ""
+				switch a.Type {
+				case "bool":
+					typ = "bool"
+				case "int":
+					typ = "int64"
+				case "duration":
+					typ = "time.Duration"
+					usesTime = true
+				case "enum":
+					typ = name + goName(a.Name)
+				default:
+					typ = "string"
+				}
+				fields = append(fields, field{goName(a.Name), a.Name, typ})
+				if a.Type == "enum" {
+					writeEnum(&body, typ, m.Path, a)
+				}
+			}
+		}
+		slices.SortFunc(fields, func(a, b field) int {
+			return strings.Compare(a.name, b.name)
+		})
+
+		fmt.Fprintf(&body, "// %s is an item of %s.\n", name, m.Path)
+		fmt.Fprintf(&body, "type %s struct {\n", name)
+		if items {
+			fmt.Fprintf(&body, "\tID string `routeros:\".id\"`\n")
+		}
+		for _, f := range fields {
+			fmt.Fprintf(&body, "\t%s %s `routeros:%s`\n", f.name, f.typ, strconv.Quote(f.prop))
+		}
+		fmt.Fprintf(&body, "}\n\n")
+		writeMenu(&body, name, m, items)
+	}
+
+	b.WriteString("import (\n\t\"context\"\n\t\"maps\"\n\t\"slices\"\n")
+	if usesTime {
+		b.WriteString("\t\"time\"\n")
+	}
+	b.WriteString("\n\t\"github.com/swoga/go-routeros\"\n)\n\n")
+	b.WriteString(`// Menus gives access to the menus of a device.
+type Menus struct {
+	r routeros.Runner
+}
+
+// New returns the menus of the device r is connected to.
+func New(r routeros.Runner) *Menus {
+	return &Menus{r: r}
+}
+
+// run runs the command at path with attrs sorted by name.
+func run(r routeros.Runner, path string, attrs map[string]string) (*routeros.Reply, error) {
+	sentence := []string{path}
+	for _, k := range slices.Sorted(maps.Keys(attrs)) {
+		sentence = append(sentence, "="+k+"="+attrs[k])
+	}
+	return r.RunArgs(sentence)
+}
+
+`)
+	b.Write(body.Bytes())
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+// writeEnum writes the string type typ of the enum a with a constant per
+// value.
+func writeEnum(b *bytes.Buffer, typ, path string, a *Arg) {
+	fmt.Fprintf(b, "// %s is the type of %s in %s.\n", typ, a.Name, path)
+	fmt.Fprintf(b, "type %s string\n\n", typ)
+	fmt.Fprintf(b, "const (\n")
+	seen := make(map[string]bool)
+	for _, v := range a.Values {
+		name := typ + camel(v)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		fmt.Fprintf(b, "\t%s %s = %s\n", name, typ, strconv.Quote(v))
+	}
+	fmt.Fprintf(b, ")\n\n")
+}
+
+// writeMenu writes the accessor of menu m with methods for its print, add,
+// set and remove commands.
+func writeMenu(b *bytes.Buffer, name string, m *Menu, items bool) {
+	menu := name + "Menu"
+	path := strconv.Quote(m.Path)
+	fmt.Fprintf(b, "// %s returns the menu %s.\n", name, m.Path)
+	fmt.Fprintf(b, "func (m *Menus) %s() *%s {\n\treturn &%s{r: m.r}\n}\n\n", name, menu, menu)
+	fmt.Fprintf(b, "// %s is the menu %s.\n", menu, m.Path)
+	fmt.Fprintf(b, "type %s struct {\n\tr routeros.Runner\n}\n\n", menu)
+
+	fmt.Fprintf(b, "// Print returns the items of the menu. args are passed as is, e.g.\n// \"?disabled=false\".\n")
+	fmt.Fprintf(b, "func (m *%s) Print(ctx context.Context, args ...string) ([]%s, error) {\n", menu, name)
+	fmt.Fprintf(b, "\treturn routeros.PrintTyped[%s](ctx, m.r, %s, args...)\n}\n\n", name, path)
+
+	if m.Command("add") != nil {
+		fmt.Fprintf(b, "// Add adds an item and returns its .id.\n")
+		fmt.Fprintf(b, "func (m *%s) Add(attrs map[string]string) (string, error) {\n", menu)
+		fmt.Fprintf(b, "\treturn m.r.Add(%s, attrs)\n}\n\n", path)
+	}
+	if m.Command("set") != nil {
+		if items {
+			fmt.Fprintf(b, "// Set sets attrs of the item with id.\n")
+			fmt.Fprintf(b, "func (m *%s) Set(id string, attrs map[string]string) error {\n", menu)
+			fmt.Fprintf(b, "\treturn m.r.Set(%s, id, attrs)\n}\n\n", path)
+		} else {
+			fmt.Fprintf(b, "// Set sets attrs of the menu.\n")
+			fmt.Fprintf(b, "func (m *%s) Set(attrs map[string]string) error {\n", menu)
+			fmt.Fprintf(b, "\t_, err := run(m.r, %s, attrs)\n\treturn err\n}\n\n", strconv.Quote(m.Path+"/set"))
+		}
+	}
+	if m.Command("remove") != nil {
+		fmt.Fprintf(b, "// Remove removes the items with ids.\n")
+		fmt.Fprintf(b, "func (m *%s) Remove(ids ...string) error {\n", menu)
+		fmt.Fprintf(b, "\treturn m.r.Remove(%s, ids...)\n}\n\n", path)
+	}
+}
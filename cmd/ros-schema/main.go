@@ -0,0 +1,168 @@
+/*
+Command ros-schema discovers the menus of a RouterOS 7 device with
+/console/inspect and generates typed Go code for them.
+
+Usage:
+
+	ros-schema dump [flags] > schema.json
+	ros-schema gen [flags] schema.json
+
+dump walks the menu tree, or with -path the tree below e.g. /ip, and writes a
+versioned JSON schema of the menus, their commands and the names, syntax and
+inferred types of the arguments. Argument types are string, bool, int,
+duration or enum with the values of the enum. A device with the self-signed
+certificate of RouterOS is reached with -fingerprint, see
+routeros.Fingerprint.
+
+gen reads such a schema and writes a Go package with a struct per menu that
+has a print command, decodable with routeros.PrintTyped, enum types for
+arguments with fixed values and accessors for print, add, set and remove:
+
+	m := ros.New(c)
+	addrs, err := m.IPAddress().Print(ctx, "?disabled=false")
+
+The properties of the structs are the arguments of add and set; properties
+only printed, e.g. counters, are not part of the schema.
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/swoga/go-routeros"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: ros-schema dump [flags]\n       ros-schema gen [flags] schema.json\n")
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "dump":
+		err = dump(os.Args[2:])
+	case "gen":
+		err = gen(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func dump(args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	address := fs.String("address", "127.0.0.1:8728", "RouterOS address and port")
+	username := fs.String("username", "admin", "User name")
+	password := fs.String("password", "", "Password")
+	useTLS := fs.Bool("tls", false, "Use TLS")
+	fingerprint := fs.String("fingerprint", "", "Accept only a device certificate with this public key fingerprint (SHA256:...), implies -tls")
+	timeout := fs.Duration("timeout", 10*time.Second, "Timeout of connecting and of single API calls")
+	root := fs.String("path", "/", "Menu to walk, e.g. /ip")
+	parallel := fs.Int("parallel", 8, "Maximum number of inspect requests in flight")
+	out := fs.String("o", "", "Output file instead of stdout")
+	fs.Parse(args)
+
+	opts := []routeros.Option{
+		routeros.WithCredentials(*username, *password),
+		routeros.WithTimeout(*timeout),
+		routeros.WithAsync(),
+	}
+	if *useTLS {
+		opts = append(opts, routeros.WithTLS(nil))
+	}
+	if *fingerprint != "" {
+		opts = append(opts, routeros.WithPinnedFingerprints(*fingerprint))
+	}
+	c, err := routeros.Connect(context.Background(), *address, opts...)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	s, err := discover(c, *root, *parallel)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return write(*out, append(b, '\n'))
+}
+
+// discover returns the schema of the menus below root.
+func discover(r routeros.Runner, root string, parallel int) (*Schema, error) {
+	reply, err := r.Run("/system/resource/print")
+	if err != nil {
+		return nil, err
+	}
+	s := &Schema{Version: SchemaVersion}
+	if len(reply.Re) > 0 {
+		s.RouterOS = reply.Re[0].Map["version"]
+	}
+	var path []string
+	for _, p := range strings.Split(root, "/") {
+		if p != "" {
+			path = append(path, p)
+		}
+	}
+	s.Menus, err = walk(r, path, parallel)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Menus) == 0 {
+		return nil, fmt.Errorf("no menus below %s, /console/inspect requires RouterOS 7", root)
+	}
+	return s, nil
+}
+
+func gen(args []string) error {
+	fs := flag.NewFlagSet("gen", flag.ExitOnError)
+	pkg := fs.String("package", "ros", "Name of the generated package")
+	out := fs.String("o", "", "Output file instead of stdout")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	b, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	s := &Schema{}
+	err = json.Unmarshal(b, s)
+	if err != nil {
+		return err
+	}
+	if s.Version != SchemaVersion {
+		return fmt.Errorf("%s: schema version %d, want %d", fs.Arg(0), s.Version, SchemaVersion)
+	}
+	src, err := generate(s, *pkg)
+	if err != nil {
+		return err
+	}
+	return write(*out, src)
+}
+
+// write writes b to the file name, to stdout if name is empty.
+func write(name string, b []byte) error {
+	if name == "" {
+		_, err := os.Stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(name, b, 0644)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// SchemaVersion is the version of the schema format. It changes with
+// incompatible changes of Schema.
+const SchemaVersion = 1
+
+// Schema describes the menus of a RouterOS version.
+type Schema struct {
+	Version  int     `json:"version"`
+	RouterOS string  `json:"routeros"`
+	Menus    []*Menu `json:"menus"`
+}
+
+// Menu is a menu with at least one command, sorted by path in a Schema.
+type Menu struct {
+	// Path is the API path of the menu, e.g. /ip/address.
+	Path     string     `json:"path"`
+	Commands []*Command `json:"commands"`
+}
+
+// Command is a command of a menu, sorted by name.
+type Command struct {
+	Name string `json:"name"`
+	Args []*Arg `json:"args,omitempty"`
+}
+
+// Arg is an argument of a command, sorted by name.
+type Arg struct {
+	Name string `json:"name"`
+	// Type is string, bool, int, duration or enum, see inferType.
+	Type string `json:"type"`
+	// Values holds the values of an enum.
+	Values []string `json:"values,omitempty"`
+	// Syntax is the value syntax as described by the device.
+	Syntax      string `json:"syntax,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Command returns the command name of m, nil if it has none.
+func (m *Menu) Command(name string) *Command {
+	for _, c := range m.Commands {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Arg returns the argument name of c, nil if it has none.
+func (c *Command) Arg(name string) *Arg {
+	for _, a := range c.Args {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+var (
+	enumSyntax  = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*( \| [a-z0-9][a-z0-9-]*)+$`)
+	rangeSyntax = regexp.MustCompile(`^-?[0-9]+\.\.-?[0-9]+$`)
+)
+
+// inferType derives the type of an argument from its syntax. Values that
+// cannot be classified are strings.
+func inferType(syntax string) (typ string, values []string) {
+	s := strings.TrimSpace(syntax)
+	lower := strings.ToLower(s)
+	switch {
+	case lower == "yes | no" || lower == "no | yes" || lower == "true | false":
+		return "bool", nil
+	case enumSyntax.MatchString(s):
+		return "enum", strings.Split(s, " | ")
+	case rangeSyntax.MatchString(s) || strings.HasPrefix(lower, "integer"):
+		return "int", nil
+	case strings.Contains(lower, "time interval"):
+		return "duration", nil
+	}
+	return "string", nil
+}
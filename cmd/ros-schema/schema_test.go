@@ -0,0 +1,205 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/proto"
+	"github.com/swoga/go-routeros/server"
+	"github.com/swoga/go-routeros/server/servertest"
+)
+
+// device serves /console/inspect for a small menu tree. children maps paths
+// to name/node-type pairs, syntax maps argument paths to their syntax and
+// explanations maps command paths to the explanations of their arguments.
+type device struct {
+	children     map[string][]string
+	syntax       map[string]string
+	explanations map[string][]string
+}
+
+func (d *device) ServeAPI(w server.ResponseWriter, cmd *server.Command) {
+	switch cmd.Word {
+	case "/system/resource/print":
+		w.Re(proto.Pair{Key: "version", Value: "7.15.3 (stable)"})
+	case "/console/inspect":
+		path := cmd.Map["path"]
+		switch cmd.Map["request"] {
+		case "child":
+			nodes, ok := d.children[path]
+			if !ok && !strings.Contains(path, ",") {
+				w.Trap(server.NoCategory, "no such item")
+				break
+			}
+			for i := 0; i < len(nodes); i += 2 {
+				w.Re(proto.Pair{Key: "type", Value: "child"}, proto.Pair{Key: "name", Value: nodes[i]}, proto.Pair{Key: "node-type", Value: nodes[i+1]})
+			}
+		case "syntax":
+			if s, ok := d.syntax[path]; ok {
+				for _, def := range strings.Split(s, " | ") {
+					w.Re(proto.Pair{Key: "type", Value: "syntax"}, proto.Pair{Key: "symbol", Value: "Value"},
+						proto.Pair{Key: "symbol-type", Value: "definition"}, proto.Pair{Key: "text", Value: def})
+				}
+			}
+			e := d.explanations[path]
+			for i := 0; i < len(e); i += 2 {
+				w.Re(proto.Pair{Key: "type", Value: "syntax"}, proto.Pair{Key: "symbol", Value: e[i]},
+					proto.Pair{Key: "symbol-type", Value: "explanation"}, proto.Pair{Key: "text", Value: e[i+1]})
+			}
+		}
+	default:
+		w.Trap(server.NoCategory, "no such command prefix")
+	}
+	w.Done()
+}
+
+var testDevice = &device{
+	children: map[string][]string{
+		"":                        {"interface", "dir", "system", "dir", "ping", "cmd"},
+		"interface":               {"bridge", "dir"},
+		"interface,bridge":        {"add", "cmd", "print", "cmd", "set", "cmd", "remove", "cmd"},
+		"interface,bridge,add":    {"name", "arg", "protocol-mode", "arg", "ageing-time", "arg", "mtu", "arg", "disabled", "arg", "copy-from", "arg"},
+		"interface,bridge,set":    {"numbers", "arg", "name", "arg", "comment", "arg"},
+		"interface,bridge,print":  {"where", "arg"},
+		"interface,bridge,remove": {"numbers", "arg"},
+		"system":                  {"identity", "dir"},
+		"system,identity":         {"print", "cmd", "set", "cmd"},
+		"system,identity,set":     {"name", "arg"},
+		"ping":                    {"address", "arg"},
+	},
+	syntax: map[string]string{
+		"interface,bridge,add,name":          "string value, max length 15",
+		"interface,bridge,add,protocol-mode": "mstp | none | rstp | stp",
+		"interface,bridge,add,ageing-time":   "time interval",
+		"interface,bridge,add,mtu":           "68..65535 | auto",
+		"interface,bridge,add,disabled":      "yes | no",
+		"interface,bridge,add,copy-from":     "Item number",
+		"interface,bridge,set,numbers":       "Item number",
+		"interface,bridge,set,name":          "string value, max length 15",
+		"interface,bridge,set,comment":       "string value",
+		"system,identity,set,name":           "string value",
+		"ping,address":                       "IP address",
+	},
+	explanations: map[string][]string{
+		"interface,bridge,add": {"name", "Name of the bridge", "mtu", "Maximum transmission unit"},
+	},
+}
+
+func TestDiscover(t *testing.T) {
+	c := servertest.Dial(t, &server.Server{Handler: testDevice}, routeros.WithAsync(), routeros.WithTimeout(time.Second))
+	s, err := discover(c, "/", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != SchemaVersion || s.RouterOS != "7.15.3 (stable)" {
+		t.Errorf("version=%d, RouterOS=%q", s.Version, s.RouterOS)
+	}
+	var paths []string
+	for _, m := range s.Menus {
+		paths = append(paths, m.Path)
+	}
+	if !slices.Equal(paths, []string{"/", "/interface/bridge", "/system/identity"}) {
+		t.Fatalf("menus=%q", paths)
+	}
+
+	add := s.Menus[1].Command("add")
+	if add == nil || len(add.Args) != 6 || add.Args[0].Name != "ageing-time" {
+		t.Fatalf("add=%+v", add)
+	}
+	for _, tc := range []struct {
+		name, typ, description string
+		values                 []string
+	}{
+		{"name", "string", "Name of the bridge", nil},
+		{"protocol-mode", "enum", "", []string{"mstp", "none", "rstp", "stp"}},
+		{"ageing-time", "duration", "", nil},
+		{"mtu", "string", "Maximum transmission unit", nil},
+		{"disabled", "bool", "", nil},
+	} {
+		a := add.Arg(tc.name)
+		if a == nil || a.Type != tc.typ || a.Description != tc.description || !slices.Equal(a.Values, tc.values) {
+			t.Errorf("%s=%+v", tc.name, a)
+		}
+	}
+	if a := add.Arg("mtu"); a == nil || a.Syntax != "68..65535 | auto" {
+		t.Errorf("mtu=%+v", a)
+	}
+	if c := s.Menus[1].Command("print"); c == nil || len(c.Args) != 1 {
+		t.Errorf("print=%+v", c)
+	}
+
+	s, err = discover(c, "/system", 4)
+	if err != nil || len(s.Menus) != 1 || s.Menus[0].Path != "/system/identity" {
+		t.Errorf("discover(/system)=%+v, %v", s, err)
+	}
+	_, err = discover(c, "/bogus", 4)
+	if err == nil {
+		t.Error("discover of a missing menu succeeded")
+	}
+}
+
+func TestInferType(t *testing.T) {
+	for _, tc := range []struct {
+		syntax string
+		typ    string
+		values []string
+	}{
+		{"yes | no", "bool", nil},
+		{"ip | ipv6 | bridge", "enum", []string{"ip", "ipv6", "bridge"}},
+		{"0..4294967295", "int", nil},
+		{"68..65535 | auto", "string", nil},
+		{"integer number", "int", nil},
+		{"time interval", "duration", nil},
+		{"string value", "string", nil},
+		{"IP address | IPv6 prefix", "string", nil},
+		{"", "string", nil},
+	} {
+		typ, values := inferType(tc.syntax)
+		if typ != tc.typ || !slices.Equal(values, tc.values) {
+			t.Errorf("inferType(%q)=%s, %q; want %s, %q", tc.syntax, typ, values, tc.typ, tc.values)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	s, err := discover(servertest.Dial(t, &server.Server{Handler: testDevice}, routeros.WithAsync(), routeros.WithTimeout(time.Second)), "/", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate(s, "ros")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"package ros\n",
+		"type InterfaceBridge struct {\n" +
+			"\tID           string                      `routeros:\".id\"`\n" +
+			"\tAgeingTime   time.Duration               `routeros:\"ageing-time\"`\n" +
+			"\tComment      string                      `routeros:\"comment\"`\n" +
+			"\tDisabled     bool                        `routeros:\"disabled\"`\n" +
+			"\tMTU          string                      `routeros:\"mtu\"`\n" +
+			"\tName         string                      `routeros:\"name\"`\n" +
+			"\tProtocolMode InterfaceBridgeProtocolMode `routeros:\"protocol-mode\"`\n" +
+			"}\n",
+		"\tInterfaceBridgeProtocolModeNone InterfaceBridgeProtocolMode = \"none\"\n",
+		"func (m *Menus) InterfaceBridge() *InterfaceBridgeMenu {",
+		"func (m *InterfaceBridgeMenu) Print(ctx context.Context, args ...string) ([]InterfaceBridge, error) {\n" +
+			"\treturn routeros.PrintTyped[InterfaceBridge](ctx, m.r, \"/interface/bridge\", args...)\n",
+		"func (m *InterfaceBridgeMenu) Add(attrs map[string]string) (string, error) {",
+		"func (m *InterfaceBridgeMenu) Set(id string, attrs map[string]string) error {",
+		"func (m *InterfaceBridgeMenu) Remove(ids ...string) error {",
+		"type SystemIdentity struct {\n\tName string `routeros:\"name\"`\n}\n",
+		"func (m *SystemIdentityMenu) Set(attrs map[string]string) error {\n" +
+			"\t_, err := run(m.r, \"/system/identity/set\", attrs)\n",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated code lacks\n%s", want)
+		}
+	}
+	if strings.Contains(string(src), "SystemIdentityMenu) Add") || strings.Contains(string(src), "CopyFrom") {
+		t.Error("generated code has methods or fields of missing arguments")
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/swoga/go-routeros"
+)
+
+// walker discovers the menu tree with /console/inspect. Menus are walked
+// concurrently, limited by sem.
+type walker struct {
+	r   routeros.Runner
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	mu    sync.Mutex
+	menus []*Menu
+	err   error
+}
+
+// walk returns the menus below root, e.g. [ip], with parallel requests in
+// flight at most.
+func walk(r routeros.Runner, root []string, parallel int) ([]*Menu, error) {
+	w := &walker{r: r, sem: make(chan struct{}, max(parallel, 1))}
+	w.wg.Add(1)
+	go w.menu(root)
+	w.wg.Wait()
+	if w.err != nil {
+		return nil, w.err
+	}
+	slices.SortFunc(w.menus, func(a, b *Menu) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return w.menus, nil
+}
+
+func (w *walker) fail(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *walker) failed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err != nil
+}
+
+// inspect runs the request for path. A path the device refuses to inspect
+// yields no rows.
+func (w *walker) inspect(request string, path []string) ([]map[string]string, error) {
+	w.sem <- struct{}{}
+	defer func() { <-w.sem }()
+	reply, err := w.r.Run("/console/inspect", "=request="+request, "=path="+strings.Join(path, ","))
+	var devErr *routeros.DeviceError
+	if errors.As(err, &devErr) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]map[string]string, len(reply.Re))
+	for i, sen := range reply.Re {
+		rows[i] = sen.Map
+	}
+	return rows, nil
+}
+
+// children returns the names of the children of path by node type.
+func (w *walker) children(path []string) (map[string][]string, error) {
+	rows, err := w.inspect("child", path)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string][]string)
+	for _, row := range rows {
+		if row["type"] == "child" {
+			res[row["node-type"]] = append(res[row["node-type"]], row["name"])
+		}
+	}
+	return res, nil
+}
+
+func (w *walker) menu(path []string) {
+	defer w.wg.Done()
+	if w.failed() {
+		return
+	}
+	children, err := w.children(path)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+	for _, name := range children["dir"] {
+		w.wg.Add(1)
+		go w.menu(append(slices.Clip(path), name))
+	}
+	if len(children["cmd"]) == 0 {
+		return
+	}
+
+	m := &Menu{Path: "/" + strings.Join(path, "/")}
+	for _, name := range children["cmd"] {
+		cmd, err := w.command(append(slices.Clip(path), name))
+		if err != nil {
+			w.fail(err)
+			return
+		}
+		m.Commands = append(m.Commands, cmd)
+	}
+	slices.SortFunc(m.Commands, func(a, b *Command) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	w.mu.Lock()
+	w.menus = append(w.menus, m)
+	w.mu.Unlock()
+}
+
+// command returns the command at path with its arguments.
+func (w *walker) command(path []string) (*Command, error) {
+	cmd := &Command{Name: path[len(path)-1]}
+	children, err := w.children(path)
+	if err != nil || len(children["arg"]) == 0 {
+		return cmd, err
+	}
+	// the syntax of the command explains the arguments
+	rows, err := w.inspect("syntax", path)
+	if err != nil {
+		return nil, err
+	}
+	descriptions := make(map[string]string)
+	for _, row := range rows {
+		if row["symbol-type"] == "explanation" && row["symbol"] != "" {
+			descriptions[row["symbol"]] = row["text"]
+		}
+	}
+	for _, name := range children["arg"] {
+		syntax, err := w.syntax(append(slices.Clip(path), name))
+		if err != nil {
+			return nil, err
+		}
+		typ, values := inferType(syntax)
+		cmd.Args = append(cmd.Args, &Arg{
+			Name:        name,
+			Type:        typ,
+			Values:      values,
+			Syntax:      syntax,
+			Description: descriptions[name],
+		})
+	}
+	slices.SortFunc(cmd.Args, func(a, b *Arg) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return cmd, nil
+}
+
+// syntax returns the value syntax of the argument at path: its definitions,
+// or the first text if there are none.
+func (w *walker) syntax(path []string) (string, error) {
+	rows, err := w.inspect("syntax", path)
+	if err != nil {
+		return "", err
+	}
+	var defs []string
+	first := ""
+	for _, row := range rows {
+		text := strings.TrimSpace(row["text"])
+		if text == "" {
+			continue
+		}
+		if row["symbol-type"] == "definition" {
+			defs = append(defs, text)
+		}
+		if first == "" {
+			first = text
+		}
+	}
+	if len(defs) > 0 {
+		return strings.Join(defs, " | "), nil
+	}
+	return first, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/cli"
+)
+
+// node is a menu, command or argument reported by /console/inspect.
+type node struct {
+	name string
+	typ  string // dir, cmd or arg
+}
+
+// completer discovers the menu tree with /console/inspect, which RouterOS 6
+// lacks. Without it, only cli.Commands are known as commands and nothing is
+// completed.
+type completer struct {
+	r           routeros.Runner
+	unsupported bool
+	cache       map[string][]node
+}
+
+func newCompleter(r routeros.Runner) *completer {
+	return &completer{r: r, cache: make(map[string][]node)}
+}
+
+// children returns the nodes below path.
+func (c *completer) children(path []string) []node {
+	if c.unsupported {
+		return nil
+	}
+	key := strings.Join(path, ",")
+	if nodes, ok := c.cache[key]; ok {
+		return nodes
+	}
+	reply, err := c.r.Run("/console/inspect", "=request=child", "=path="+key)
+	var devErr *routeros.DeviceError
+	if errors.As(err, &devErr) {
+		// either path does not exist or the device cannot inspect at all
+		if key == "" {
+			c.unsupported = true
+		} else {
+			c.cache[key] = nil
+			c.children(nil)
+		}
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+	var nodes []node
+	for _, sen := range reply.Re {
+		if sen.Map["type"] == "child" {
+			nodes = append(nodes, node{name: sen.Map["name"], typ: sen.Map["node-type"]})
+		}
+	}
+	c.cache[key] = nodes
+	return nodes
+}
+
+// isCommand reports whether name is a command of menu. Names the device
+// does not know are looked up in cli.Commands.
+func (c *completer) isCommand(menu []string, name string) bool {
+	for _, n := range c.children(menu) {
+		if n.name == name {
+			return n.typ == "cmd"
+		}
+	}
+	return slices.Contains(cli.Commands, name)
+}
+
+// complete returns the candidates for the last, possibly empty, word of
+// head, which is the input before the cursor in the menu cur. Menus and
+// commands are completed before the command, argument names after it.
+func (c *completer) complete(cur []string, head string) []string {
+	i := strings.LastIndexAny(head, " \t") + 1
+	rest, partial := head[:i], head[i:]
+	if strings.ContainsAny(partial, `"\`) {
+		return nil
+	}
+	// menus can be completed after a slash, e.g. /ip/a
+	prefix := partial[:strings.LastIndex(partial, "/")+1]
+	p := &cli.Parser{IsCommand: c.isCommand}
+	l, err := p.Parse(cur, rest+prefix)
+	if err != nil {
+		return nil
+	}
+
+	var res []string
+	if l.Sentence == nil {
+		for _, n := range c.children(l.Menu) {
+			if n.typ != "arg" && strings.HasPrefix(n.name, partial[len(prefix):]) {
+				res = append(res, prefix+n.name)
+			}
+		}
+		return res
+	}
+	query := slices.ContainsFunc(l.Sentence, func(w string) bool {
+		return strings.HasPrefix(w, "?")
+	})
+	if prefix != "" || query || strings.Contains(partial, "=") {
+		return nil
+	}
+	for _, n := range c.children(strings.Split(l.Sentence[0][1:], "/")) {
+		if n.typ == "arg" && strings.HasPrefix(n.name, partial) {
+			res = append(res, n.name+"=")
+		}
+	}
+	return res
+}
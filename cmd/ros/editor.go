@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// maxHistory limits the lines kept in the history file.
+const maxHistory = 1000
+
+// editor reads input lines. On a terminal it supports cursor movement,
+// history and completion, otherwise it reads plain lines.
+type editor struct {
+	in  *bufio.Reader
+	out io.Writer
+	// fd is the file descriptor of the terminal, -1 without one.
+	fd int
+	// complete returns the candidates for the last word of the input
+	// before the cursor.
+	complete func(head string) []string
+
+	history  []string
+	histFile string
+}
+
+func newEditor(in *os.File, out io.Writer, complete func(string) []string) *editor {
+	e := &editor{in: bufio.NewReader(in), out: out, fd: -1, complete: complete}
+	if isTerminal(int(in.Fd())) {
+		e.fd = int(in.Fd())
+	}
+	return e
+}
+
+// loadHistory reads the history from name and appends new lines to it.
+func (e *editor) loadHistory(name string) {
+	e.histFile = name
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+		os.WriteFile(name, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+	}
+	e.history = lines
+}
+
+func (e *editor) addHistory(s string) {
+	if s == "" || len(e.history) > 0 && e.history[len(e.history)-1] == s {
+		return
+	}
+	e.history = append(e.history, s)
+	if e.histFile == "" {
+		return
+	}
+	f, err := os.OpenFile(e.histFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, s)
+	f.Close()
+}
+
+// readLine reads a line. It returns io.EOF at the end of the input or on
+// Ctrl-D on an empty line.
+func (e *editor) readLine(prompt string) (string, error) {
+	if e.fd < 0 {
+		s, err := e.in.ReadString('\n')
+		if err == io.EOF && s != "" {
+			err = nil
+		}
+		return strings.TrimRight(s, "\r\n"), err
+	}
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	return e.edit(prompt)
+}
+
+// edit reads a line from a terminal in raw mode.
+func (e *editor) edit(prompt string) (string, error) {
+	var (
+		buf  []rune
+		pos  int
+		hist = len(e.history)
+		// saved is the line being edited while browsing the history
+		saved []rune
+	)
+	redraw := func() {
+		s := "\r" + prompt + string(buf) + "\x1b[K"
+		if n := len(buf) - pos; n > 0 {
+			s += fmt.Sprintf("\x1b[%dD", n)
+		}
+		io.WriteString(e.out, s)
+	}
+	insert := func(s string) {
+		r := []rune(s)
+		buf = append(buf[:pos], append(r, buf[pos:]...)...)
+		pos += len(r)
+	}
+	setLine := func(r []rune) {
+		buf = append([]rune(nil), r...)
+		pos = len(buf)
+	}
+	redraw()
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			io.WriteString(e.out, "\r\n")
+			return string(buf), nil
+		case 3: // Ctrl-C discards the line
+			io.WriteString(e.out, "^C\r\n")
+			buf, pos = buf[:0], 0
+		case 4: // Ctrl-D
+			if len(buf) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+		case 127, 8:
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(buf)
+		case 2: // Ctrl-B
+			pos = max(pos-1, 0)
+		case 6: // Ctrl-F
+			pos = min(pos+1, len(buf))
+		case 11: // Ctrl-K
+			buf = buf[:pos]
+		case 21: // Ctrl-U
+			buf = append(buf[:0], buf[pos:]...)
+			pos = 0
+		case 23: // Ctrl-W
+			i := pos
+			for i > 0 && buf[i-1] == ' ' {
+				i--
+			}
+			for i > 0 && buf[i-1] != ' ' {
+				i--
+			}
+			buf = append(buf[:i], buf[pos:]...)
+			pos = i
+		case 12: // Ctrl-L
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case '\t':
+			e.tab(string(buf[:pos]), insert)
+		case 27:
+			key := e.escape()
+			switch key {
+			case 'A', 'B':
+				if key == 'A' && hist > 0 {
+					if hist == len(e.history) {
+						saved = append([]rune(nil), buf...)
+					}
+					hist--
+					setLine([]rune(e.history[hist]))
+				} else if key == 'B' && hist < len(e.history) {
+					hist++
+					if hist == len(e.history) {
+						setLine(saved)
+					} else {
+						setLine([]rune(e.history[hist]))
+					}
+				}
+			case 'C':
+				pos = min(pos+1, len(buf))
+			case 'D':
+				pos = max(pos-1, 0)
+			case 'H':
+				pos = 0
+			case 'F':
+				pos = len(buf)
+			case '3':
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+				}
+			}
+		default:
+			if r >= ' ' {
+				insert(string(r))
+			}
+		}
+		redraw()
+	}
+}
+
+// escape reads the rest of an escape sequence and returns its final
+// character. The sequence for the delete key, ESC [ 3 ~, yields '3'.
+func (e *editor) escape() rune {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != '[' && r != 'O' {
+		return 0
+	}
+	r, _, err = e.in.ReadRune()
+	if err != nil {
+		return 0
+	}
+	for r >= '0' && r <= '9' {
+		next, _, err := e.in.ReadRune()
+		if err != nil {
+			return 0
+		}
+		if next == '~' {
+			return r
+		}
+		r = next
+	}
+	return r
+}
+
+// tab completes the last word of head. A single candidate replaces the
+// word, several are extended to their common prefix or listed.
+func (e *editor) tab(head string, insert func(string)) {
+	if e.complete == nil {
+		return
+	}
+	cands := e.complete(head)
+	if len(cands) == 0 {
+		return
+	}
+	word := head[strings.LastIndexAny(head, " ")+1:]
+	if len(cands) == 1 {
+		s := cands[0][min(len(word), len(cands[0])):]
+		if !strings.HasSuffix(cands[0], "=") {
+			s += " "
+		}
+		insert(s)
+		return
+	}
+	prefix := cands[0]
+	for _, c := range cands[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(prefix) > len(word) {
+		insert(prefix[len(word):])
+		return
+	}
+	io.WriteString(e.out, "\r\n"+strings.Join(cands, "  ")+"\r\n")
+}
@@ -0,0 +1,187 @@
+/*
+Command ros is an interactive shell for RouterOS devices over the API.
+
+Usage:
+
+	ros [flags] [command]
+
+Lines are either sentences of API words or use the syntax of the RouterOS
+console, see package cli:
+
+	[admin@router1] /> /ip/address/print ?interface=ether1
+	[admin@router1] /> /ip address
+	[admin@router1] /ip address> print where interface=ether1
+	[admin@router1] /ip address> set *1 comment="uplink 1"
+	[admin@router1] /ip address> .. route print
+
+Replies are printed as table, or with -json and -csv in those formats. The
+rows of listens, of print with follow and of monitor-like commands are
+printed as they arrive until Ctrl-C cancels the command.
+
+On RouterOS 7 the menu tree is discovered with /console/inspect and Tab
+completes menus, commands and argument names. The history is kept in
+~/.ros_history.
+
+Connection settings can be saved with -save-profile and used with -profile;
+flags given along with -profile override its settings. The self-signed
+certificate of a device is accepted with -fingerprint, see
+routeros.Fingerprint. Profiles are stored in
+ros/profiles.json in the user's configuration directory.
+
+A command given after the flags is run instead of the shell.
+*/
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/swoga/go-routeros"
+)
+
+var (
+	address     = flag.String("address", "127.0.0.1:8728", "RouterOS address and port")
+	username    = flag.String("username", "admin", "User name")
+	password    = flag.String("password", "", "Password")
+	useTLS      = flag.Bool("tls", false, "Use TLS")
+	fingerprint = flag.String("fingerprint", "", "Accept only a device certificate with this public key fingerprint (SHA256:...), implies -tls")
+	timeout     = flag.Duration("timeout", 10*time.Second, "Timeout of connecting and of single API calls")
+	jsonOutput  = flag.Bool("json", false, "Print replies as JSON")
+	csvOutput   = flag.Bool("csv", false, "Print replies as CSV")
+	profileName = flag.String("profile", "", "Use the saved connection profile")
+	saveAs      = flag.String("save-profile", "", "Save the connection settings as profile")
+)
+
+// settings returns the connection settings from the profile and the flags.
+func settings(file string) (profile, error) {
+	p := profile{Address: *address, Username: *username, Password: *password, TLS: *useTLS, Fingerprint: *fingerprint}
+	if *profileName == "" {
+		return p, nil
+	}
+	profiles, err := loadProfiles(file)
+	if err != nil {
+		return p, err
+	}
+	saved, ok := profiles[*profileName]
+	if !ok {
+		return p, fmt.Errorf("no profile %q in %s", *profileName, file)
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "address":
+			saved.Address = p.Address
+		case "username":
+			saved.Username = p.Username
+		case "password":
+			saved.Password = p.Password
+		case "tls":
+			saved.TLS = p.TLS
+		case "fingerprint":
+			saved.Fingerprint = p.Fingerprint
+		}
+	})
+	return saved, nil
+}
+
+func main() {
+	flag.Parse()
+	log.SetFlags(0)
+
+	file, err := profilesFile()
+	if err != nil && (*profileName != "" || *saveAs != "") {
+		log.Fatal(err)
+	}
+	p, err := settings(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *saveAs != "" {
+		err = saveProfile(file, *saveAs, p)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	opts := []routeros.Option{
+		routeros.WithCredentials(p.Username, p.Password),
+		routeros.WithTimeout(*timeout),
+		routeros.WithAsync(),
+	}
+	if p.TLS {
+		opts = append(opts, routeros.WithTLS(nil))
+	}
+	if p.Fingerprint != "" {
+		opts = append(opts, routeros.WithPinnedFingerprints(p.Fingerprint))
+	}
+	c, err := routeros.Connect(context.Background(), p.Address, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+
+	format := "table"
+	if *jsonOutput {
+		format = "json"
+	} else if *csvOutput {
+		format = "csv"
+	}
+	r := newREPL(c, os.Stdout, format)
+
+	if flag.NArg() > 0 {
+		err = execute(r, strings.Join(flag.Args(), " "))
+		if err != nil {
+			c.Close()
+			log.Fatal(err)
+		}
+		return
+	}
+
+	identity := p.Address
+	reply, err := c.Run("/system/identity/print")
+	if err == nil && len(reply.Re) > 0 {
+		identity = reply.Re[0].Map["name"]
+	}
+
+	e := newEditor(os.Stdin, os.Stdout, r.complete)
+	if home, err := os.UserHomeDir(); err == nil {
+		e.loadHistory(filepath.Join(home, ".ros_history"))
+	}
+	for {
+		line, err := e.readLine(r.prompt(p.Username, identity))
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "quit" || line == "exit" {
+			return
+		}
+		e.addHistory(line)
+		err = execute(r, line)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failure:", err)
+		}
+		select {
+		case <-c.Done():
+			log.Fatal("connection closed: ", c.Err())
+		default:
+		}
+	}
+}
+
+// execute runs line until it completes or Ctrl-C is pressed.
+func execute(r *repl, line string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return r.execute(ctx, line)
+}
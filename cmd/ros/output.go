@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/swoga/go-routeros/cli"
+	"github.com/swoga/go-routeros/proto"
+)
+
+// maxWidth limits the width of table cells.
+const maxWidth = 40
+
+// printer writes reply sentences as table, JSON or CSV. Complete replies are
+// written with rows, streamed ones sentence by sentence with row.
+type printer struct {
+	w      io.Writer
+	format string
+
+	// header of streamed CSV, taken from the first sentence
+	header []string
+	csv    *csv.Writer
+}
+
+func newPrinter(w io.Writer, format string) *printer {
+	return &printer{w: w, format: format}
+}
+
+// columns returns the keys of sens in order of appearance, .id first.
+func columns(sens []*proto.Sentence) []string {
+	var cols []string
+	seen := make(map[string]bool)
+	for _, sen := range sens {
+		for _, p := range sen.List {
+			if !seen[p.Key] {
+				seen[p.Key] = true
+				cols = append(cols, p.Key)
+			}
+		}
+	}
+	if seen[".id"] {
+		for i, c := range cols {
+			if c == ".id" {
+				copy(cols[1:i+1], cols[:i])
+				cols[0] = ".id"
+				break
+			}
+		}
+	}
+	return cols
+}
+
+func (p *printer) rows(sens []*proto.Sentence) error {
+	switch p.format {
+	case "json":
+		res := make([]map[string]string, len(sens))
+		for i, sen := range sens {
+			res[i] = sen.Map
+		}
+		b, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(p.w, "%s\n", b)
+		return err
+	case "csv":
+		if len(sens) == 0 {
+			return nil
+		}
+		cols := columns(sens)
+		w := csv.NewWriter(p.w)
+		w.Write(cols)
+		for _, sen := range sens {
+			w.Write(values(sen, cols))
+		}
+		w.Flush()
+		return w.Error()
+	}
+	if len(sens) == 0 {
+		return nil
+	}
+	cols := columns(sens)
+	cells := [][]string{cols}
+	for _, sen := range sens {
+		row := values(sen, cols)
+		for i, v := range row {
+			v = strings.ReplaceAll(v, "\n", " ")
+			if utf8.RuneCountInString(v) > maxWidth {
+				v = string([]rune(v)[:maxWidth-3]) + "..."
+			}
+			row[i] = v
+		}
+		cells = append(cells, row)
+	}
+	widths := make([]int, len(cols))
+	for _, row := range cells {
+		for i, v := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(v))
+		}
+	}
+	var b strings.Builder
+	for _, row := range cells {
+		for i, v := range row {
+			if i == len(row)-1 {
+				b.WriteString(v)
+				break
+			}
+			b.WriteString(v + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(v)+2))
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(p.w, b.String())
+	return err
+}
+
+// row writes one sentence of a streamed reply.
+func (p *printer) row(sen *proto.Sentence) error {
+	switch p.format {
+	case "json":
+		b, err := json.Marshal(sen.Map)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(p.w, "%s\n", b)
+		return err
+	case "csv":
+		if p.csv == nil {
+			p.csv = csv.NewWriter(p.w)
+			p.header = columns([]*proto.Sentence{sen})
+			p.csv.Write(p.header)
+		}
+		p.csv.Write(values(sen, p.header))
+		p.csv.Flush()
+		return p.csv.Error()
+	}
+	words := make([]string, len(sen.List))
+	for i, pair := range sen.List {
+		words[i] = pair.Key + "=" + cli.Quote(pair.Value)
+	}
+	_, err := fmt.Fprintln(p.w, strings.Join(words, " "))
+	return err
+}
+
+func values(sen *proto.Sentence, cols []string) []string {
+	row := make([]string, len(cols))
+	for i, c := range cols {
+		row[i] = sen.Map[c]
+	}
+	return row
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// profile holds the settings of a saved connection.
+type profile struct {
+	Address  string `json:"address"`
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+	TLS      bool   `json:"tls,omitempty"`
+	// Fingerprint pins the public key of the device certificate, see
+	// routeros.Fingerprint.
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// profilesFile returns the name of the file with the saved profiles.
+func profilesFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ros", "profiles.json"), nil
+}
+
+func loadProfiles(name string) (map[string]profile, error) {
+	profiles := make(map[string]profile)
+	b, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return profiles, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &profiles)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return profiles, nil
+}
+
+// saveProfile adds or replaces the profile with the given name. The file is
+// only readable by the user as it may contain passwords.
+func saveProfile(file, name string, p profile) error {
+	profiles, err := loadProfiles(file)
+	if err != nil {
+		return err
+	}
+	profiles[name] = p
+	b, err := json.MarshalIndent(profiles, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0700)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(b, '\n'), 0600)
+}
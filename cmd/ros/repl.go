@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/cli"
+	"github.com/swoga/go-routeros/proto"
+)
+
+// repl runs input lines on a client.
+type repl struct {
+	c    *routeros.Client
+	comp *completer
+	out  io.Writer
+	// format is table, json or csv.
+	format string
+	// menu is the current menu, e.g. [ip address].
+	menu []string
+}
+
+func newREPL(c *routeros.Client, out io.Writer, format string) *repl {
+	return &repl{c: c, comp: newCompleter(c), out: out, format: format}
+}
+
+// prompt returns the prompt for the current menu in the style of the
+// RouterOS console.
+func (r *repl) prompt(user, identity string) string {
+	return "[" + user + "@" + identity + "] /" + strings.Join(r.menu, " ") + "> "
+}
+
+func (r *repl) complete(head string) []string {
+	return r.comp.complete(r.menu, head)
+}
+
+// execute runs the input line s, which is either a sentence of API words,
+// e.g.
+//
+//	/ip/address/add =address=10.0.0.1/24 "=comment=uplink 1"
+//
+// or uses the syntax of the RouterOS console, see package cli. Cancelling
+// ctx cancels the command.
+func (r *repl) execute(ctx context.Context, s string) error {
+	words, err := cli.Split(s)
+	if err != nil || len(words) == 0 {
+		return err
+	}
+	if isSentence(words) {
+		return r.run(ctx, words)
+	}
+	p := &cli.Parser{IsCommand: r.comp.isCommand}
+	l, err := p.Parse(r.menu, s)
+	if err != nil {
+		return err
+	}
+	if l.Sentence == nil {
+		r.menu = l.Menu
+		return nil
+	}
+	return r.run(ctx, l.Sentence)
+}
+
+// isSentence reports whether words are API words: a command path and
+// attribute, query or .tag words, at least one of them an attribute or
+// query. Other lines, e.g. /ip/address/print or the menu change /ip/address,
+// are left to the console syntax, which takes slashes in paths as well.
+func isSentence(words []string) bool {
+	if !strings.HasPrefix(words[0], "/") || !strings.Contains(words[0][1:], "/") {
+		return false
+	}
+	api := false
+	for _, w := range words[1:] {
+		switch {
+		case strings.HasPrefix(w, "="), strings.HasPrefix(w, "?"):
+			api = true
+		case !strings.HasPrefix(w, ".tag="):
+			return false
+		}
+	}
+	return api
+}
+
+// run runs the sentence words. Every command is run as listen, which allows
+// to cancel it. The rows of commands that run until cancelled are written
+// as they arrive.
+func (r *repl) run(ctx context.Context, words []string) error {
+	listen, err := r.c.ListenArgs(words)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		listen.Cancel()
+	})
+	defer stop()
+
+	p := newPrinter(r.out, r.format)
+	stream := streaming(words)
+	var rows []*proto.Sentence
+	for sen := range listen.Chan() {
+		if stream {
+			err = p.row(sen)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		rows = append(rows, sen)
+	}
+	err = listen.Err()
+	if err != nil {
+		return err
+	}
+	if stream {
+		return nil
+	}
+	// commands like add reply with the result in !done
+	if len(rows) == 0 && listen.Done != nil && len(listen.Done.List) > 0 {
+		rows = append(rows, listen.Done)
+	}
+	return p.rows(rows)
+}
+
+// streaming reports whether the command of words runs until cancelled.
+func streaming(words []string) bool {
+	has := func(arg string) bool {
+		return slices.ContainsFunc(words[1:], func(w string) bool {
+			return w == "="+arg+"=" || strings.HasPrefix(w, "="+arg+"=")
+		})
+	}
+	if has("follow") || has("follow-only") || has("interval") {
+		return true
+	}
+	switch path.Base(words[0]) {
+	case "listen":
+		return true
+	case "monitor", "monitor-traffic", "torch":
+		return !has("once") && !has("duration")
+	case "ping":
+		return !has("count")
+	}
+	return false
+}
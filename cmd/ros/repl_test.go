@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/cli"
+	"github.com/swoga/go-routeros/proto"
+	"github.com/swoga/go-routeros/server"
+	"github.com/swoga/go-routeros/server/servertest"
+	"github.com/swoga/go-routeros/sim"
+)
+
+// syncBuffer is a strings.Builder safe for concurrent use.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+func (b *syncBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.b.Reset()
+}
+
+func TestREPL(t *testing.T) {
+	d := sim.New()
+	c := servertest.Dial(t, d, routeros.WithAsync(), routeros.WithTimeout(time.Second))
+	out := &syncBuffer{}
+	r := newREPL(c, out, "table")
+	exec := func(line, want string) {
+		t.Helper()
+		out.Reset()
+		err := r.execute(context.Background(), line)
+		if err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		if out.String() != want {
+			t.Errorf("%s:\n%s\nwant:\n%s", line, out, want)
+		}
+	}
+
+	exec("/ip address", "")
+	if p := r.prompt("admin", "sim"); p != "[admin@sim] /ip address> " {
+		t.Errorf("prompt=%q", p)
+	}
+	exec("add address=10.0.0.1/24 interface=ether1", "ret\n*1\n")
+	exec(`/ip/address/add =address=10.0.1.1/24 =interface=ether2 "=comment=lab net"`, "ret\n*2\n")
+	exec("print .proplist=.id,address,interface",
+		".id  address      interface\n"+
+			"*1   10.0.0.1/24  ether1\n"+
+			"*2   10.0.1.1/24  ether2\n")
+	exec("print .proplist=address,comment where interface=ether2",
+		"address      comment\n"+
+			"10.0.1.1/24  lab net\n")
+
+	r.format = "json"
+	exec("print .proplist=address where interface=ether1", "[\n  {\n    \"address\": \"10.0.0.1/24\"\n  }\n]\n")
+	r.format = "csv"
+	exec("print .proplist=address,comment", "address,comment\n10.0.0.1/24,\n10.0.1.1/24,lab net\n")
+
+	err := r.execute(context.Background(), "/ip address add address=bogus interface=ether1")
+	if err == nil {
+		t.Error("add of an invalid address succeeded")
+	}
+	if strings.Join(r.menu, " ") != "ip address" {
+		t.Errorf("menu=%q", r.menu)
+	}
+
+	// a slash-separated path without command changes the menu
+	exec("/interface/ethernet", "")
+	if strings.Join(r.menu, " ") != "interface ethernet" {
+		t.Errorf("menu=%q; want interface ethernet", r.menu)
+	}
+	exec("/ip/address/print .proplist=address ?interface=ether1", "address\n10.0.0.1/24\n")
+}
+
+func TestREPLStream(t *testing.T) {
+	d := sim.New()
+	c := servertest.Dial(t, d, routeros.WithAsync(), routeros.WithTimeout(time.Second))
+	out := &syncBuffer{}
+	r := newREPL(c, out, "table")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errC := make(chan error, 1)
+	go func() {
+		errC <- r.execute(ctx, "/interface listen")
+	}()
+	for d.Listeners("/interface") == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	d.Set("/interface", "ether1", map[string]string{"comment": "uplink 1"})
+	for !strings.Contains(out.String(), `comment="uplink 1"`) {
+		select {
+		case err := <-errC:
+			t.Fatalf("listen ended: %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	cancel()
+	select {
+	case err := <-errC:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("listen not cancelled")
+	}
+	if d.Listeners("/interface") != 0 {
+		t.Error("listen still running on the device")
+	}
+}
+
+// inspect serves /console/inspect for a small menu tree.
+func inspect(w server.ResponseWriter, cmd *server.Command) {
+	tree := map[string][]node{
+		"":                 {{"interface", "dir"}, {"ip", "dir"}, {"ping", "cmd"}},
+		"ip":               {{"address", "dir"}, {"arp", "dir"}, {"route", "dir"}},
+		"ip,address":       {{"add", "cmd"}, {"print", "cmd"}, {"export", "cmd"}},
+		"ip,address,add":   {{"address", "arg"}, {"interface", "arg"}, {"comment", "arg"}},
+		"ip,address,print": {{"where", "arg"}},
+	}
+	if cmd.Word != "/console/inspect" {
+		w.Trap(server.NoCategory, "no such command prefix")
+		w.Done()
+		return
+	}
+	nodes, ok := tree[cmd.Map["path"]]
+	if !ok {
+		w.Trap(server.NoCategory, "no such item")
+		w.Done()
+		return
+	}
+	for _, n := range nodes {
+		w.Re(proto.Pair{Key: "type", Value: "child"}, proto.Pair{Key: "name", Value: n.name}, proto.Pair{Key: "node-type", Value: n.typ})
+	}
+	w.Done()
+}
+
+func TestComplete(t *testing.T) {
+	c := servertest.Dial(t, &server.Server{Handler: server.HandlerFunc(inspect)}, routeros.WithAsync(), routeros.WithTimeout(time.Second))
+	comp := newCompleter(c)
+	for _, tc := range []struct {
+		cur  string
+		head string
+		want []string
+	}{
+		{"", "/i", []string{"/interface", "/ip"}},
+		{"", "/ip a", []string{"address", "arp"}},
+		{"", "/ip address ", []string{"add", "print", "export"}},
+		{"", "/ip address add ", []string{"address=", "interface=", "comment="}},
+		{"", "/ip address add address=10.0.0.1/24 in", []string{"interface="}},
+		{"", "/ip address add comment=", nil},
+		{"ip address", "e", []string{"export"}},
+		{"ip address", ".. r", []string{"route"}},
+		{"ip", "bogus ", nil},
+		{"", "/ip/a", []string{"/ip/address", "/ip/arp"}},
+		{"ip address", "../r", []string{"../route"}},
+		{"", "/ip address print where ", nil},
+	} {
+		var cur []string
+		if tc.cur != "" {
+			cur = strings.Split(tc.cur, " ")
+		}
+		got := comp.complete(cur, tc.head)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("complete(%q, %q)=%q; want %q", tc.cur, tc.head, got, tc.want)
+		}
+	}
+	// the device knows more commands than cli.Commands
+	p := &cli.Parser{IsCommand: comp.isCommand}
+	l, err := p.Parse(nil, "/ip address export file=x")
+	if err != nil || !slices.Equal(l.Sentence, []string{"/ip/address/export", "=file=x"}) {
+		t.Errorf("Parse=%v, %v", l, err)
+	}
+	if comp.isCommand([]string{"ip"}, "route") {
+		t.Error("menu route taken as command")
+	}
+
+	// RouterOS 6 cannot inspect, cli.Commands are used
+	comp = newCompleter(servertest.Dial(t, sim.New(), routeros.WithAsync(), routeros.WithTimeout(time.Second)))
+	if got := comp.complete(nil, "/i"); got != nil {
+		t.Errorf("complete without inspect=%q", got)
+	}
+	if !comp.isCommand([]string{"ip", "address"}, "print") || comp.isCommand(nil, "ip") {
+		t.Error("cli.Commands not used without inspect")
+	}
+}
+
+func TestEditor(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+	}{
+		{"print\r", "print"},
+		{"prnt\x1b[D\x1b[Di\r", "print"},
+		{"print x\x7f\x7f\r", "print"},
+		{"abc\x01x\x05y\r", "xabcy"},
+		{"one two\x17three\r", "one three"},
+		{"abc\x1b[D\x1b[D\x1b[3~\r", "ac"},
+		{"junk\x03print\r", "print"},
+		{"/ip a\tpr\t\r", "/ip address print "},
+		{"/i\t\r", "/i"},
+		{"\x1b[A\x1b[A\r", "first"},
+		{"\x1b[A\x1b[A\x1b[B\r", "second"},
+		{"edit\x1b[A\x1b[B\r", "edit"},
+	} {
+		e := &editor{
+			in:      bufio.NewReader(strings.NewReader(tc.in)),
+			out:     io.Discard,
+			fd:      -1,
+			history: []string{"first", "second"},
+			complete: func(head string) []string {
+				switch head {
+				case "/ip a":
+					return []string{"address"}
+				case "/ip address pr":
+					return []string{"print"}
+				case "/i":
+					return []string{"/interface", "/ip"}
+				}
+				return nil
+			},
+		}
+		got, err := e.edit("> ")
+		if err != nil || got != tc.want {
+			t.Errorf("edit(%q)=%q, %v; want %q", tc.in, got, err, tc.want)
+		}
+	}
+
+	e := &editor{in: bufio.NewReader(strings.NewReader("\x04")), out: io.Discard, fd: -1}
+	_, err := e.edit("> ")
+	if err != io.EOF {
+		t.Errorf("Ctrl-D: %v; want EOF", err)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/swoga/go-routeros"
+)
+
+// collectAll runs all collectors on r. The exported metrics are:
+//
+//	routeros_system_info{version,board_name,architecture}
+//	routeros_system_uptime_seconds
+//	routeros_system_cpu_load_percent
+//	routeros_system_free_memory_bytes, routeros_system_total_memory_bytes
+//	routeros_system_temperature_celsius{sensor}
+//	routeros_interface_{rx,tx}_{bytes,packets,errors,drops}_total{interface,type}
+//	routeros_interface_running{interface,type}
+//	routeros_dhcp_leases{server,status}
+//	routeros_firewall_rule_{bytes,packets}_total{table,chain,id,action,comment}
+//	routeros_bgp_session_established{peer,remote_address,remote_as}
+//	routeros_bgp_session_prefixes{peer,remote_address,remote_as}
+//	routeros_wireless_clients{interface}
+func collectAll(r routeros.Runner, s *metricSet) error {
+	v, err := collectSystem(r, s)
+	if err != nil {
+		return err
+	}
+	for _, collect := range []func(routeros.Runner, *metricSet, routeros.Version) error{
+		collectHealth,
+		collectInterfaces,
+		collectDHCP,
+		collectFirewall,
+		collectBGP,
+		collectWireless,
+	} {
+		err = collect(r, s, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rows prints path with the given property list. A trap, e.g. for the menu
+// of a package that is not installed, yields no rows.
+func rows(r routeros.Runner, path, proplist string) ([]map[string]string, error) {
+	var args []string
+	if proplist != "" {
+		args = append(args, "=.proplist="+proplist)
+	}
+	reply, err := r.Print(path, args...)
+	var devErr *routeros.DeviceError
+	if errors.As(err, &devErr) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := make([]map[string]string, len(reply.Re))
+	for i, sen := range reply.Re {
+		res[i] = sen.Map
+	}
+	return res, nil
+}
+
+// number parses a numeric attribute, 0 if it is missing or invalid.
+func number(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+func boolean(s string) float64 {
+	if s == "true" || s == "yes" {
+		return 1
+	}
+	return 0
+}
+
+func collectSystem(r routeros.Runner, s *metricSet) (routeros.Version, error) {
+	res, err := rows(r, "/system/resource", "version,board-name,architecture-name,uptime,cpu-load,free-memory,total-memory")
+	if err != nil || len(res) == 0 {
+		return routeros.Version{}, err
+	}
+	m := res[0]
+	v, _ := routeros.ParseVersion(m["version"])
+	uptime, _ := routeros.ParseDuration(m["uptime"])
+	s.add("routeros_system_info", gauge, "Version and hardware of the device.", 1,
+		"version", m["version"], "board_name", m["board-name"], "architecture", m["architecture-name"])
+	s.add("routeros_system_uptime_seconds", gauge, "Time since the device booted.", uptime.Seconds())
+	s.add("routeros_system_cpu_load_percent", gauge, "CPU load.", number(m["cpu-load"]))
+	s.add("routeros_system_free_memory_bytes", gauge, "Free memory.", number(m["free-memory"]))
+	s.add("routeros_system_total_memory_bytes", gauge, "Total memory.", number(m["total-memory"]))
+	return v, nil
+}
+
+func collectHealth(r routeros.Runner, s *metricSet, _ routeros.Version) error {
+	res, err := rows(r, "/system/health", "")
+	if err != nil {
+		return err
+	}
+	const help = "Temperature reported by /system/health."
+	for _, m := range res {
+		// RouterOS 7 prints one row per sensor, RouterOS 6 one row with
+		// a property per sensor.
+		if name, ok := m["name"]; ok {
+			if m["type"] == "C" {
+				s.add("routeros_system_temperature_celsius", gauge, help, number(m["value"]), "sensor", name)
+			}
+			continue
+		}
+		for _, name := range slices.Sorted(maps.Keys(m)) {
+			if strings.HasSuffix(name, "temperature") {
+				s.add("routeros_system_temperature_celsius", gauge, help, number(m[name]), "sensor", name)
+			}
+		}
+	}
+	return nil
+}
+
+func collectInterfaces(r routeros.Runner, s *metricSet, _ routeros.Version) error {
+	res, err := rows(r, "/interface", "name,type,running,rx-byte,tx-byte,rx-packet,tx-packet,rx-error,tx-error,rx-drop,tx-drop")
+	if err != nil {
+		return err
+	}
+	for _, m := range res {
+		labels := []string{"interface", m["name"], "type", m["type"]}
+		for _, c := range []struct{ attr, name, help string }{
+			{"rx-byte", "rx_bytes", "Bytes received."},
+			{"tx-byte", "tx_bytes", "Bytes sent."},
+			{"rx-packet", "rx_packets", "Packets received."},
+			{"tx-packet", "tx_packets", "Packets sent."},
+			{"rx-error", "rx_errors", "Receive errors."},
+			{"tx-error", "tx_errors", "Send errors."},
+			{"rx-drop", "rx_drops", "Received packets dropped."},
+			{"tx-drop", "tx_drops", "Packets to send dropped."},
+		} {
+			if v, ok := m[c.attr]; ok {
+				s.add("routeros_interface_"+c.name+"_total", counter, c.help, number(v), labels...)
+			}
+		}
+		s.add("routeros_interface_running", gauge, "Whether the interface is running.", boolean(m["running"]), labels...)
+	}
+	return nil
+}
+
+func collectDHCP(r routeros.Runner, s *metricSet, _ routeros.Version) error {
+	res, err := rows(r, "/ip/dhcp-server/lease", "server,status")
+	if err != nil {
+		return err
+	}
+	type key struct{ server, status string }
+	var order []key
+	counts := make(map[key]int)
+	for _, m := range res {
+		k := key{m["server"], m["status"]}
+		if counts[k] == 0 {
+			order = append(order, k)
+		}
+		counts[k]++
+	}
+	for _, k := range order {
+		s.add("routeros_dhcp_leases", gauge, "DHCP leases by server and status.", float64(counts[k]), "server", k.server, "status", k.status)
+	}
+	return nil
+}
+
+func collectFirewall(r routeros.Runner, s *metricSet, _ routeros.Version) error {
+	for _, table := range []string{"filter", "nat", "mangle"} {
+		res, err := rows(r, "/ip/firewall/"+table, ".id,chain,action,comment,bytes,packets")
+		if err != nil {
+			return err
+		}
+		for _, m := range res {
+			labels := []string{"table", table, "chain", m["chain"], "id", m[".id"], "action", m["action"], "comment", m["comment"]}
+			s.add("routeros_firewall_rule_bytes_total", counter, "Bytes matched by a firewall rule.", number(m["bytes"]), labels...)
+			s.add("routeros_firewall_rule_packets_total", counter, "Packets matched by a firewall rule.", number(m["packets"]), labels...)
+		}
+	}
+	return nil
+}
+
+func collectBGP(r routeros.Runner, s *metricSet, v routeros.Version) error {
+	// RouterOS 7 reports the state of BGP peers in sessions
+	var peers []map[string]string
+	if v.Major >= 7 {
+		res, err := rows(r, "/routing/bgp/session", "name,remote.address,remote.as,established,prefix-count")
+		if err != nil {
+			return err
+		}
+		for _, m := range res {
+			peers = append(peers, map[string]string{
+				"name":         m["name"],
+				"address":      m["remote.address"],
+				"as":           m["remote.as"],
+				"established":  m["established"],
+				"prefix-count": m["prefix-count"],
+			})
+		}
+	} else {
+		res, err := rows(r, "/routing/bgp/peer", "name,remote-address,remote-as,state,prefix-count")
+		if err != nil {
+			return err
+		}
+		for _, m := range res {
+			established := "false"
+			if m["state"] == "established" {
+				established = "true"
+			}
+			peers = append(peers, map[string]string{
+				"name":         m["name"],
+				"address":      m["remote-address"],
+				"as":           m["remote-as"],
+				"established":  established,
+				"prefix-count": m["prefix-count"],
+			})
+		}
+	}
+	for _, p := range peers {
+		labels := []string{"peer", p["name"], "remote_address", p["address"], "remote_as", p["as"]}
+		s.add("routeros_bgp_session_established", gauge, "Whether the BGP session is established.", boolean(p["established"]), labels...)
+		s.add("routeros_bgp_session_prefixes", gauge, "Prefixes received in the BGP session.", number(p["prefix-count"]), labels...)
+	}
+	return nil
+}
+
+func collectWireless(r routeros.Runner, s *metricSet, v routeros.Version) error {
+	// the wifi package replaces wireless since RouterOS 7.13, but devices
+	// may run either
+	paths := []string{"/interface/wireless/registration-table"}
+	if v.Major >= 7 {
+		paths = append([]string{"/interface/wifi/registration-table"}, paths...)
+	}
+	var order []string
+	counts := make(map[string]int)
+	for _, path := range paths {
+		res, err := rows(r, path, "interface")
+		if err != nil {
+			return err
+		}
+		for _, m := range res {
+			if counts[m["interface"]] == 0 {
+				order = append(order, m["interface"])
+			}
+			counts[m["interface"]]++
+		}
+	}
+	for _, iface := range order {
+		s.add("routeros_wireless_clients", gauge, "Registered wireless clients.", float64(counts[iface]), "interface", iface)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/metrics"
+)
+
+// Target is a device to scrape.
+type Target struct {
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	TLS      bool   `json:"tls"`
+	// Fingerprint pins the public key of the device certificate, see
+	// routeros.Fingerprint, instead of verifying the certificate against
+	// the system roots. It implies TLS.
+	Fingerprint string `json:"fingerprint"`
+}
+
+// Exporter scrapes its targets on every HTTP request. The connection to a
+// target is kept between scrapes and replaced after an error.
+type Exporter struct {
+	targets []*target
+	timeout time.Duration
+	dial    func(ctx context.Context, t Target) (*routeros.Client, error)
+}
+
+type target struct {
+	Target
+
+	mu     sync.Mutex
+	client *routeros.Client
+}
+
+// NewExporter returns an Exporter for targets. A scrape is given timeout to
+// complete.
+func NewExporter(targets []Target, timeout time.Duration, dial func(ctx context.Context, t Target) (*routeros.Client, error)) *Exporter {
+	e := &Exporter{timeout: timeout, dial: dial}
+	for _, t := range targets {
+		e.targets = append(e.targets, &target{Target: t})
+	}
+	return e
+}
+
+// Close closes the connections to the targets.
+func (e *Exporter) Close() {
+	for _, t := range e.targets {
+		t.mu.Lock()
+		if t.client != nil {
+			t.client.Close()
+			t.client = nil
+		}
+		t.mu.Unlock()
+	}
+}
+
+// ServeHTTP scrapes all targets concurrently and writes their metrics.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+
+	sets := make([]*metricSet, len(e.targets))
+	var wg sync.WaitGroup
+	for i, t := range e.targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sets[i] = e.scrape(ctx, t)
+		}()
+	}
+	wg.Wait()
+
+	all := newMetricSet()
+	for _, s := range sets {
+		all.merge(s)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	all.writeTo(w)
+}
+
+// scrape collects the metrics of t. All samples carry the target label.
+func (e *Exporter) scrape(ctx context.Context, t *target) *metricSet {
+	start := time.Now()
+	s := newMetricSet("target", t.Name)
+	err := t.collect(ctx, e.dial, s)
+	up := 1.0
+	if err != nil {
+		log.Printf("routeros-exporter: %s: %v", t.Name, err)
+		up = 0
+		// the samples of a failed scrape may be incomplete
+		s = newMetricSet("target", t.Name)
+	}
+	s.add("routeros_up", gauge, "Whether the last scrape of the target succeeded.", up)
+	s.add("routeros_scrape_duration_seconds", gauge, "Duration of the last scrape of the target.", time.Since(start).Seconds())
+	return s
+}
+
+// collect runs the collectors on the connection of t, dialing it first if
+// needed. The connection is closed when ctx is done before the collectors
+// have finished or when they fail.
+func (t *target) collect(ctx context.Context, dial func(context.Context, Target) (*routeros.Client, error), s *metricSet) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client == nil {
+		c, err := dial(ctx, t.Target)
+		if err != nil {
+			return err
+		}
+		t.client = c
+	}
+	c := t.client
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- collectAll(c, s)
+	}()
+	var err error
+	select {
+	case err = <-errC:
+	case <-ctx.Done():
+		// closing the connection makes the collectors return
+		c.Close()
+		<-errC
+		err = ctx.Err()
+	}
+	if err != nil {
+		c.Close()
+		t.client = nil
+	}
+	return err
+}
+
+type metricType string
+
+const (
+	gauge   metricType = "gauge"
+	counter metricType = "counter"
+)
+
+// metricSet holds samples grouped by metric name.
+type metricSet struct {
+	labels   []string
+	families map[string]*family
+}
+
+type family struct {
+	typ     metricType
+	help    string
+	samples []string
+}
+
+// newMetricSet returns a metricSet adding the given label name/value pairs
+// to all samples.
+func newMetricSet(labels ...string) *metricSet {
+	return &metricSet{labels: labels, families: make(map[string]*family)}
+}
+
+// add adds a sample of the metric name with the given label name/value
+// pairs.
+func (s *metricSet) add(name string, typ metricType, help string, v float64, labels ...string) {
+	f := s.families[name]
+	if f == nil {
+		f = &family{typ: typ, help: help}
+		s.families[name] = f
+	}
+	f.samples = append(f.samples, metrics.Sample(name, v, append(slices.Clip(s.labels), labels...)...))
+}
+
+func (s *metricSet) merge(o *metricSet) {
+	for name, of := range o.families {
+		f := s.families[name]
+		if f == nil {
+			s.families[name] = &family{typ: of.typ, help: of.help, samples: slices.Clone(of.samples)}
+			continue
+		}
+		f.samples = append(f.samples, of.samples...)
+	}
+}
+
+func (s *metricSet) writeTo(w io.Writer) error {
+	var b strings.Builder
+	names := make([]string, 0, len(s.families))
+	for name := range s.families {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		f := s.families[name]
+		b.WriteString(metrics.Header(name, string(f.typ), f.help))
+		for _, sample := range f.samples {
+			b.WriteString(sample)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
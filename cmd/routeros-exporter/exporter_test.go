@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/proto"
+	"github.com/swoga/go-routeros/server"
+	"github.com/swoga/go-routeros/server/servertest"
+)
+
+// fakeDevice answers print commands with fixed rows and traps all other
+// commands like a device without the menu.
+type fakeDevice map[string][]map[string]string
+
+func (d fakeDevice) ServeAPI(w server.ResponseWriter, cmd *server.Command) {
+	rows, ok := d[strings.TrimSuffix(cmd.Word, "/print")]
+	if !ok || !strings.HasSuffix(cmd.Word, "/print") {
+		w.Trap(server.NoCategory, "no such command prefix")
+		w.Done()
+		return
+	}
+	for _, row := range rows {
+		var pairs []proto.Pair
+		for k, v := range row {
+			pairs = append(pairs, proto.Pair{Key: k, Value: v})
+		}
+		w.Re(pairs...)
+	}
+	w.Done()
+}
+
+func startFake(t *testing.T, d fakeDevice) string {
+	return servertest.Start(t, &server.Server{Handler: d})
+}
+
+func scrape(t *testing.T, e *Exporter) string {
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	return rec.Body.String()
+}
+
+func dial(ctx context.Context, t Target) (*routeros.Client, error) {
+	return routeros.Connect(ctx, t.Address, routeros.WithCredentials(t.Username, t.Password), routeros.WithTimeout(time.Second))
+}
+
+func TestExporter(t *testing.T) {
+	v7 := startFake(t, fakeDevice{
+		"/system/resource": {{"version": "7.15.3 (stable)", "board-name": "CCR2004", "architecture-name": "arm64",
+			"uptime": "1d2h", "cpu-load": "7", "free-memory": "100", "total-memory": "400"}},
+		"/system/health": {
+			{"name": "cpu-temperature", "value": "48", "type": "C"},
+			{"name": "fan1-speed", "value": "3000", "type": "RPM"},
+		},
+		"/interface": {
+			{"name": "ether1", "type": "ether", "running": "true", "rx-byte": "1000", "tx-byte": "2000"},
+			{"name": "ether2", "type": "ether", "running": "false", "rx-byte": "0", "tx-byte": "0"},
+		},
+		"/ip/dhcp-server/lease": {
+			{"server": "lan", "status": "bound"},
+			{"server": "lan", "status": "bound"},
+			{"server": "lan", "status": "waiting"},
+		},
+		"/ip/firewall/filter": {{".id": "*1", "chain": "input", "action": "drop", "comment": `say "hi"`, "bytes": "512", "packets": "8"}},
+		"/routing/bgp/session": {{"name": "upstream-1", "remote.address": "192.0.2.1", "remote.as": "64500",
+			"established": "true", "prefix-count": "950000"}},
+		"/interface/wifi/registration-table": {{"interface": "wifi1"}, {"interface": "wifi1"}},
+	})
+	v6 := startFake(t, fakeDevice{
+		"/system/resource": {{"version": "6.49.10 (long-term)", "uptime": "5m"}},
+		"/system/health":   {{"temperature": "40", "cpu-temperature": "45", "voltage": "24"}},
+		"/routing/bgp/peer": {{"name": "peer1", "remote-address": "198.51.100.1", "remote-as": "64501",
+			"state": "active", "prefix-count": "0"}},
+		"/interface/wireless/registration-table": {{"interface": "wlan1"}},
+	})
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down.Close()
+
+	e := NewExporter([]Target{
+		{Name: "core", Address: v7},
+		{Name: "old", Address: v6},
+		{Name: "gone", Address: down.Addr().String()},
+	}, 5*time.Second, dial)
+	defer e.Close()
+
+	body := scrape(t, e)
+	for _, want := range []string{
+		"# TYPE routeros_up gauge",
+		`routeros_up{target="core"} 1`,
+		`routeros_up{target="old"} 1`,
+		`routeros_up{target="gone"} 0`,
+		`routeros_system_info{target="core",version="7.15.3 (stable)",board_name="CCR2004",architecture="arm64"} 1`,
+		`routeros_system_uptime_seconds{target="core"} 93600`,
+		`routeros_system_cpu_load_percent{target="core"} 7`,
+		`routeros_system_temperature_celsius{target="core",sensor="cpu-temperature"} 48`,
+		`routeros_system_temperature_celsius{target="old",sensor="cpu-temperature"} 45`,
+		`routeros_system_temperature_celsius{target="old",sensor="temperature"} 40`,
+		"# TYPE routeros_interface_rx_bytes_total counter",
+		`routeros_interface_rx_bytes_total{target="core",interface="ether1",type="ether"} 1000`,
+		`routeros_interface_running{target="core",interface="ether2",type="ether"} 0`,
+		`routeros_dhcp_leases{target="core",server="lan",status="bound"} 2`,
+		`routeros_dhcp_leases{target="core",server="lan",status="waiting"} 1`,
+		`routeros_firewall_rule_bytes_total{target="core",table="filter",chain="input",id="*1",action="drop",comment="say \"hi\""} 512`,
+		`routeros_bgp_session_established{target="core",peer="upstream-1",remote_address="192.0.2.1",remote_as="64500"} 1`,
+		`routeros_bgp_session_prefixes{target="core",peer="upstream-1",remote_address="192.0.2.1",remote_as="64500"} 950000`,
+		`routeros_bgp_session_established{target="old",peer="peer1",remote_address="198.51.100.1",remote_as="64501"} 0`,
+		`routeros_wireless_clients{target="core",interface="wifi1"} 2`,
+		`routeros_wireless_clients{target="old",interface="wlan1"} 1`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+	if strings.Contains(body, "fan1-speed") || strings.Contains(body, "voltage") {
+		t.Error("non-temperature health values exported")
+	}
+	if strings.Count(body, "# TYPE routeros_up ") != 1 {
+		t.Error("metric family repeated")
+	}
+	if t.Failed() {
+		t.Log(body)
+	}
+
+	// the connections are kept
+	body = scrape(t, e)
+	if !strings.Contains(body, `routeros_up{target="core"} 1`) {
+		t.Fatal("second scrape failed")
+	}
+}
+
+func TestExporterTimeout(t *testing.T) {
+	// accepts the connection but never answers the login
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	e := NewExporter([]Target{{Name: "slow", Address: l.Addr().String()}}, 100*time.Millisecond, dial)
+	defer e.Close()
+	start := time.Now()
+	body := scrape(t, e)
+	if !strings.Contains(body, `routeros_up{target="slow"} 0`) {
+		t.Fatalf("metrics=%s; want target down", body)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatalf("scrape took %s", time.Since(start))
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Command routeros-exporter serves metrics of RouterOS devices in the
+Prometheus text format. Every request of /metrics scrapes all targets
+concurrently over the API.
+
+Usage:
+
+	routeros-exporter -config exporter.json
+
+The configuration file looks like:
+
+	{
+		"listen": ":9436",
+		"timeout": "10s",
+		"username": "monitoring",
+		"password": "secret",
+		"targets": [
+			{"name": "core1", "address": "192.168.88.1:8728"},
+			{"name": "edge1", "address": "192.168.88.2:8729", "tls": true, "username": "ro", "password": "x"},
+			{"name": "edge2", "address": "192.168.88.3:8729", "fingerprint": "SHA256:n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg"}
+		]
+	}
+
+Targets without username and password use the global ones. With tls the
+device certificate is verified against the system roots; a fingerprint pins
+its public key instead, see routeros.Fingerprint, as needed for the
+self-signed certificates of RouterOS, and implies tls. The exported
+metrics cover system resources and health, interface counters, DHCP leases,
+firewall rule counters, BGP sessions and wireless clients; see collect.go for
+the full list. Menus missing on a device, e.g. because a package is not
+installed, are skipped.
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/swoga/go-routeros"
+)
+
+type config struct {
+	Listen   string   `json:"listen"`
+	Timeout  string   `json:"timeout"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	Targets  []Target `json:"targets"`
+}
+
+var configFile = flag.String("config", "routeros-exporter.json", "Configuration file")
+
+func loadConfig(name string) (*config, time.Duration, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	cfg := &config{Listen: ":9436", Timeout: "10s"}
+	err = json.Unmarshal(b, cfg)
+	if err != nil {
+		return nil, 0, err
+	}
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range cfg.Targets {
+		t := &cfg.Targets[i]
+		if t.Username == "" && t.Password == "" {
+			t.Username, t.Password = cfg.Username, cfg.Password
+		}
+		if t.Name == "" {
+			t.Name = t.Address
+		}
+	}
+	return cfg, timeout, nil
+}
+
+func main() {
+	flag.Parse()
+
+	cfg, timeout, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dial := func(ctx context.Context, t Target) (*routeros.Client, error) {
+		opts := []routeros.Option{
+			routeros.WithCredentials(t.Username, t.Password),
+			routeros.WithTimeout(timeout),
+		}
+		if t.TLS {
+			opts = append(opts, routeros.WithTLS(nil))
+		}
+		if t.Fingerprint != "" {
+			opts = append(opts, routeros.WithPinnedFingerprints(t.Fingerprint))
+		}
+		return routeros.Connect(ctx, t.Address, opts...)
+	}
+
+	e := NewExporter(cfg.Targets, timeout, dial)
+	defer e.Close()
+
+	http.Handle("/metrics", e)
+	log.Printf("routeros-exporter: listening on %s, %d targets", cfg.Listen, len(cfg.Targets))
+	log.Fatal(http.ListenAndServe(cfg.Listen, nil))
+}
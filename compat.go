@@ -0,0 +1,398 @@
+package routeros
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/swoga/go-routeros/proto"
+)
+
+// Mapping maps a RouterOS 6 menu to its RouterOS 7 equivalent.
+type Mapping struct {
+	// V6 and V7 are the menu paths. An empty path means the menu has no
+	// equivalent in that version.
+	V6, V7 string
+	// Attrs maps RouterOS 6 attribute names to RouterOS 7 names. An empty
+	// RouterOS 7 name means the attribute has no equivalent.
+	Attrs map[string]string
+	// Since is the first RouterOS 7 version with the V7 menu, e.g. "7.13".
+	// Older RouterOS 7 versions use the V6 menu unchanged.
+	Since string
+}
+
+// DefaultMappings are the menus moved or restructured in RouterOS 7.
+var DefaultMappings = []Mapping{
+	{
+		V6: "/routing/bgp/peer", V7: "/routing/bgp/connection",
+		Attrs: map[string]string{
+			"remote-address":    "remote.address",
+			"remote-as":         "remote.as",
+			"remote-port":       "remote.port",
+			"update-source":     "local.address",
+			"in-filter":         "input.filter",
+			"out-filter":        "output.filter-chain",
+			"default-originate": "output.default-originate",
+			"route-reflect":     "",
+		},
+	},
+	{V6: "/routing/bgp/instance", V7: "/routing/bgp/template"},
+	{V6: "/routing/bgp/network", V7: ""},
+	{
+		V6: "/routing/ospf/interface", V7: "/routing/ospf/interface-template",
+		Attrs: map[string]string{
+			"interface":    "interfaces",
+			"network-type": "type",
+		},
+	},
+	{
+		V6: "/routing/ospf/instance", V7: "/routing/ospf/instance",
+		Attrs: map[string]string{
+			"distribute-default": "originate-default",
+		},
+	},
+	{V6: "/routing/ospf/network", V7: ""},
+	{V6: "/routing/filter", V7: ""},
+	{V6: "/routing/bfd/interface", V7: "/routing/bfd/configuration"},
+	{V6: "/ip/route/rule", V7: "/routing/rule"},
+	{
+		V6: "/ip/route", V7: "/ip/route",
+		Attrs: map[string]string{
+			"routing-mark": "routing-table",
+		},
+	},
+	{
+		V6: "/interface/wireless", V7: "/interface/wifi", Since: "7.13",
+		Attrs: map[string]string{
+			"ssid":              "configuration.ssid",
+			"mode":              "configuration.mode",
+			"country":           "configuration.country",
+			"band":              "channel.band",
+			"frequency":         "channel.frequency",
+			"channel-width":     "channel.width",
+			"security-profile":  "security",
+			"wireless-protocol": "",
+		},
+	},
+	{
+		V6: "/interface/wireless/security-profiles", V7: "/interface/wifi/security", Since: "7.13",
+		Attrs: map[string]string{
+			"wpa2-pre-shared-key": "passphrase",
+		},
+	},
+	{V6: "/tool/user-manager", V7: "/user-manager"},
+}
+
+// ErrUntranslatable is returned in strict mode for commands without an
+// equivalent in the RouterOS version of the device.
+var ErrUntranslatable = errors.New("RouterOS: no equivalent")
+
+// Compat translates commands written for one RouterOS major version to the
+// version of the device, and the replies back. Menus and attributes not in
+// Mappings are passed unchanged.
+type Compat struct {
+	// Strict makes commands fail with ErrUntranslatable if their menu or one
+	// of their attributes has no equivalent on the device.
+	Strict bool
+	// Mappings is the translation table, a copy of DefaultMappings by
+	// default.
+	Mappings []Mapping
+
+	r       Runner
+	dialect int
+	device  Version
+}
+
+var _ Runner = (*Compat)(nil)
+
+// NewCompat returns a Compat for commands written for RouterOS major version
+// dialect (6 or 7) that runs them on r, a device with the given version.
+func NewCompat(r Runner, device Version, dialect int) *Compat {
+	return &Compat{
+		Mappings: cloneMappings(DefaultMappings),
+		r:        r,
+		dialect:  dialect,
+		device:   device,
+	}
+}
+
+// cloneMappings returns a deep copy of ms, so changes to the mappings of one
+// Compat do not affect the defaults.
+func cloneMappings(ms []Mapping) []Mapping {
+	res := make([]Mapping, len(ms))
+	for i, m := range ms {
+		m.Attrs = maps.Clone(m.Attrs)
+		res[i] = m
+	}
+	return res
+}
+
+// Compat returns a Compat for commands written for RouterOS major version
+// dialect (6 or 7), using the device version from Info.
+func (c *Client) Compat(ctx context.Context, dialect int) (*Compat, error) {
+	info, err := c.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewCompat(c, info.Version, dialect), nil
+}
+
+// translation is the mapping applicable to one command.
+type translation struct {
+	from, to string
+	// attrs maps the caller's attribute names to the device's, back the
+	// reverse for replies. Only set if the menu matched exactly.
+	attrs, back map[string]string
+}
+
+// lookup returns the translation for the menu at path, or nil if it is
+// passed unchanged.
+func (cp *Compat) lookup(path string) (*translation, error) {
+	if cp.dialect == cp.device.Major || cp.device.Major < 6 || cp.device.Major > 7 {
+		return nil, nil
+	}
+	toV7 := cp.device.Major == 7
+
+	var best *Mapping
+	bestFrom := ""
+	for i := range cp.Mappings {
+		m := &cp.Mappings[i]
+		from := m.V6
+		if !toV7 {
+			from = m.V7
+		}
+		if from == "" || (path != from && !strings.HasPrefix(path, from+"/")) {
+			continue
+		}
+		if toV7 && m.Since != "" && !cp.device.AtLeast(m.Since) {
+			continue
+		}
+		if len(from) > len(bestFrom) {
+			best, bestFrom = m, from
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+
+	to := best.V7
+	if !toV7 {
+		to = best.V6
+	}
+	if to == "" {
+		if cp.Strict {
+			return nil, fmt.Errorf("%w for %s in RouterOS %d", ErrUntranslatable, path, cp.device.Major)
+		}
+		return nil, nil
+	}
+	t := &translation{from: bestFrom, to: to}
+	if path == bestFrom && len(best.Attrs) > 0 {
+		t.attrs = make(map[string]string, len(best.Attrs))
+		t.back = make(map[string]string, len(best.Attrs))
+		for v6, v7 := range best.Attrs {
+			if !toV7 {
+				v6, v7 = v7, v6
+			}
+			if v6 == "" {
+				continue
+			}
+			t.attrs[v6] = v7
+			if v7 != "" {
+				t.back[v7] = v6
+			}
+		}
+	}
+	return t, nil
+}
+
+// Translate returns sentence translated for the device.
+func (cp *Compat) Translate(sentence []string) ([]string, error) {
+	t, err := cp.translation(sentence)
+	if err != nil || t == nil {
+		return sentence, err
+	}
+	return cp.translate(t, sentence)
+}
+
+func (cp *Compat) translation(sentence []string) (*translation, error) {
+	if len(sentence) == 0 {
+		return nil, nil
+	}
+	i := strings.LastIndex(sentence[0], "/")
+	if i <= 0 {
+		return nil, nil
+	}
+	return cp.lookup(sentence[0][:i])
+}
+
+func (cp *Compat) translate(t *translation, sentence []string) ([]string, error) {
+	res := make([]string, len(sentence))
+	res[0] = t.to + strings.TrimPrefix(sentence[0], t.from)
+	for i, word := range sentence[1:] {
+		w, err := cp.translateWord(t, word)
+		if err != nil {
+			return nil, fmt.Errorf("RouterOS: %s: %w", sentence[0], err)
+		}
+		res[i+1] = w
+	}
+	return res, nil
+}
+
+// translateWord renames the attribute in an attribute or query word and the
+// names in .proplist.
+func (cp *Compat) translateWord(t *translation, word string) (string, error) {
+	if t.attrs == nil || len(word) < 2 {
+		return word, nil
+	}
+	if strings.HasPrefix(word, "=.proplist=") {
+		names := strings.Split(strings.TrimPrefix(word, "=.proplist="), ",")
+		for i, n := range names {
+			to, err := cp.attr(t, n)
+			if err != nil {
+				return "", err
+			}
+			names[i] = to
+		}
+		return "=.proplist=" + strings.Join(names, ","), nil
+	}
+
+	var prefix string
+	switch word[0] {
+	case '=':
+		prefix = "="
+	case '?':
+		prefix = "?"
+		switch word[1] {
+		case '#':
+			return word, nil
+		case '-', '<', '>':
+			prefix = word[:2]
+		}
+	default:
+		return word, nil
+	}
+	name, value, hasValue := strings.Cut(word[len(prefix):], "=")
+	to, err := cp.attr(t, name)
+	if err != nil {
+		return "", err
+	}
+	if hasValue {
+		return prefix + to + "=" + value, nil
+	}
+	return prefix + to, nil
+}
+
+func (cp *Compat) attr(t *translation, name string) (string, error) {
+	to, ok := t.attrs[name]
+	switch {
+	case !ok:
+		return name, nil
+	case to != "":
+		return to, nil
+	case cp.Strict:
+		return "", fmt.Errorf("%w for attribute %s in RouterOS %d", ErrUntranslatable, name, cp.device.Major)
+	}
+	return name, nil
+}
+
+// translateReply renames the attributes of the sentences back to the
+// caller's version.
+func translateReply(t *translation, sen *proto.Sentence) *proto.Sentence {
+	if t == nil || t.back == nil || sen == nil {
+		return sen
+	}
+	res := &proto.Sentence{Word: sen.Word, Tag: sen.Tag, Map: make(map[string]string, len(sen.Map))}
+	for _, p := range sen.List {
+		if to, ok := t.back[p.Key]; ok {
+			p.Key = to
+		}
+		res.List = append(res.List, p)
+		res.Map[p.Key] = p.Value
+	}
+	return res
+}
+
+// Run simply calls RunArgs().
+func (cp *Compat) Run(sentence ...string) (*Reply, error) {
+	return cp.RunArgs(sentence)
+}
+
+// RunArgs translates sentence, runs it and translates the reply back.
+func (cp *Compat) RunArgs(sentence []string) (*Reply, error) {
+	t, err := cp.translation(sentence)
+	if err != nil {
+		return nil, err
+	}
+	if t != nil {
+		sentence, err = cp.translate(t, sentence)
+		if err != nil {
+			return nil, err
+		}
+	}
+	r, err := cp.r.RunArgs(sentence)
+	if r != nil && t != nil {
+		for i, re := range r.Re {
+			r.Re[i] = translateReply(t, re)
+		}
+		r.Done = translateReply(t, r.Done)
+	}
+	return r, err
+}
+
+// Print runs the print command of the menu at path.
+func (cp *Compat) Print(path string, args ...string) (*Reply, error) {
+	return cp.RunArgs(printSentence(path, args))
+}
+
+// Add runs the add command of the menu at path and returns the .id of the
+// new item.
+func (cp *Compat) Add(path string, attrs map[string]string) (string, error) {
+	r, err := cp.RunArgs(addSentence(path, attrs))
+	if err != nil {
+		return "", err
+	}
+	return r.Done.Map["ret"], nil
+}
+
+// Set runs the set command of the menu at path for the item with id.
+func (cp *Compat) Set(path, id string, attrs map[string]string) error {
+	_, err := cp.RunArgs(setSentence(path, id, attrs))
+	return err
+}
+
+// Remove runs the remove command of the menu at path for the items with ids.
+func (cp *Compat) Remove(path string, ids ...string) error {
+	_, err := cp.RunArgs(removeSentence(path, ids))
+	return err
+}
+
+// Listen translates the listen command and the sentences it receives.
+func (cp *Compat) Listen(sentence ...string) (*ListenReply, error) {
+	t, err := cp.translation(sentence)
+	if err != nil {
+		return nil, err
+	}
+	if t != nil {
+		sentence, err = cp.translate(t, sentence)
+		if err != nil {
+			return nil, err
+		}
+	}
+	l, err := cp.r.Listen(sentence...)
+	if err != nil || t == nil || t.back == nil {
+		return l, err
+	}
+
+	tl := newListenReply(cap(l.reC))
+	tl.cancel = l.Cancel
+	go func() {
+		for sen := range l.Chan() {
+			tl.send(translateReply(t, sen))
+		}
+		tl.Done = translateReply(t, l.Done)
+		tl.close(l.Err())
+	}()
+	return tl, nil
+}
@@ -0,0 +1,189 @@
+package routeros_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/proto"
+	"github.com/swoga/go-routeros/server"
+	"github.com/swoga/go-routeros/server/servertest"
+)
+
+func TestCompatTranslate(t *testing.T) {
+	v7, _ := routeros.ParseVersion("7.15.3")
+	v712, _ := routeros.ParseVersion("7.12")
+	v6, _ := routeros.ParseVersion("6.49.10")
+
+	tests := []struct {
+		name    string
+		device  routeros.Version
+		dialect int
+		strict  bool
+		in      []string
+		want    []string
+		err     bool
+	}{
+		{
+			"bgp peer", v7, 6, false,
+			[]string{"/routing/bgp/peer/add", "=name=isp", "=remote-address=192.0.2.1", "=remote-as=65000"},
+			[]string{"/routing/bgp/connection/add", "=name=isp", "=remote.address=192.0.2.1", "=remote.as=65000"},
+			false,
+		},
+		{
+			"query and proplist", v7, 6, false,
+			[]string{"/ip/route/print", "?routing-mark=vpn", "?-routing-mark", "?#!", "=.proplist=dst-address,routing-mark"},
+			[]string{"/ip/route/print", "?routing-table=vpn", "?-routing-table", "?#!", "=.proplist=dst-address,routing-table"},
+			false,
+		},
+		{
+			"submenu", v7, 6, false,
+			[]string{"/ip/route/rule/print"},
+			[]string{"/routing/rule/print"},
+			false,
+		},
+		{
+			"v7 to v6", v6, 7, false,
+			[]string{"/routing/ospf/interface-template/add", "=interfaces=ether1", "=type=ptp"},
+			[]string{"/routing/ospf/interface/add", "=interface=ether1", "=network-type=ptp"},
+			false,
+		},
+		{
+			"wifi before 7.13", v712, 6, false,
+			[]string{"/interface/wireless/set", "=.id=*1", "=ssid=x"},
+			[]string{"/interface/wireless/set", "=.id=*1", "=ssid=x"},
+			false,
+		},
+		{
+			"wifi", v7, 6, false,
+			[]string{"/interface/wireless/set", "=.id=*1", "=ssid=x"},
+			[]string{"/interface/wifi/set", "=.id=*1", "=configuration.ssid=x"},
+			false,
+		},
+		{
+			"same version", v7, 7, true,
+			[]string{"/routing/bgp/peer/print"},
+			[]string{"/routing/bgp/peer/print"},
+			false,
+		},
+		{
+			"untranslatable menu", v7, 6, false,
+			[]string{"/routing/filter/print"},
+			[]string{"/routing/filter/print"},
+			false,
+		},
+		{"untranslatable menu strict", v7, 6, true, []string{"/routing/filter/print"}, nil, true},
+		{"untranslatable attribute strict", v7, 6, true, []string{"/routing/bgp/peer/set", "=.id=*1", "=route-reflect=yes"}, nil, true},
+		{"v7 to v6 query strict", v6, 7, true, []string{"/routing/bgp/connection/print", "?remote.as=65000"}, []string{"/routing/bgp/peer/print", "?remote-as=65000"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp := routeros.NewCompat(nil, tt.device, tt.dialect)
+			cp.Strict = tt.strict
+			got, err := cp.Translate(tt.in)
+			if tt.err {
+				if !errors.Is(err, routeros.ErrUntranslatable) {
+					t.Fatalf("Translate()=%v; want ErrUntranslatable", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Translate()=%q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompat(t *testing.T) {
+	d, addr := startSim(t)
+	c, err := routeros.Connect(context.Background(), addr, routeros.WithCredentials("admin", ""), routeros.WithAsync())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	cp, err := c.Compat(context.Background(), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := cp.Add("/ip/route", map[string]string{"dst-address": "10.0.0.0/8", "gateway": "192.0.2.1", "routing-mark": "vpn"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Items("/ip/route")[0]["routing-table"]; got != "vpn" {
+		t.Fatalf("routing-table=%q; want vpn", got)
+	}
+
+	r, err := cp.Print("/ip/route", "?routing-mark=vpn", "=.proplist=.id,routing-mark")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Re) != 1 || r.Re[0].Map[".id"] != id || r.Re[0].Map["routing-mark"] != "vpn" || len(r.Re[0].List) != 2 {
+		t.Fatalf("Print=%s; want %s with routing-mark vpn", r, id)
+	}
+
+	l, err := cp.Listen("/ip/route/listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for d.Listeners("/ip/route") == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	d.Set("/ip/route", id, map[string]string{"routing-table": "main"})
+	sen := <-l.Chan()
+	if sen.Map["routing-mark"] != "main" || sen.Map["routing-table"] != "" {
+		t.Fatalf("listen=%s; want routing-mark", sen)
+	}
+	_, err = l.Cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range l.Chan() {
+	}
+	if l.Err() != nil || l.Done == nil {
+		t.Fatalf("Err=%v Done=%v", l.Err(), l.Done)
+	}
+}
+
+func TestCompatDone(t *testing.T) {
+	// the device answers with the attributes in !done
+	c := servertest.Dial(t, &server.Server{Handler: server.HandlerFunc(func(w server.ResponseWriter, cmd *server.Command) {
+		w.Done(proto.Pair{Key: "routing-table", Value: cmd.Map["routing-table"]})
+	})})
+
+	v7, _ := routeros.ParseVersion("7.15.3")
+	cp := routeros.NewCompat(c, v7, 6)
+	r, err := cp.Run("/ip/route/set", "=.id=*1", "=routing-mark=vpn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Done.Map["routing-mark"] != "vpn" || r.Done.Map["routing-table"] != "" {
+		t.Fatalf("Done=%s; want routing-mark", r.Done)
+	}
+}
+
+func TestCompatMappingsCopied(t *testing.T) {
+	v7, _ := routeros.ParseVersion("7.15.3")
+	cp := routeros.NewCompat(nil, v7, 6)
+	cp.Mappings[0].V7 = "/changed"
+	cp.Mappings[0].Attrs["remote-address"] = "changed"
+	cp.Mappings = append(cp.Mappings, routeros.Mapping{V6: "/a", V7: "/b"})
+
+	got, err := routeros.NewCompat(nil, v7, 6).Translate([]string{"/routing/bgp/peer/add", "=remote-address=192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/routing/bgp/connection/add", "=remote.address=192.0.2.1"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Translate()=%q; want %q", got, want)
+	}
+	if len(routeros.DefaultMappings) == len(cp.Mappings) {
+		t.Fatal("DefaultMappings grew")
+	}
+}
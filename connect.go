@@ -0,0 +1,233 @@
+package routeros
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/swoga/go-routeros/proto"
+)
+
+// Option configures Connect.
+type Option func(*connectConfig)
+
+type connectConfig struct {
+	tlsConfig    *tls.Config
+	useTLS       bool
+	credentials  Credentials
+	dial         dialFunc
+	dialTimeout  time.Duration
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	loginTimeout time.Duration
+	async        bool
+	queue        int
+	proxySet     bool
+	proxyURL     string
+	verify       func(address string) func([][]byte, [][]*x509.Certificate) error
+	certificates []tls.Certificate
+	err          error
+
+	stateFuncs        []func(State)
+	tagPrefix         string
+	unknownTagFuncs   []func(*proto.Sentence)
+	metrics           Metrics
+	keepaliveInterval time.Duration
+	keepaliveFailures int
+}
+
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// WithTLS connects using TLS. A nil cfg uses the default configuration.
+func WithTLS(cfg *tls.Config) Option {
+	return func(cc *connectConfig) {
+		cc.useTLS = true
+		cc.tlsConfig = cfg
+	}
+}
+
+// WithCredentials makes Connect log in after connecting. Without it the
+// caller has to call Login.
+func WithCredentials(username, password string) Option {
+	return func(cc *connectConfig) {
+		cc.credentials = StaticCredentials{username, password}
+	}
+}
+
+// WithDialer sets the function used to open the TCP connection, e.g. the
+// DialContext method of a net.Dialer with a local address or keepalive.
+// TLS, if enabled, is negotiated on top of the returned connection. When
+// connecting through a proxy, dial opens the connection to the proxy.
+func WithDialer(dial func(ctx context.Context, network, address string) (net.Conn, error)) Option {
+	return func(cc *connectConfig) {
+		cc.dial = dial
+	}
+}
+
+// WithTimeout sets the dial, read, write and login timeouts to d.
+func WithTimeout(d time.Duration) Option {
+	return func(cc *connectConfig) {
+		cc.dialTimeout = d
+		cc.readTimeout = d
+		cc.writeTimeout = d
+		cc.loginTimeout = d
+	}
+}
+
+// WithTimeouts sets the timeout for reading a reply, for writing a command
+// and for the whole login exchange. A zero login timeout means the login is
+// only bounded by the read and write timeouts and the context.
+func WithTimeouts(read, write, login time.Duration) Option {
+	return func(cc *connectConfig) {
+		cc.readTimeout = read
+		cc.writeTimeout = write
+		cc.loginTimeout = login
+	}
+}
+
+// WithAsync starts asynchronous mode after connecting, see Client.Async.
+func WithAsync() Option {
+	return func(cc *connectConfig) {
+		cc.async = true
+	}
+}
+
+// WithQueueSize sets Client.Queue, the queue size of the Listen functions.
+func WithQueueSize(n int) Option {
+	return func(cc *connectConfig) {
+		cc.queue = n
+	}
+}
+
+// Connect connects to the RouterOS device at address and applies opts.
+// Without options it opens a plain TCP connection with read and write
+// timeouts of one minute and does not log in.
+func Connect(ctx context.Context, address string, opts ...Option) (*Client, error) {
+	cc := &connectConfig{
+		readTimeout:  time.Minute,
+		writeTimeout: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(cc)
+	}
+	if cc.err != nil {
+		return nil, cc.err
+	}
+
+	conn, err := cc.dialContext(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	c := newClient(conn, cc.readTimeout, cc.writeTimeout)
+	c.Queue = cc.queue
+	c.stateFuncs = cc.stateFuncs
+	c.TagPrefix = cc.tagPrefix
+	c.unknownTagFuncs = cc.unknownTagFuncs
+	c.SetMetrics(cc.metrics)
+	c.setState(StateConnected)
+	c.address = address
+	c.opts = opts
+	if cc.credentials != nil {
+		err = c.loginCredentials(ctx, address, cc.credentials, cc.loginTimeout)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if cc.async {
+		c.Async()
+	}
+	c.Keepalive(cc.keepaliveInterval, cc.keepaliveFailures)
+	return c, nil
+}
+
+func (cc *connectConfig) dialContext(ctx context.Context, address string) (net.Conn, error) {
+	dial := cc.dial
+	if dial == nil {
+		dialer := &net.Dialer{Timeout: cc.dialTimeout}
+		dial = dialer.DialContext
+	} else if cc.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cc.dialTimeout)
+		defer cancel()
+	}
+
+	proxy, err := cc.proxy(address)
+	if err != nil {
+		return nil, err
+	}
+	var conn net.Conn
+	if proxy != nil {
+		conn, err = dialProxy(ctx, dial, proxy, address)
+	} else {
+		conn, err = dial(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !cc.useTLS {
+		return conn, nil
+	}
+
+	tlsConn := tls.Client(conn, cc.tlsConfigFor(address))
+	err = tlsConn.HandshakeContext(ctx)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// proxy returns the proxy to use for address, or nil for a direct connection.
+func (cc *connectConfig) proxy(address string) (*url.URL, error) {
+	if !cc.proxySet {
+		return proxyFromEnvironment(address)
+	}
+	if cc.proxyURL == "" {
+		return nil, nil
+	}
+	return parseProxyURL(cc.proxyURL)
+}
+
+// Redial opens a new connection to the device with the address and options
+// c was created with by Connect. The credentials are fetched again, so a
+// rotated password is used. c itself is not closed.
+func (c *Client) Redial(ctx context.Context) (*Client, error) {
+	if c.address == "" {
+		return nil, errors.New("RouterOS: Redial requires a Client created by Connect")
+	}
+	return Connect(ctx, c.address, c.opts...)
+}
+
+// loginCredentials gets the credentials for address from p and logs in.
+func (c *Client) loginCredentials(ctx context.Context, address string, p Credentials, timeout time.Duration) error {
+	username, password, err := p.Get(ctx, address)
+	if err != nil {
+		return err
+	}
+	return c.loginContext(ctx, username, password, timeout)
+}
+
+// loginContext calls Login and aborts it by closing the connection when ctx
+// is done or timeout has passed.
+func (c *Client) loginContext(ctx context.Context, username, password string, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.Close()
+	})
+	err := c.Login(username, password)
+	if !stop() {
+		return fmt.Errorf("RouterOS: /login: %w", ctx.Err())
+	}
+	return err
+}
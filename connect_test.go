@@ -0,0 +1,96 @@
+package routeros_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/server/servertest"
+	"github.com/swoga/go-routeros/sim"
+)
+
+func startSim(t *testing.T) (*sim.Device, string) {
+	d := sim.New()
+	return d, servertest.Start(t, d)
+}
+
+func TestConnect(t *testing.T) {
+	d, addr := startSim(t)
+
+	var dialed string
+	dialer := &net.Dialer{}
+	c, err := routeros.Connect(context.Background(), addr,
+		routeros.WithCredentials("admin", ""),
+		routeros.WithDialer(func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed = network + " " + address
+			return dialer.DialContext(ctx, network, address)
+		}),
+		routeros.WithTimeouts(time.Second, time.Second, time.Second),
+		routeros.WithQueueSize(10),
+		routeros.WithAsync(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if dialed != "tcp "+addr {
+		t.Fatalf("dialer called with %q; want tcp %s", dialed, addr)
+	}
+	if c.Queue != 10 {
+		t.Fatalf("Queue=%d; want 10", c.Queue)
+	}
+	l, err := c.Listen("/interface/listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for d.Listeners("/interface") == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	d.Set("/interface", "ether1", map[string]string{"comment": "x"})
+	sen := <-l.Chan()
+	if sen.Map["comment"] != "x" {
+		t.Fatalf("listen=%s; want comment x", sen)
+	}
+}
+
+func TestConnectWithoutCredentials(t *testing.T) {
+	_, addr := startSim(t)
+
+	c, err := routeros.Connect(context.Background(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_, err = c.Run("/system/identity/print")
+	if err == nil || err.Error() != "from RouterOS device: not logged in" {
+		t.Fatalf("Run()=%v; want not logged in", err)
+	}
+}
+
+func TestConnectLoginTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		// accept but never answer
+		conn, err := l.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	_, err = routeros.Connect(context.Background(), l.Addr().String(),
+		routeros.WithCredentials("admin", ""),
+		routeros.WithTimeouts(time.Minute, time.Minute, 50*time.Millisecond),
+	)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Connect()=%v; want deadline exceeded", err)
+	}
+}
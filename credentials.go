@@ -0,0 +1,221 @@
+package routeros
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials provides the user name and password for a device. Get is called
+// for every login, so an implementation can return rotated passwords without
+// restarting the program.
+type Credentials interface {
+	Get(ctx context.Context, address string) (username, password string, err error)
+}
+
+// StaticCredentials are fixed credentials.
+type StaticCredentials struct {
+	Username string
+	Password string
+}
+
+// Get returns the user name and password.
+func (s StaticCredentials) Get(context.Context, string) (string, string, error) {
+	return s.Username, s.Password, nil
+}
+
+// EnvCredentials reads the credentials from environment variables on every
+// login, e.g. EnvCredentials{"ROUTEROS_USER", "ROUTEROS_PASSWORD"}.
+type EnvCredentials struct {
+	UsernameVar string
+	PasswordVar string
+}
+
+// Get returns the values of the environment variables. It is an error if
+// the user name variable is not set.
+func (e EnvCredentials) Get(context.Context, string) (string, string, error) {
+	username, ok := os.LookupEnv(e.UsernameVar)
+	if !ok {
+		return "", "", fmt.Errorf("RouterOS: environment variable %s not set", e.UsernameVar)
+	}
+	return username, os.Getenv(e.PasswordVar), nil
+}
+
+// watchedFile caches the content of a file and reads it again when its
+// modification time or size changes.
+type watchedFile struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	data    []byte
+}
+
+func (f *watchedFile) read() ([]byte, error) {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.data != nil && fi.ModTime().Equal(f.modTime) && fi.Size() == f.size {
+		return f.data, nil
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	f.data, f.modTime, f.size = data, fi.ModTime(), fi.Size()
+	return data, nil
+}
+
+// FileCredentials reads the credentials from a file with the user name on the
+// first and the password on the second line, as mounted secrets usually are.
+// The file is read again whenever it changes.
+type FileCredentials struct {
+	f watchedFile
+}
+
+// NewFileCredentials returns FileCredentials for the file at path.
+func NewFileCredentials(path string) *FileCredentials {
+	return &FileCredentials{f: watchedFile{path: path}}
+}
+
+// Get returns the user name and password in the file.
+func (c *FileCredentials) Get(context.Context, string) (string, string, error) {
+	data, err := c.f.read()
+	if err != nil {
+		return "", "", fmt.Errorf("RouterOS: credentials: %w", err)
+	}
+	username, password, _ := strings.Cut(string(data), "\n")
+	password, _, _ = strings.Cut(password, "\n")
+	username = strings.TrimSuffix(username, "\r")
+	password = strings.TrimSuffix(password, "\r")
+	if username == "" {
+		return "", "", fmt.Errorf("RouterOS: credentials: no user name in %s", c.f.path)
+	}
+	return username, password, nil
+}
+
+// NetrcCredentials looks up the credentials in a netrc file by the host name
+// of the device address, falling back to the default entry. The file is read
+// again whenever it changes.
+type NetrcCredentials struct {
+	f watchedFile
+}
+
+// NewNetrcCredentials returns NetrcCredentials for the file at path. If path
+// is empty, $NETRC or ~/.netrc is used.
+func NewNetrcCredentials(path string) *NetrcCredentials {
+	if path == "" {
+		path = os.Getenv("NETRC")
+	}
+	if path == "" {
+		home, _ := os.UserHomeDir()
+		path = filepath.Join(home, ".netrc")
+	}
+	return &NetrcCredentials{f: watchedFile{path: path}}
+}
+
+// Get returns the login and password of the machine entry for address.
+func (c *NetrcCredentials) Get(_ context.Context, address string) (string, string, error) {
+	data, err := c.f.read()
+	if err != nil {
+		return "", "", fmt.Errorf("RouterOS: credentials: %w", err)
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	username, password, ok := lookupNetrc(data, host)
+	if !ok {
+		return "", "", fmt.Errorf("RouterOS: credentials: no entry for %s in %s", host, c.f.path)
+	}
+	return username, password, nil
+}
+
+// lookupNetrc returns the login and password of the machine entry for host
+// or of the default entry.
+func lookupNetrc(data []byte, host string) (username, password string, ok bool) {
+	var (
+		inMacro          bool
+		current          string
+		found            bool
+		fields           = map[string]string{}
+		defUser, defPass string
+		hasDefault       bool
+	)
+
+	flush := func() {
+		switch current {
+		case "machine " + host:
+			if !found {
+				username, password, found = fields["login"], fields["password"], true
+			}
+		case "default":
+			defUser, defPass, hasDefault = fields["login"], fields["password"], true
+		}
+		fields = map[string]string{}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			// a macro definition ends with an empty line
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		words := strings.Fields(line)
+		for i := 0; i < len(words); i++ {
+			switch w := words[i]; w {
+			case "machine":
+				flush()
+				current = ""
+				if i+1 < len(words) {
+					i++
+					current = "machine " + words[i]
+				}
+			case "default":
+				flush()
+				current = "default"
+			case "macdef":
+				flush()
+				current = ""
+				inMacro = true
+				i = len(words)
+			case "login", "password", "account":
+				if i+1 < len(words) {
+					i++
+					fields[w] = words[i]
+				}
+			default:
+				if strings.HasPrefix(w, "#") {
+					i = len(words)
+				}
+			}
+		}
+	}
+	flush()
+	if found {
+		return username, password, true
+	}
+	return defUser, defPass, hasDefault
+}
+
+// WithCredentialsProvider makes Connect log in with the credentials returned
+// by p for the device address. Client.Redial asks p again, so rotated
+// passwords are picked up on reconnect.
+func WithCredentialsProvider(p Credentials) Option {
+	return func(cc *connectConfig) {
+		cc.credentials = p
+	}
+}
@@ -0,0 +1,101 @@
+package routeros_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/server/servertest"
+	"github.com/swoga/go-routeros/sim"
+)
+
+func TestCredentials(t *testing.T) {
+	dir := t.TempDir()
+	netrc := filepath.Join(dir, "netrc")
+	os.WriteFile(netrc, []byte(`# fleet
+machine r1.example.com login api password one
+macdef init
+	machine r1.example.com login macro password macro
+
+machine r2.example.com
+	login api2
+	password two
+default login admin password fallback
+`), 0o600)
+	file := filepath.Join(dir, "secret")
+	os.WriteFile(file, []byte("api\r\nsecret\r\n"), 0o600)
+	t.Setenv("TEST_ROS_USER", "env")
+	t.Setenv("TEST_ROS_PASSWORD", "envpass")
+
+	tests := []struct {
+		name    string
+		creds   routeros.Credentials
+		address string
+		user    string
+		pass    string
+		err     string
+	}{
+		{"static", routeros.StaticCredentials{"admin", "x"}, "r1:8728", "admin", "x", ""},
+		{"env", routeros.EnvCredentials{"TEST_ROS_USER", "TEST_ROS_PASSWORD"}, "r1:8728", "env", "envpass", ""},
+		{"env unset", routeros.EnvCredentials{"TEST_ROS_UNSET", "TEST_ROS_PASSWORD"}, "r1:8728", "", "", "RouterOS: environment variable TEST_ROS_UNSET not set"},
+		{"file", routeros.NewFileCredentials(file), "r1:8728", "api", "secret", ""},
+		{"netrc machine", routeros.NewNetrcCredentials(netrc), "r1.example.com:8728", "api", "one", ""},
+		{"netrc multiline", routeros.NewNetrcCredentials(netrc), "r2.example.com:8729", "api2", "two", ""},
+		{"netrc default", routeros.NewNetrcCredentials(netrc), "192.0.2.1:8728", "admin", "fallback", ""},
+		{"netrc missing", routeros.NewNetrcCredentials(filepath.Join(dir, "missing")), "r1:8728", "", "", "RouterOS: credentials: stat " + filepath.Join(dir, "missing") + ": no such file or directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, pass, err := tt.creds.Get(context.Background(), tt.address)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("Get()=%v; want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user != tt.user || pass != tt.pass {
+				t.Fatalf("Get()=%s, %s; want %s, %s", user, pass, tt.user, tt.pass)
+			}
+		})
+	}
+}
+
+func TestConnectCredentialsRotation(t *testing.T) {
+	d := sim.New()
+	d.Users["admin"] = "secret"
+	addr := servertest.Start(t, d)
+
+	file := filepath.Join(t.TempDir(), "secret")
+	os.WriteFile(file, []byte("admin\nold\n"), 0o600)
+	creds := routeros.NewFileCredentials(file)
+
+	_, err := routeros.Connect(context.Background(), addr, routeros.WithCredentialsProvider(creds))
+	if err == nil {
+		t.Fatal("Connect with old password succeeded")
+	}
+
+	os.WriteFile(file, []byte("admin\nsecret\n"), 0o600)
+	c, err := routeros.Connect(context.Background(), addr, routeros.WithCredentialsProvider(creds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c2, err := c.Redial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2.Close()
+
+	// the provider is asked again on Redial
+	os.WriteFile(file, []byte("admin\nrotated\n"), 0o600)
+	_, err = c.Redial(context.Background())
+	if err == nil {
+		t.Fatal("Redial did not use the rotated password")
+	}
+}
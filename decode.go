@@ -0,0 +1,169 @@
+package routeros
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/swoga/go-routeros/proto"
+)
+
+// Decode stores the attributes of sen in the struct pointed to by v. The
+// attribute of a field is given by its routeros tag, e.g.
+//
+//	type Address struct {
+//		ID       string `routeros:".id"`
+//		Address  string `routeros:"address"`
+//		Disabled bool   `routeros:"disabled"`
+//	}
+//
+// Fields without tag use their name in kebab case (DstAddress becomes
+// dst-address), fields tagged "-" are skipped. Supported field types are
+// strings, booleans (true/yes and false/no), integers, floats,
+// time.Duration in RouterOS notation (see ParseDuration), string slices from
+// comma separated lists and types implementing encoding.TextUnmarshaler.
+// Attributes without field are ignored, fields without attribute keep their
+// value.
+func Decode(sen *proto.Sentence, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("RouterOS: Decode requires a non-nil pointer to a struct")
+	}
+	rv = rv.Elem()
+	for _, f := range structFields(rv.Type()) {
+		s, ok := sen.Map[f.name]
+		if !ok {
+			continue
+		}
+		err := setField(rv.FieldByIndex(f.index), s)
+		if err != nil {
+			return fmt.Errorf("RouterOS: decode %s=%q: %w", f.name, s, err)
+		}
+	}
+	return nil
+}
+
+type structField struct {
+	name  string
+	index []int
+}
+
+var fieldCache sync.Map // reflect.Type -> []structField
+
+func structFields(t reflect.Type) []structField {
+	if fs, ok := fieldCache.Load(t); ok {
+		return fs.([]structField)
+	}
+	var fs []structField
+	for _, sf := range reflect.VisibleFields(t) {
+		if !sf.IsExported() || sf.Anonymous {
+			continue
+		}
+		name := sf.Tag.Get("routeros")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = kebab(sf.Name)
+		}
+		fs = append(fs, structField{name, sf.Index})
+	}
+	fieldCache.Store(t, fs)
+	return fs
+}
+
+// kebab converts a Go identifier like DstAddress or MTU to dst-address and
+// mtu.
+func kebab(s string) string {
+	var b strings.Builder
+	r := []rune(s)
+	for i, c := range r {
+		if unicode.IsUpper(c) {
+			// start a word at an upper case letter following a lower case
+			// one, or preceding one in an acronym (e.g. MACAddress)
+			if i > 0 && (unicode.IsLower(r[i-1]) || (i+1 < len(r) && unicode.IsLower(r[i+1]) && unicode.IsUpper(r[i-1]))) {
+				b.WriteByte('-')
+			}
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+var (
+	durationType        = reflect.TypeFor[time.Duration]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+func setField(v reflect.Value, s string) error {
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if v.Type() == durationType {
+		d, err := ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		switch s {
+		case "true", "yes":
+			v.SetBool(true)
+		case "false", "no":
+			v.SetBool(false)
+		default:
+			return errors.New("invalid boolean")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var parts []string
+		if s != "" {
+			parts = strings.Split(s, ",")
+		}
+		sl := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, p := range parts {
+			sl.Index(i).SetString(p)
+		}
+		v.Set(sl)
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setField(v.Elem(), s)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"strings"
@@ -18,10 +19,14 @@ var (
 )
 
 func dial() (*routeros.Client, error) {
+	opts := []routeros.Option{routeros.WithCredentials(*username, *password)}
 	if *useTLS {
-		return routeros.DialTLS(*address, *username, *password, nil)
+		opts = append(opts, routeros.WithTLS(nil))
 	}
-	return routeros.Dial(*address, *username, *password)
+	if *async {
+		opts = append(opts, routeros.WithAsync())
+	}
+	return routeros.Connect(context.Background(), *address, opts...)
 }
 
 func main() {
@@ -33,10 +38,6 @@ func main() {
 	}
 	defer c.Close()
 
-	if *async {
-		c.Async()
-	}
-
 	r, err := c.RunArgs(strings.Split(*command, " "))
 	if err != nil {
 		log.Fatal(err)
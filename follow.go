@@ -0,0 +1,115 @@
+package routeros
+
+import (
+	"context"
+	"iter"
+
+	"github.com/swoga/go-routeros/proto"
+)
+
+// FollowKind is the kind of a FollowEvent.
+type FollowKind int
+
+const (
+	// FollowSnapshot is an entry that existed when the command started.
+	FollowSnapshot FollowKind = iota + 1
+	// FollowSnapshotEnd follows the last FollowSnapshot event. It carries
+	// no sentence.
+	FollowSnapshotEnd
+	// FollowUpdate is an entry that was added or changed.
+	FollowUpdate
+	// FollowDeleted is an entry that was removed, only ID is meaningful.
+	FollowDeleted
+)
+
+func (k FollowKind) String() string {
+	switch k {
+	case FollowSnapshot:
+		return "snapshot"
+	case FollowSnapshotEnd:
+		return "snapshot end"
+	case FollowUpdate:
+		return "update"
+	case FollowDeleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// FollowEvent is an event reported by Follow.
+type FollowEvent struct {
+	Kind     FollowKind
+	ID       string
+	Sentence *proto.Sentence
+}
+
+// Follow runs print with =follow= on path, or with =follow-only= if
+// followOnly is set, and returns an iterator over its events. query holds
+// additional words like query words or =.proplist=.
+//
+// Without followOnly the device first prints the entries matching query,
+// reported as FollowSnapshot events and terminated by a single
+// FollowSnapshotEnd event. All later rows are FollowUpdate or FollowDeleted
+// events. The device groups its output into sections, numbered by the
+// .section attribute; the snapshot ends with the sentence closing the first
+// section, or with the first row of a later section or of a deletion.
+//
+// When ctx is done, the command is cancelled and ctx.Err() is yielded. As
+// with ListenReply.All, breaking out of the loop cancels the command.
+func (c *Client) Follow(ctx context.Context, path string, query []string, followOnly bool) (iter.Seq2[FollowEvent, error], error) {
+	mode := "=follow="
+	if followOnly {
+		mode = "=follow-only="
+	}
+	sentence := append([]string{path + "/print", mode}, query...)
+	l, err := c.ListenArgsQueue(sentence, c.Queue)
+	if err != nil {
+		return nil, err
+	}
+
+	all := allContext(ctx, l)
+	return func(yield func(FollowEvent, error) bool) {
+		snapshot := !followOnly
+		first, hasFirst := "", false
+		for sen, err := range all {
+			if err != nil {
+				yield(FollowEvent{}, err)
+				return
+			}
+			section, hasSection := sen.Map[".section"]
+			if hasSection && len(sen.Map) == 1 {
+				// end of a section
+				if snapshot {
+					snapshot = false
+					if !yield(FollowEvent{Kind: FollowSnapshotEnd}, nil) {
+						return
+					}
+				}
+				continue
+			}
+
+			dead := sen.Map[".dead"] == "yes"
+			if snapshot && (dead || (hasFirst && hasSection && section != first)) {
+				snapshot = false
+				if !yield(FollowEvent{Kind: FollowSnapshotEnd}, nil) {
+					return
+				}
+			}
+			ev := FollowEvent{ID: sen.Map[".id"], Sentence: sen}
+			switch {
+			case snapshot:
+				ev.Kind = FollowSnapshot
+				if !hasFirst && hasSection {
+					first, hasFirst = section, true
+				}
+			case dead:
+				ev.Kind = FollowDeleted
+			default:
+				ev.Kind = FollowUpdate
+			}
+			if !yield(ev, nil) {
+				return
+			}
+		}
+	}, nil
+}
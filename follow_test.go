@@ -0,0 +1,110 @@
+package routeros_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+)
+
+func TestFollow(t *testing.T) {
+	d, addr := startSim(t)
+	c, err := routeros.Connect(context.Background(), addr, routeros.WithCredentials("admin", ""), routeros.WithAsync())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	existing, err := d.Add("/ip/address", map[string]string{"address": "192.0.2.1/24", "interface": "ether1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, followOnly := range []bool{false, true} {
+		ctx, cancel := context.WithCancel(context.Background())
+		events, err := c.Follow(ctx, "/ip/address", []string{"?interface=ether1"}, followOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for d.Listeners("/ip/address") == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		id, err := d.Add("/ip/address", map[string]string{"address": "198.51.100.1/24", "interface": "ether1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// not matched by the query
+		other, err := d.Add("/ip/address", map[string]string{"address": "203.0.113.1/24", "interface": "ether2"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Remove("/ip/address", id)
+
+		var got []string
+		for ev, err := range events {
+			if err != nil {
+				if err != context.Canceled {
+					t.Fatal(err)
+				}
+				break
+			}
+			s := ev.Kind.String()
+			if ev.ID != "" {
+				s += " " + ev.ID
+			}
+			got = append(got, s)
+			if ev.Kind == routeros.FollowUpdate && ev.Sentence.Map["address"] != "198.51.100.1/24" {
+				t.Errorf("update=%s; want added address", ev.Sentence)
+			}
+			if ev.Kind == routeros.FollowDeleted {
+				cancel()
+			}
+		}
+		cancel()
+		d.Remove("/ip/address", other)
+
+		want := []string{"update " + id, "deleted " + id}
+		if !followOnly {
+			want = append([]string{"snapshot " + existing, "snapshot end"}, want...)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("followOnly=%v: events=%q; want %q", followOnly, got, want)
+		}
+		for d.Listeners("/ip/address") != 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestFollowWithoutSeparator(t *testing.T) {
+	c, s := newPair(t)
+	defer c.Close()
+
+	go func() {
+		defer s.Close()
+		s.readSentence(t, "/interface/print @l1 [{`follow` ``}]")
+		s.writeSentence(t, "!re", ".tag=l1", "=.id=*1", "=.section=0")
+		s.writeSentence(t, "!re", ".tag=l1", "=.id=*2", "=.section=0")
+		s.writeSentence(t, "!re", ".tag=l1", "=.id=*1", "=.section=1")
+		s.writeSentence(t, "!re", ".tag=l1", "=.id=*2", "=.dead=yes", "=.section=2")
+		s.writeSentence(t, "!done", ".tag=l1")
+	}()
+
+	events, err := c.Follow(context.Background(), "/interface", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []routeros.FollowKind
+	for ev, err := range events {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ev.Kind)
+	}
+	want := []routeros.FollowKind{routeros.FollowSnapshot, routeros.FollowSnapshot, routeros.FollowSnapshotEnd, routeros.FollowUpdate, routeros.FollowDeleted}
+	if !slices.Equal(got, want) {
+		t.Fatalf("events=%v; want %v", got, want)
+	}
+}
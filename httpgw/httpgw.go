@@ -0,0 +1,336 @@
+/*
+Package httpgw exposes a routeros.Client over HTTP with JSON bodies, similar
+to the REST API of RouterOS 7.
+
+Requests are mapped to API commands as follows:
+
+	GET    /ip/address?disabled=false  /ip/address/print ?disabled=false
+	GET    /ip/address/*1              /ip/address/print ?.id=*1
+	PUT    /ip/address                 /ip/address/add (attributes from body)
+	PATCH  /ip/address/*1              /ip/address/set =.id=*1 (attributes from body)
+	DELETE /ip/address/*1              /ip/address/remove =.id=*1
+	POST   /system/reboot              /system/reboot (attributes from body)
+	GET    /ip/address/listen?list=a   /ip/address/listen ?list=a, streamed
+
+Query parameters of GET become API queries, except .proplist which selects
+the returned properties. A parameter without value (?comment) matches items
+having the property.
+
+Streamed replies are sent as Server-Sent Events if the request accepts
+text/event-stream and as newline delimited JSON otherwise. The command is
+cancelled on the device when the HTTP client goes away.
+*/
+package httpgw
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/proto"
+)
+
+// Gateway is an http.Handler relaying requests to a RouterOS device.
+type Gateway struct {
+	c *routeros.Client
+	// Queue is the queue size of streamed commands.
+	Queue int
+}
+
+// New returns a Gateway backed by c. Requests are served concurrently, so c
+// is put into asynchronous mode unless it already is, see
+// routeros.Client.Async.
+func New(c *routeros.Client) *Gateway {
+	c.Async()
+	return &Gateway{c: c, Queue: 100}
+}
+
+// Error is the JSON body of error responses.
+type Error struct {
+	Error string `json:"error"`
+	// Category is the category of the !trap if the device rejected the
+	// command.
+	Category string `json:"category,omitempty"`
+}
+
+// Reply is the JSON body of POST responses.
+type Reply struct {
+	Re   []map[string]string `json:"re"`
+	Done map[string]string   `json:"done"`
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimSuffix(r.URL.Path, "/")
+	if !strings.HasPrefix(p, "/") || len(p) < 2 {
+		writeError(w, http.StatusNotFound, &Error{Error: "no such command prefix"})
+		return
+	}
+	menu, id := splitID(p)
+
+	switch r.Method {
+	case http.MethodGet:
+		if strings.HasSuffix(p, "/listen") {
+			g.stream(w, r, p)
+			return
+		}
+		g.print(w, r, menu, id)
+	case http.MethodPut:
+		if id != "" {
+			writeError(w, http.StatusMethodNotAllowed, &Error{Error: "PUT adds to a menu, not to an item"})
+			return
+		}
+		attrs, ok := readAttrs(w, r)
+		if !ok {
+			return
+		}
+		reply, err := g.c.RunArgs(append([]string{menu + "/add"}, attrs...))
+		if err != nil {
+			writeDeviceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]string{".id": reply.Done.Map["ret"]})
+	case http.MethodPatch:
+		if id == "" {
+			writeError(w, http.StatusMethodNotAllowed, &Error{Error: "PATCH requires an item .id"})
+			return
+		}
+		attrs, ok := readAttrs(w, r)
+		if !ok {
+			return
+		}
+		_, err := g.c.RunArgs(append([]string{menu + "/set", "=.id=" + id}, attrs...))
+		if err != nil {
+			writeDeviceError(w, err)
+			return
+		}
+		g.print(w, r, menu, id)
+	case http.MethodDelete:
+		if id == "" {
+			writeError(w, http.StatusMethodNotAllowed, &Error{Error: "DELETE requires an item .id"})
+			return
+		}
+		_, err := g.c.Run(menu+"/remove", "=.id="+id)
+		if err != nil {
+			writeDeviceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPost:
+		attrs, ok := readAttrs(w, r)
+		if !ok {
+			return
+		}
+		reply, err := g.c.RunArgs(append([]string{p}, attrs...))
+		if err != nil {
+			writeDeviceError(w, err)
+			return
+		}
+		res := Reply{Re: []map[string]string{}, Done: reply.Done.Map}
+		for _, re := range reply.Re {
+			res.Re = append(res.Re, re.Map)
+		}
+		writeJSON(w, http.StatusOK, res)
+	default:
+		w.Header().Set("Allow", "GET, PUT, PATCH, DELETE, POST")
+		writeError(w, http.StatusMethodNotAllowed, &Error{Error: "method not allowed"})
+	}
+}
+
+// splitID splits the trailing .id (e.g. *1) from p.
+func splitID(p string) (menu, id string) {
+	i := strings.LastIndex(p, "/")
+	if strings.HasPrefix(p[i+1:], "*") {
+		return p[:i], p[i+1:]
+	}
+	return p, ""
+}
+
+func (g *Gateway) print(w http.ResponseWriter, r *http.Request, menu, id string) {
+	sentence, err := querySentence(menu+"/print", id, r.URL.RawQuery)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, &Error{Error: err.Error()})
+		return
+	}
+	reply, err := g.c.RunArgs(sentence)
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	if id != "" {
+		if len(reply.Re) == 0 {
+			writeError(w, http.StatusNotFound, &Error{Error: "no such item"})
+			return
+		}
+		writeJSON(w, http.StatusOK, reply.Re[0].Map)
+		return
+	}
+	items := make([]map[string]string, 0, len(reply.Re))
+	for _, re := range reply.Re {
+		items = append(items, re.Map)
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// querySentence builds the command word with the queries and .proplist of
+// the URL query, preserving the order of the parameters.
+func querySentence(word, id, rawQuery string) ([]string, error) {
+	sentence := []string{word}
+	if id != "" {
+		sentence = append(sentence, "?.id="+id)
+	}
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+		k, v, hasValue := strings.Cut(param, "=")
+		key, err := url.QueryUnescape(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query parameter %q: %w", k, err)
+		}
+		value, err := url.QueryUnescape(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query parameter value %q: %w", v, err)
+		}
+		switch {
+		case key == ".proplist":
+			sentence = append(sentence, "=.proplist="+value)
+		case !hasValue:
+			sentence = append(sentence, "?"+key)
+		default:
+			sentence = append(sentence, "?"+key+"="+value)
+		}
+	}
+	return sentence, nil
+}
+
+func (g *Gateway) stream(w http.ResponseWriter, r *http.Request, p string) {
+	sentence, err := querySentence(p, "", r.URL.RawQuery)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, &Error{Error: err.Error()})
+		return
+	}
+	l, err := g.c.ListenArgsQueue(sentence, g.Queue)
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	write := func(event string, v any) {
+		b, _ := json.Marshal(v)
+		if sse {
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+		} else {
+			fmt.Fprintf(w, "%s\n", b)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	cancelled := r.Context().Done()
+	reC := l.Chan()
+	for reC != nil {
+		select {
+		case sen, ok := <-reC:
+			if !ok {
+				reC = nil
+				break
+			}
+			write("re", sen.Map)
+		case <-cancelled:
+			l.Cancel()
+			cancelled = nil
+		}
+	}
+	if r.Context().Err() != nil {
+		return
+	}
+	if err := l.Err(); err != nil {
+		write("error", errorBody(err))
+		return
+	}
+	done := map[string]string{}
+	if l.Done != nil {
+		done = l.Done.Map
+	}
+	write("done", done)
+}
+
+func readAttrs(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	var body map[string]string
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, &Error{Error: "invalid JSON body: " + err.Error()})
+		return nil, false
+	}
+	attrs := make([]string, 0, len(body))
+	for _, k := range slices.Sorted(maps.Keys(body)) {
+		attrs = append(attrs, "="+k+"="+body[k])
+	}
+	return attrs, true
+}
+
+func errorBody(err error) *Error {
+	var devErr *routeros.DeviceError
+	if errors.As(err, &devErr) {
+		return &Error{
+			Error:    devErr.Sentence.Map["message"],
+			Category: devErr.Sentence.Map["category"],
+		}
+	}
+	return &Error{Error: err.Error()}
+}
+
+// writeDeviceError maps the error of a command to an HTTP status: device
+// errors about missing items or commands are 404, permission errors 403 and
+// other device errors 400. Any other error means the device could not be
+// reached and is 502.
+func writeDeviceError(w http.ResponseWriter, err error) {
+	var devErr *routeros.DeviceError
+	if !errors.As(err, &devErr) {
+		writeError(w, http.StatusBadGateway, errorBody(err))
+		return
+	}
+	writeError(w, deviceErrorStatus(devErr.Sentence), errorBody(err))
+}
+
+func deviceErrorStatus(sen *proto.Sentence) int {
+	m := sen.Map["message"]
+	switch {
+	case strings.HasPrefix(m, "no such"):
+		return http.StatusNotFound
+	case strings.Contains(m, "permission"):
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+func writeError(w http.ResponseWriter, status int, e *Error) {
+	writeJSON(w, status, e)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,197 @@
+package httpgw_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/httpgw"
+	"github.com/swoga/go-routeros/proto"
+	"github.com/swoga/go-routeros/server"
+	"github.com/swoga/go-routeros/sim"
+)
+
+func newGateway(t *testing.T) (*sim.Device, *httptest.Server) {
+	d := sim.New()
+	s, c := net.Pipe()
+	go d.ServeConn(s)
+	client, err := routeros.NewClient(c, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Login("admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Async()
+	t.Cleanup(client.Close)
+
+	ts := httptest.NewServer(httpgw.New(client))
+	t.Cleanup(ts.Close)
+	return d, ts
+}
+
+func do(t *testing.T, method, url, body string, v any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if v != nil {
+		err = json.NewDecoder(res.Body).Decode(v)
+		if err != nil {
+			t.Fatalf("%s %s: %s", method, url, err)
+		}
+	}
+	return res.StatusCode
+}
+
+func TestCRUD(t *testing.T) {
+	d, ts := newGateway(t)
+
+	var added map[string]string
+	status := do(t, "PUT", ts.URL+"/ip/address", `{"address":"192.0.2.1/24","interface":"ether1"}`, &added)
+	if status != http.StatusCreated || added[".id"] != "*1" {
+		t.Fatalf("PUT=%d %v; want 201 *1", status, added)
+	}
+
+	var e httpgw.Error
+	status = do(t, "PUT", ts.URL+"/ip/address", `{"address":"192.0.2.1/24","interface":"ether1"}`, &e)
+	if status != http.StatusBadRequest || e.Error != "failure: already have such address" {
+		t.Fatalf("duplicate PUT=%d %v; want 400", status, e)
+	}
+
+	var items []map[string]string
+	status = do(t, "GET", ts.URL+"/interface?disabled=false&name=ether2&.proplist=name,mtu", "", &items)
+	if status != http.StatusOK || len(items) != 1 || items[0]["name"] != "ether2" || items[0]["type"] != "" {
+		t.Fatalf("GET=%d %v; want ether2 with name and mtu only", status, items)
+	}
+
+	var item map[string]string
+	status = do(t, "PATCH", ts.URL+"/ip/address/*1", `{"comment":"uplink"}`, &item)
+	if status != http.StatusOK || item["comment"] != "uplink" {
+		t.Fatalf("PATCH=%d %v; want updated item", status, item)
+	}
+
+	status = do(t, "DELETE", ts.URL+"/ip/address/*1", "", nil)
+	if status != http.StatusNoContent || len(d.Items("/ip/address")) != 0 {
+		t.Fatalf("DELETE=%d; want 204 and item removed", status)
+	}
+	status = do(t, "GET", ts.URL+"/ip/address/*1", "", &e)
+	if status != http.StatusNotFound {
+		t.Fatalf("GET removed item=%d; want 404", status)
+	}
+	status = do(t, "DELETE", ts.URL+"/ip/address/*1", "", &e)
+	if status != http.StatusNotFound || e.Error != "no such item" {
+		t.Fatalf("DELETE removed item=%d %v; want 404", status, e)
+	}
+
+	var reply httpgw.Reply
+	status = do(t, "POST", ts.URL+"/system/identity/set", `{"name":"gw"}`, &reply)
+	if status != http.StatusOK {
+		t.Fatalf("POST=%d; want 200", status)
+	}
+	status = do(t, "GET", ts.URL+"/system/identity", "", &items)
+	if status != http.StatusOK || items[0]["name"] != "gw" {
+		t.Fatalf("GET identity=%d %v; want gw", status, items)
+	}
+
+	status = do(t, "PUT", ts.URL+"/ip/address", `{"address":`, &e)
+	if status != http.StatusBadRequest {
+		t.Fatalf("PUT invalid JSON=%d; want 400", status)
+	}
+}
+
+func TestStream(t *testing.T) {
+	for _, sse := range []bool{false, true} {
+		d, ts := newGateway(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/ip/firewall/address-list/listen", nil)
+		if sse {
+			req.Header.Set("Accept", "text/event-stream")
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; d.Listeners("/ip/firewall/address-list") == 0; i++ {
+			if i == 100 {
+				t.Fatal("listen not started")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		d.Add("/ip/firewall/address-list", map[string]string{"list": "block", "address": "192.0.2.9"})
+		line, err := bufio.NewReader(res.Body).ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{".id":"*1","address":"192.0.2.9","disabled":"false","dynamic":"false","list":"block"}`
+		if sse {
+			want = "event: re\n"
+		} else {
+			want += "\n"
+		}
+		if line != want {
+			t.Fatalf("sse=%v: line=%q; want %q", sse, line, want)
+		}
+
+		cancel()
+		res.Body.Close()
+		for i := 0; d.Listeners("/ip/firewall/address-list") != 0; i++ {
+			if i == 100 {
+				t.Fatalf("sse=%v: listen not cancelled on device", sse)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestStreamQuery(t *testing.T) {
+	// echo returns the words of the command, the query in order
+	echo := server.HandlerFunc(func(w server.ResponseWriter, cmd *server.Command) {
+		w.Re(proto.Pair{Key: "query", Value: strings.Join(cmd.Query, " ")}, proto.Pair{Key: "proplist", Value: cmd.Map[".proplist"]})
+		w.Done()
+	})
+	s, c := net.Pipe()
+	go (&server.Server{Handler: echo}).ServeConn(s)
+	client, err := routeros.NewClient(c, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Login("admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	// New switches the client to asynchronous mode
+	ts := httptest.NewServer(httpgw.New(client))
+	t.Cleanup(ts.Close)
+
+	res, err := http.Get(ts.URL + "/ip/address/listen?list=a&.proplist=address&disabled=false&comment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	line, err := bufio.NewReader(res.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"proplist":"address","query":"list=a disabled=false comment"}` + "\n"
+	if line != want {
+		t.Fatalf("line=%q; want %q", line, want)
+	}
+}
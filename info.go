@@ -0,0 +1,288 @@
+package routeros
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is a RouterOS version such as 7.15.3 (stable).
+type Version struct {
+	Major, Minor, Patch int
+	// Pre is the pre-release suffix, e.g. beta2 or rc1.
+	Pre string
+	// Channel is the release channel, e.g. stable, long-term or testing.
+	Channel string
+}
+
+// ParseVersion parses a version as printed by /system/resource, e.g.
+// "7.15.3 (stable)", "6.49.10 (long-term)" or "7.16rc2 (testing)". The
+// channel is optional.
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	s = strings.TrimSpace(s)
+	num, channel, ok := strings.Cut(s, " ")
+	if ok {
+		v.Channel = strings.Trim(channel, "()")
+	}
+
+	// the pre-release suffix starts at the first letter
+	if i := strings.IndexFunc(num, func(r rune) bool { return r >= 'a' && r <= 'z' }); i >= 0 {
+		num, v.Pre = num[:i], num[i:]
+	}
+	parts := strings.Split(num, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Version{}, fmt.Errorf("RouterOS: invalid version %q", s)
+	}
+	dst := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("RouterOS: invalid version %q", s)
+		}
+		*dst[i] = n
+	}
+	return v, nil
+}
+
+// Compare returns -1, 0 or +1 depending on whether v is older, equal or newer
+// than o. A pre-release is older than the release with the same number, the
+// channel is ignored.
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		switch {
+		case d < 0:
+			return -1
+		case d > 0:
+			return 1
+		}
+	}
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	}
+	return comparePre(v.Pre, o.Pre)
+}
+
+// comparePre compares suffixes like beta2 and rc10 by name, then by number.
+func comparePre(a, b string) int {
+	split := func(s string) (string, int) {
+		i := strings.IndexFunc(s, func(r rune) bool { return r >= '0' && r <= '9' })
+		if i < 0 {
+			return s, 0
+		}
+		n, _ := strconv.Atoi(s[i:])
+		return s[:i], n
+	}
+	an, ai := split(a)
+	bn, bi := split(b)
+	switch {
+	case an != bn:
+		return strings.Compare(an, bn)
+	case ai < bi:
+		return -1
+	case ai > bi:
+		return 1
+	}
+	return 0
+}
+
+// AtLeast reports whether v is the same as or newer than version, e.g.
+// "7.10". It is false if version is invalid.
+func (v Version) AtLeast(version string) bool {
+	o, err := ParseVersion(version)
+	return err == nil && v.Compare(o) >= 0
+}
+
+func (v Version) String() string {
+	s := strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor)
+	if v.Patch != 0 {
+		s += "." + strconv.Itoa(v.Patch)
+	}
+	s += v.Pre
+	if v.Channel != "" {
+		s += " (" + v.Channel + ")"
+	}
+	return s
+}
+
+// Package is an installed package from /system/package.
+type Package struct {
+	Name     string
+	Version  string
+	Disabled bool
+}
+
+// Info is the metadata of a device.
+type Info struct {
+	Version      Version
+	Architecture string
+	// BoardName is e.g. CHR or hAP ac^2.
+	BoardName string
+	// RouterBoard is false for CHR and x86 installations, which have no
+	// model and serial number.
+	RouterBoard  bool
+	Model        string
+	SerialNumber string
+	Identity     string
+	// Uptime is the uptime at the time the metadata was fetched.
+	Uptime       time.Duration
+	Packages     []Package
+	LicenseLevel string
+}
+
+// Info returns the metadata of the device. It is fetched with the print
+// commands of /system/resource, /system/identity, /system/routerboard,
+// /system/package and /system/license on the first call and cached for the
+// lifetime of the connection. ctx is checked between the commands.
+func (c *Client) Info(ctx context.Context) (*Info, error) {
+	c.infoMu.Lock()
+	defer c.infoMu.Unlock()
+	if c.info != nil {
+		return c.info, nil
+	}
+	info, err := fetchInfo(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	c.info = info
+	return info, nil
+}
+
+// VersionAtLeast reports whether the device runs version, e.g. "7.10", or a
+// newer one. See Info.
+func (c *Client) VersionAtLeast(version string) (bool, error) {
+	o, err := ParseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	info, err := c.Info(context.Background())
+	if err != nil {
+		return false, err
+	}
+	return info.Version.Compare(o) >= 0, nil
+}
+
+func fetchInfo(ctx context.Context, r Runner) (*Info, error) {
+	info := &Info{}
+	printMenu := func(path string) (*Reply, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return r.Print(path)
+	}
+
+	res, err := printMenu("/system/resource")
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Re) == 0 {
+		return nil, errors.New("RouterOS: /system/resource/print returned no data")
+	}
+	m := res.Re[0].Map
+	info.Version, err = ParseVersion(m["version"])
+	if err != nil {
+		return nil, err
+	}
+	info.Architecture = m["architecture-name"]
+	info.BoardName = m["board-name"]
+	info.Uptime, _ = ParseDuration(m["uptime"])
+
+	res, err = printMenu("/system/identity")
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Re) > 0 {
+		info.Identity = res.Re[0].Map["name"]
+	}
+
+	// x86 and CHR devices may not have the routerboard menu
+	res, err = printMenu("/system/routerboard")
+	var devErr *DeviceError
+	if err != nil && !errors.As(err, &devErr) {
+		return nil, err
+	}
+	if err == nil && len(res.Re) > 0 {
+		m := res.Re[0].Map
+		info.RouterBoard = m["routerboard"] == "true"
+		info.Model = m["model"]
+		info.SerialNumber = m["serial-number"]
+	}
+
+	res, err = printMenu("/system/package")
+	if err != nil {
+		return nil, err
+	}
+	for _, re := range res.Re {
+		info.Packages = append(info.Packages, Package{
+			Name:     re.Map["name"],
+			Version:  re.Map["version"],
+			Disabled: re.Map["disabled"] == "true",
+		})
+	}
+
+	// the license menu may be restricted, the level is optional
+	res, err = printMenu("/system/license")
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err == nil && len(res.Re) > 0 {
+		info.LicenseLevel = res.Re[0].Map["level"]
+		if info.LicenseLevel == "" {
+			// RouterOS 6 on RouterBOARDs
+			info.LicenseLevel = res.Re[0].Map["nlevel"]
+		}
+	}
+	return info, nil
+}
+
+// ParseDuration parses a duration as printed by RouterOS, e.g. 1w2d3h4m5s,
+// 00:01:30 or 1d02:03:04.
+func ParseDuration(s string) (time.Duration, error) {
+	var d time.Duration
+	rest := s
+	units := map[byte]time.Duration{
+		'w': 7 * 24 * time.Hour,
+		'd': 24 * time.Hour,
+		'h': time.Hour,
+		'm': time.Minute,
+		's': time.Second,
+	}
+	for rest != "" {
+		i := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+		if i <= 0 {
+			return 0, fmt.Errorf("RouterOS: invalid duration %q", s)
+		}
+		if rest[i] == ':' {
+			break
+		}
+		n, _ := strconv.Atoi(rest[:i])
+		if strings.HasPrefix(rest[i:], "ms") {
+			d += time.Duration(n) * time.Millisecond
+			rest = rest[i+2:]
+			continue
+		}
+		unit, ok := units[rest[i]]
+		if !ok {
+			return 0, fmt.Errorf("RouterOS: invalid duration %q", s)
+		}
+		d += time.Duration(n) * unit
+		rest = rest[i+1:]
+	}
+	if rest != "" {
+		var h, m, sec int
+		_, err := fmt.Sscanf(rest, "%d:%d:%d", &h, &m, &sec)
+		if err != nil {
+			return 0, fmt.Errorf("RouterOS: invalid duration %q", s)
+		}
+		d += time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second
+	}
+	return d, nil
+}
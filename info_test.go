@@ -0,0 +1,155 @@
+package routeros_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/server"
+	"github.com/swoga/go-routeros/server/servertest"
+	"github.com/swoga/go-routeros/sim"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in   string
+		want routeros.Version
+		err  bool
+	}{
+		{"7.15.3 (stable)", routeros.Version{Major: 7, Minor: 15, Patch: 3, Channel: "stable"}, false},
+		{"6.49.10 (long-term)", routeros.Version{Major: 6, Minor: 49, Patch: 10, Channel: "long-term"}, false},
+		{"7.16rc2 (testing)", routeros.Version{Major: 7, Minor: 16, Pre: "rc2", Channel: "testing"}, false},
+		{"7.10", routeros.Version{Major: 7, Minor: 10}, false},
+		{"7", routeros.Version{}, true},
+		{"seven.1", routeros.Version{}, true},
+	}
+	for _, tt := range tests {
+		got, err := routeros.ParseVersion(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseVersion(%q)=%+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+		if err == nil && got.String() != tt.in {
+			t.Errorf("String()=%q; want %q", got, tt.in)
+		}
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		v, min string
+		want   bool
+	}{
+		{"7.15.3", "7.10", true},
+		{"7.10", "7.10", true},
+		{"7.9.2", "7.10", false},
+		{"6.49.10", "7.1", false},
+		{"7.16rc2", "7.16", false},
+		{"7.16rc2", "7.16beta9", true},
+		{"7.16rc10", "7.16rc2", true},
+		{"7.16", "invalid", false},
+	}
+	for _, tt := range tests {
+		v, err := routeros.ParseVersion(tt.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := v.AtLeast(tt.min); got != tt.want {
+			t.Errorf("%s.AtLeast(%s)=%v; want %v", tt.v, tt.min, got, tt.want)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{"1w2d3h4m5s", 7*24*time.Hour + 2*24*time.Hour + 3*time.Hour + 4*time.Minute + 5*time.Second, false},
+		{"5m30s", 5*time.Minute + 30*time.Second, false},
+		{"150ms", 150 * time.Millisecond, false},
+		{"00:01:30", 90 * time.Second, false},
+		{"1d02:03:04", 26*time.Hour + 3*time.Minute + 4*time.Second, false},
+		{"", 0, false},
+		{"5x", 0, true},
+		{"h", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := routeros.ParseDuration(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseDuration(%q)=%v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestInfo(t *testing.T) {
+	d, addr := startSim(t)
+	d.Set("/system/identity", "", map[string]string{"name": "core1"})
+
+	c, err := routeros.Connect(context.Background(), addr, routeros.WithCredentials("admin", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	info, err := c.Info(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version.String() != "7.15.3 (stable)" || info.Identity != "core1" ||
+		info.Architecture != "x86_64" || info.BoardName != "CHR" || info.RouterBoard ||
+		info.LicenseLevel != "p1" {
+		t.Fatalf("Info=%+v", info)
+	}
+	if len(info.Packages) != 1 || info.Packages[0] != (routeros.Package{Name: "routeros", Version: "7.15.3"}) {
+		t.Fatalf("Packages=%+v", info.Packages)
+	}
+
+	// the result is cached
+	d.Set("/system/identity", "", map[string]string{"name": "core2"})
+	info2, _ := c.Info(context.Background())
+	if info2 != info {
+		t.Fatal("Info was fetched again")
+	}
+
+	ok, err := c.VersionAtLeast("7.10")
+	if err != nil || !ok {
+		t.Fatalf("VersionAtLeast(7.10)=%v, %v; want true", ok, err)
+	}
+	ok, err = c.VersionAtLeast("7.16")
+	if err != nil || ok {
+		t.Fatalf("VersionAtLeast(7.16)=%v, %v; want false", ok, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c2, err := routeros.Connect(context.Background(), addr, routeros.WithCredentials("admin", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	_, err = c2.Info(ctx)
+	if err != context.Canceled {
+		t.Fatalf("Info with cancelled context=%v; want context.Canceled", err)
+	}
+}
+
+func TestInfoWithoutRouterboard(t *testing.T) {
+	d := sim.New()
+	c := servertest.Dial(t, &server.Server{Handler: server.HandlerFunc(func(w server.ResponseWriter, cmd *server.Command) {
+		if cmd.Word == "/system/routerboard/print" {
+			w.Trap(server.NoCategory, "no such command prefix")
+			w.Done()
+			return
+		}
+		d.ServeAPI(w, cmd)
+	})})
+	info, err := c.Info(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.RouterBoard || info.Identity == "" || len(info.Packages) == 0 {
+		t.Fatalf("Info=%+v", info)
+	}
+}
@@ -0,0 +1,154 @@
+package routeros
+
+import (
+	"errors"
+	"time"
+)
+
+var errKeepalive = errors.New("RouterOS: keepalive failed, connection considered dead")
+
+// WithKeepalive starts Client.Keepalive after connecting.
+func WithKeepalive(interval time.Duration, maxFailures int) Option {
+	return func(cc *connectConfig) {
+		cc.keepaliveInterval = interval
+		cc.keepaliveFailures = maxFailures
+	}
+}
+
+// Keepalive probes the connection with /system/identity/print whenever
+// nothing has been received for interval, so NAT and firewall state is kept
+// and a dead connection is noticed before the next command runs into its
+// timeout. A probe fails if its reply does not arrive within interval. After
+// maxFailures consecutive failures the connection is closed, Healthy reports
+// false and Done is closed. A probe is not counted as failed while the read
+// loop is busy handing replies to a slow listen consumer.
+//
+// Keepalive puts the client into asynchronous mode, see Async. Only the first
+// call starts probing, later calls do nothing.
+func (c *Client) Keepalive(interval time.Duration, maxFailures int) {
+	if interval <= 0 || !c.keepaliveOn.CompareAndSwap(false, true) {
+		return
+	}
+	if maxFailures < 1 {
+		maxFailures = 1
+	}
+	if !c.async {
+		c.Async()
+	}
+	c.lastRead.Store(time.Now().UnixNano())
+	go c.keepalive(interval, maxFailures)
+}
+
+func (c *Client) keepalive(interval time.Duration, maxFailures int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		if time.Since(time.Unix(0, c.lastRead.Load())) < interval || c.delivering.Load() {
+			failures = 0
+			continue
+		}
+		start := time.Now()
+		rtt, err := c.probe(interval)
+		if errors.Is(err, errShuttingDown) {
+			return
+		}
+		if err == nil {
+			c.latency.Store(int64(rtt))
+			failures = 0
+			continue
+		}
+		if c.delivering.Load() || c.lastRead.Load() > start.UnixNano() {
+			// the reply may be stuck behind sentences for a slow consumer,
+			// the connection itself is alive
+			failures = 0
+			continue
+		}
+		failures++
+		if failures >= maxFailures {
+			c.fail(errKeepalive)
+			return
+		}
+	}
+}
+
+// probe runs a cheap command and returns its round-trip time.
+func (c *Client) probe(timeout time.Duration) (time.Duration, error) {
+	// probes are commands as well, so none is sent while Shutdown drains
+	err := c.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer c.finish()
+	start := time.Now()
+	a, err := c.sendAsync([]string{"/system/identity/print", "=.proplist=name"})
+	if err != nil {
+		return 0, err
+	}
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	for {
+		select {
+		case _, open := <-a.reC:
+			if !open {
+				// a trap proves the connection alive as well
+				var devErr *DeviceError
+				if a.err != nil && !errors.As(a.err, &devErr) {
+					return 0, a.err
+				}
+				return time.Since(start), nil
+			}
+		case <-t.C:
+			// a late reply is dropped like one of an unknown tag
+			c.unregister(a.tag)
+			return 0, errAsyncTimeout
+		}
+	}
+}
+
+// Latency returns the round-trip time of the last keepalive probe, or 0 if
+// there was none.
+func (c *Client) Latency() time.Duration {
+	return time.Duration(c.latency.Load())
+}
+
+// Healthy reports whether the client is still usable, i.e. it has not been
+// closed, the asynchronous read loop has not failed and Keepalive has not
+// given up on the connection.
+func (c *Client) Healthy() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Done returns a channel that is closed when the client is no longer usable,
+// see Healthy.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// end records err as the reason the client ended and closes c.done.
+func (c *Client) end(err error) {
+	c.endOnce.Do(func() {
+		c.endErr = err
+		close(c.done)
+		c.setState(StateClosed)
+	})
+}
+
+// fail ends the client with err and closes the connection.
+func (c *Client) fail(err error) {
+	c.end(err)
+	c.mu.Lock()
+	c.closing = true
+	c.mu.Unlock()
+	c.conn.Close()
+}
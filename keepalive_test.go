@@ -0,0 +1,142 @@
+package routeros_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+)
+
+// blackhole relays connections to addr until drop is set, after that the
+// replies are discarded like by a NAT that has forgotten the connection.
+func blackhole(t *testing.T, addr string, drop *atomic.Bool) string {
+	return startProxy(t, func(conn net.Conn) {
+		target, err := net.Dial("tcp", addr)
+		if err != nil {
+			return
+		}
+		defer target.Close()
+		go io.Copy(target, conn)
+		b := make([]byte, 4096)
+		for {
+			n, err := target.Read(b)
+			if err != nil {
+				return
+			}
+			if !drop.Load() {
+				conn.Write(b[:n])
+			}
+		}
+	})
+}
+
+func TestKeepalive(t *testing.T) {
+	_, addr := startSim(t)
+	var drop atomic.Bool
+	c, err := routeros.Connect(context.Background(), blackhole(t, addr, &drop),
+		routeros.WithCredentials("admin", ""),
+		routeros.WithKeepalive(20*time.Millisecond, 2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for c.Latency() == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !c.Healthy() {
+		t.Fatal("client unhealthy before the connection broke")
+	}
+
+	drop.Store(true)
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done not closed after keepalive failures")
+	}
+	if c.Healthy() {
+		t.Fatal("client healthy after keepalive failures")
+	}
+	_, err = c.Run("/system/identity/print")
+	if err == nil {
+		t.Fatal("Run succeeded on a dead connection")
+	}
+}
+
+func TestKeepaliveLostProbes(t *testing.T) {
+	_, addr := startSim(t)
+	var drop atomic.Bool
+	c, err := routeros.Connect(context.Background(), blackhole(t, addr, &drop),
+		routeros.WithCredentials("admin", ""),
+		routeros.WithKeepalive(10*time.Millisecond, 1000),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	drop.Store(true)
+	time.Sleep(200 * time.Millisecond)
+	// only the probe currently waiting for its reply is in flight
+	if n := len(c.InFlight()); n > 1 {
+		t.Fatalf("%d commands in flight after lost probes; want at most 1", n)
+	}
+}
+
+func TestKeepaliveSlowConsumer(t *testing.T) {
+	d, addr := startSim(t)
+	c, err := routeros.Connect(context.Background(), addr,
+		routeros.WithCredentials("admin", ""),
+		routeros.WithKeepalive(10*time.Millisecond, 1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	// a second call does not start another prober
+	c.Keepalive(10*time.Millisecond, 1)
+
+	// without a queue the read loop blocks until the sentence is taken
+	l, err := c.ListenArgsQueue([]string{"/interface/listen"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for d.Listeners("/interface") == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	d.Set("/interface", "ether1", map[string]string{"comment": "a"})
+	d.Set("/interface", "ether1", map[string]string{"comment": "b"})
+	time.Sleep(200 * time.Millisecond)
+	if !c.Healthy() {
+		t.Fatalf("client closed while a listen consumer was slow: %v", c.Err())
+	}
+
+	<-l.Chan()
+	<-l.Chan()
+	_, err = l.Cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDoneOnClose(t *testing.T) {
+	_, addr := startSim(t)
+	c, err := routeros.Connect(context.Background(), addr, routeros.WithCredentials("admin", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Healthy() {
+		t.Fatal("new client unhealthy")
+	}
+	c.Close()
+	select {
+	case <-c.Done():
+	default:
+		t.Fatal("Done not closed by Close")
+	}
+}
@@ -1,7 +1,11 @@
 package routeros
 
 import (
-	"strconv"
+	"context"
+	"errors"
+	"iter"
+	"sync"
+	"time"
 
 	"github.com/swoga/go-routeros/proto"
 )
@@ -11,8 +15,24 @@ import (
 // RouterOS sentence that caused it to be closed.
 type ListenReply struct {
 	chanReply
-	Done *proto.Sentence
-	c    *Client
+	Done    *proto.Sentence
+	cancel  func() (*Reply, error)
+	ended   chan struct{}
+	timeout time.Duration
+
+	cancelled  chan struct{}
+	cancelOnce sync.Once
+	// client is set for listens of a Client, to report their end.
+	client *Client
+}
+
+func newListenReply(queueSize int) *ListenReply {
+	l := &ListenReply{
+		ended:     make(chan struct{}),
+		cancelled: make(chan struct{}),
+	}
+	l.reC = make(chan *proto.Sentence, queueSize)
+	return l
 }
 
 // Chan returns a channel for receiving !re RouterOS sentences.
@@ -21,9 +41,99 @@ func (l *ListenReply) Chan() <-chan *proto.Sentence {
 	return l.reC
 }
 
-// Cancel sends a cancel command to the RouterOS device.
+// Cancel calls CancelContext, limited to the client's timeout.
 func (l *ListenReply) Cancel() (*Reply, error) {
-	return l.c.Run("/cancel", "=tag="+l.tag)
+	ctx := context.Background()
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+	reply, err := l.CancelContext(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = errCancelTimeout
+	}
+	return reply, err
+}
+
+// CancelContext sends a cancel command to the RouterOS device and waits
+// until the device has ended the command or ctx is done. On success the
+// channel returned by Chan() is closed and Done is set. Sentences arriving
+// after CancelContext was called that do not fit into the channel's buffer
+// are discarded.
+//
+// If the command has already ended, e.g. because the connection broke,
+// CancelContext returns nil, nil.
+func (l *ListenReply) CancelContext(ctx context.Context) (*Reply, error) {
+	select {
+	case <-l.ended:
+		return nil, nil
+	default:
+	}
+	l.cancelOnce.Do(func() {
+		close(l.cancelled)
+	})
+
+	reply, err := l.cancel()
+	if err != nil {
+		select {
+		case <-l.ended:
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+	select {
+	case <-l.ended:
+		return reply, nil
+	case <-ctx.Done():
+		return reply, ctx.Err()
+	}
+}
+
+// send delivers sen on the channel. Once the command is being cancelled,
+// sen is dropped instead of blocking, so the confirmation can be read.
+func (l *ListenReply) send(sen *proto.Sentence) {
+	select {
+	case l.reC <- sen:
+	default:
+		select {
+		case l.reC <- sen:
+		case <-l.cancelled:
+		}
+	}
+}
+
+func (l *ListenReply) close(err error) {
+	l.chanReply.close(err)
+	if l.client != nil {
+		l.client.commandDone(l.command, l.tag, true, l.started, err)
+	}
+	close(l.ended)
+}
+
+// All returns an iterator over the !re sentences of l. After the last
+// sentence it yields the error of l once, if there is one. Breaking out of
+// the loop cancels the command and waits until it has ended.
+//
+//	for sen, err := range l.All() {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (l *ListenReply) All() iter.Seq2[*proto.Sentence, error] {
+	return func(yield func(*proto.Sentence, error) bool) {
+		for sen := range l.reC {
+			if !yield(sen, nil) {
+				l.Cancel()
+				return
+			}
+		}
+		if err := l.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
 }
 
 // Listen simply calls ListenArgsQueue() with queueSize set to c.Queue.
@@ -37,39 +147,52 @@ func (c *Client) ListenArgs(sentence []string) (*ListenReply, error) {
 }
 
 // ListenArgsQueue sends a sentence to the RouterOS device and returns immediately.
+// A .tag word in sentence sets the tag of the command, which must not be in
+// use, instead of a generated one.
 func (c *Client) ListenArgsQueue(sentence []string, queueSize int) (*ListenReply, error) {
 	if !c.async {
 		c.Async()
 	}
+	err := c.begin()
+	if err != nil {
+		return nil, err
+	}
 
-	l := &ListenReply{c: c}
-	l.tag = "l" + strconv.FormatUint(c.nextTag(), 10)
-	l.reC = make(chan *proto.Sentence, queueSize)
+	sentence, tag := splitTag(sentence)
+	l := newListenReply(queueSize)
+	l.cancel = func() (*Reply, error) {
+		return c.Run("/cancel", "=tag="+l.tag)
+	}
+	l.timeout = c.timeout
+	l.command = command(sentence)
+	l.client = c
+	err = c.register(l, "l", tag)
+	if err != nil {
+		c.finish()
+		return nil, err
+	}
 
 	c.w.BeginSentence()
 	for _, word := range sentence {
 		c.w.WriteWord(word)
 	}
 	c.w.WriteWord(".tag=" + l.tag)
-
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	err := c.w.EndSentence()
+	c.commandStarted(l.command, true)
+	err = c.w.EndSentence()
 	if err != nil {
+		if c.unregister(l.tag) {
+			c.finish()
+			c.commandDone(l.command, l.tag, true, l.started, err)
+		}
 		return nil, err
 	}
-	if c.tags == nil {
-		return nil, errAsyncLoopEnded
-	}
-	c.tags[l.tag] = l
 	return l, nil
 }
 
 func (l *ListenReply) processSentence(sen *proto.Sentence) (bool, error) {
 	switch sen.Word {
 	case "!re":
-		l.reC <- sen
+		l.send(sen)
 	case "!done":
 		l.Done = sen
 		return true, nil
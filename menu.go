@@ -0,0 +1,77 @@
+package routeros
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Runner is implemented by the clients of the binary API and the REST API,
+// so code can be written against either of them.
+type Runner interface {
+	Run(sentence ...string) (*Reply, error)
+	RunArgs(sentence []string) (*Reply, error)
+	Print(path string, args ...string) (*Reply, error)
+	Add(path string, attrs map[string]string) (string, error)
+	Set(path, id string, attrs map[string]string) error
+	Remove(path string, ids ...string) error
+	Listen(sentence ...string) (*ListenReply, error)
+}
+
+var (
+	_ Runner = (*Client)(nil)
+	_ Runner = (*RESTClient)(nil)
+)
+
+// Print runs the print command of the menu at path. args are passed as is,
+// e.g. "?disabled=false" or "=.proplist=name".
+func (c *Client) Print(path string, args ...string) (*Reply, error) {
+	return c.RunArgs(printSentence(path, args))
+}
+
+// Add runs the add command of the menu at path and returns the .id of the
+// new item.
+func (c *Client) Add(path string, attrs map[string]string) (string, error) {
+	r, err := c.RunArgs(addSentence(path, attrs))
+	if err != nil {
+		return "", err
+	}
+	return r.Done.Map["ret"], nil
+}
+
+// Set runs the set command of the menu at path for the item with id.
+func (c *Client) Set(path, id string, attrs map[string]string) error {
+	_, err := c.RunArgs(setSentence(path, id, attrs))
+	return err
+}
+
+// Remove runs the remove command of the menu at path for the items with ids.
+func (c *Client) Remove(path string, ids ...string) error {
+	_, err := c.RunArgs(removeSentence(path, ids))
+	return err
+}
+
+func printSentence(path string, args []string) []string {
+	return append([]string{path + "/print"}, args...)
+}
+
+func addSentence(path string, attrs map[string]string) []string {
+	return append([]string{path + "/add"}, attrWords(attrs)...)
+}
+
+func setSentence(path, id string, attrs map[string]string) []string {
+	return append([]string{path + "/set", "=.id=" + id}, attrWords(attrs)...)
+}
+
+func removeSentence(path string, ids []string) []string {
+	return []string{path + "/remove", "=.id=" + strings.Join(ids, ",")}
+}
+
+// attrWords returns the attribute words for attrs sorted by key.
+func attrWords(attrs map[string]string) []string {
+	words := make([]string, 0, len(attrs))
+	for _, k := range slices.Sorted(maps.Keys(attrs)) {
+		words = append(words, "="+k+"="+attrs[k])
+	}
+	return words
+}
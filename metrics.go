@@ -0,0 +1,132 @@
+package routeros
+
+import (
+	"net"
+	"time"
+
+	"github.com/swoga/go-routeros/proto"
+)
+
+// Metrics receives usage events of a Client, see WithMetrics. Methods are
+// called synchronously, possibly from several goroutines, and must not
+// block. The metrics package provides an implementation exposing them to
+// Prometheus.
+type Metrics interface {
+	// CommandStarted is called when a command is sent.
+	CommandStarted(command string, listen bool)
+	// CommandDone is called when a command has ended.
+	CommandDone(stats CommandStats)
+	// SentenceRead is called for every sentence read from the device.
+	SentenceRead(sen *proto.Sentence)
+	// SentenceWritten is called for every sentence sent to the device.
+	SentenceWritten()
+	// BytesRead and BytesWritten are called with the number of bytes read
+	// from and written to the connection.
+	BytesRead(n int)
+	BytesWritten(n int)
+}
+
+// CommandStats describes a command that has ended.
+type CommandStats struct {
+	Command string
+	// Tag is empty in synchronous mode.
+	Tag    string
+	Listen bool
+	// Duration is the time from sending the command until its reply, or
+	// until the end of a listen command.
+	Duration time.Duration
+	Err      error
+}
+
+// WithMetrics sets m with Client.SetMetrics before connecting, so m also
+// sees the login.
+func WithMetrics(m Metrics) Option {
+	return func(cc *connectConfig) {
+		cc.metrics = m
+	}
+}
+
+// SetMetrics makes the client report usage events to m. A nil m stops the
+// reporting.
+func (c *Client) SetMetrics(m Metrics) {
+	if m == nil {
+		c.metrics.Store(nil)
+		return
+	}
+	c.metrics.Store(&m)
+}
+
+func (c *Client) hook() Metrics {
+	if m := c.metrics.Load(); m != nil {
+		return *m
+	}
+	return nil
+}
+
+func (c *Client) commandStarted(command string, listen bool) {
+	if m := c.hook(); m != nil {
+		m.CommandStarted(command, listen)
+	}
+}
+
+func (c *Client) commandDone(command, tag string, listen bool, start time.Time, err error) {
+	if m := c.hook(); m != nil {
+		m.CommandDone(CommandStats{
+			Command:  command,
+			Tag:      tag,
+			Listen:   listen,
+			Duration: time.Since(start),
+			Err:      err,
+		})
+	}
+}
+
+// meteredConn reports the bytes transferred on a connection.
+type meteredConn struct {
+	net.Conn
+	c *Client
+}
+
+func (mc *meteredConn) Read(b []byte) (int, error) {
+	n, err := mc.Conn.Read(b)
+	if m := mc.c.hook(); m != nil && n > 0 {
+		m.BytesRead(n)
+	}
+	return n, err
+}
+
+func (mc *meteredConn) Write(b []byte) (int, error) {
+	n, err := mc.Conn.Write(b)
+	if m := mc.c.hook(); m != nil && n > 0 {
+		m.BytesWritten(n)
+	}
+	return n, err
+}
+
+// meteredReader reports the sentences read.
+type meteredReader struct {
+	proto.Reader
+	c *Client
+}
+
+func (r *meteredReader) ReadSentence(setDeadline bool) (*proto.Sentence, error) {
+	sen, err := r.Reader.ReadSentence(setDeadline)
+	if m := r.c.hook(); m != nil && err == nil {
+		m.SentenceRead(sen)
+	}
+	return sen, err
+}
+
+// meteredWriter reports the sentences written.
+type meteredWriter struct {
+	proto.Writer
+	c *Client
+}
+
+func (w *meteredWriter) EndSentence() error {
+	err := w.Writer.EndSentence()
+	if m := w.c.hook(); m != nil && err == nil {
+		m.SentenceWritten()
+	}
+	return err
+}
@@ -0,0 +1,288 @@
+/*
+Package metrics collects the usage of routeros.Client values and exposes it
+in the Prometheus text format, without depending on a Prometheus library.
+
+	col := metrics.New()
+	c, err := routeros.Connect(ctx, address, routeros.WithMetrics(col), ...)
+	...
+	col.Watch(c)
+	http.Handle("/metrics", col)
+
+The following metrics are exposed:
+
+	routeros_client_commands_total{command,outcome}        counter
+	routeros_client_traps_total{category}                  counter
+	routeros_client_read_bytes_total                       counter
+	routeros_client_written_bytes_total                    counter
+	routeros_client_read_sentences_total                   counter
+	routeros_client_written_sentences_total                counter
+	routeros_client_command_duration_seconds{command}      histogram
+	routeros_client_commands_in_flight                     gauge
+	routeros_client_listen_queue_length{command}           gauge
+
+The outcome of a command is ok, trap (a routeros.DeviceError) or error. The
+duration histogram leaves out listen commands, whose duration is their
+lifetime. The queue length is the number of sentences waiting in the
+channels of all listen commands with the same path. It is only reported for
+clients passed to Watch.
+
+Header and Sample format lines of the text format for programs exposing
+metrics of their own, e.g. of the devices.
+*/
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/proto"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the duration histogram
+// used by New.
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Collector implements routeros.Metrics and http.Handler. It can be shared
+// by several clients.
+type Collector struct {
+	buckets []float64
+
+	mu               sync.Mutex
+	commands         map[commandKey]uint64
+	traps            map[string]uint64
+	durations        map[string]*histogram
+	inFlight         int64
+	bytesRead        uint64
+	bytesWritten     uint64
+	sentencesRead    uint64
+	sentencesWritten uint64
+	clients          map[*routeros.Client]struct{}
+}
+
+type commandKey struct {
+	command, outcome string
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// New returns a Collector using DefaultBuckets.
+func New() *Collector {
+	return NewWithBuckets(DefaultBuckets)
+}
+
+// NewWithBuckets returns a Collector using the given upper bounds in seconds
+// for the duration histogram.
+func NewWithBuckets(buckets []float64) *Collector {
+	b := slices.Clone(buckets)
+	slices.Sort(b)
+	return &Collector{
+		buckets:   b,
+		commands:  make(map[commandKey]uint64),
+		traps:     make(map[string]uint64),
+		durations: make(map[string]*histogram),
+		clients:   make(map[*routeros.Client]struct{}),
+	}
+}
+
+var _ routeros.Metrics = (*Collector)(nil)
+
+// Instrument makes c report to col and watches it, see Watch.
+func (col *Collector) Instrument(c *routeros.Client) {
+	c.SetMetrics(col)
+	col.Watch(c)
+}
+
+// Watch adds the listen commands of c to the queue length gauge until c
+// has ended.
+func (col *Collector) Watch(c *routeros.Client) {
+	col.mu.Lock()
+	col.clients[c] = struct{}{}
+	col.mu.Unlock()
+	go func() {
+		<-c.Done()
+		col.mu.Lock()
+		delete(col.clients, c)
+		col.mu.Unlock()
+	}()
+}
+
+// CommandStarted implements routeros.Metrics.
+func (col *Collector) CommandStarted(command string, listen bool) {
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	col.inFlight++
+}
+
+// CommandDone implements routeros.Metrics.
+func (col *Collector) CommandDone(stats routeros.CommandStats) {
+	outcome := "ok"
+	var devErr *routeros.DeviceError
+	if errors.As(stats.Err, &devErr) {
+		outcome = "trap"
+	} else if stats.Err != nil {
+		outcome = "error"
+	}
+
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	col.inFlight--
+	col.commands[commandKey{stats.Command, outcome}]++
+	if stats.Listen {
+		return
+	}
+	h := col.durations[stats.Command]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(col.buckets))}
+		col.durations[stats.Command] = h
+	}
+	s := stats.Duration.Seconds()
+	if i, _ := slices.BinarySearch(col.buckets, s); i < len(col.buckets) {
+		h.counts[i]++
+	}
+	h.sum += s
+	h.count++
+}
+
+// SentenceRead implements routeros.Metrics.
+func (col *Collector) SentenceRead(sen *proto.Sentence) {
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	col.sentencesRead++
+	if sen.Word == "!trap" {
+		category := sen.Map["category"]
+		if category == "" {
+			category = "none"
+		}
+		col.traps[category]++
+	}
+}
+
+// SentenceWritten implements routeros.Metrics.
+func (col *Collector) SentenceWritten() {
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	col.sentencesWritten++
+}
+
+// BytesRead implements routeros.Metrics.
+func (col *Collector) BytesRead(n int) {
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	col.bytesRead += uint64(n)
+}
+
+// BytesWritten implements routeros.Metrics.
+func (col *Collector) BytesWritten(n int) {
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	col.bytesWritten += uint64(n)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (col *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	col.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text format to w.
+func (col *Collector) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	col.write(&b)
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (col *Collector) write(b *strings.Builder) {
+	col.mu.Lock()
+	clients := make([]*routeros.Client, 0, len(col.clients))
+	for c := range col.clients {
+		clients = append(clients, c)
+	}
+
+	header(b, "commands_total", "counter", "Commands by path and outcome.")
+	keys := make([]commandKey, 0, len(col.commands))
+	for k := range col.commands {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b commandKey) int {
+		return strings.Compare(a.command+"\x00"+a.outcome, b.command+"\x00"+b.outcome)
+	})
+	for _, k := range keys {
+		sample(b, "commands_total", float64(col.commands[k]), "command", k.command, "outcome", k.outcome)
+	}
+
+	header(b, "traps_total", "counter", "Traps received by category.")
+	for _, category := range sortedKeys(col.traps) {
+		sample(b, "traps_total", float64(col.traps[category]), "category", category)
+	}
+
+	for _, c := range []struct {
+		name, help string
+		v          uint64
+	}{
+		{"read_bytes_total", "Bytes read from devices.", col.bytesRead},
+		{"written_bytes_total", "Bytes written to devices.", col.bytesWritten},
+		{"read_sentences_total", "Sentences read from devices.", col.sentencesRead},
+		{"written_sentences_total", "Sentences written to devices.", col.sentencesWritten},
+	} {
+		header(b, c.name, "counter", c.help)
+		sample(b, c.name, float64(c.v))
+	}
+
+	header(b, "command_duration_seconds", "histogram", "Time from sending a command until its reply.")
+	for _, command := range sortedKeys(col.durations) {
+		h := col.durations[command]
+		var cum uint64
+		for i, le := range col.buckets {
+			cum += h.counts[i]
+			sample(b, "command_duration_seconds_bucket", float64(cum), "command", command, "le", formatFloat(le))
+		}
+		sample(b, "command_duration_seconds_bucket", float64(h.count), "command", command, "le", "+Inf")
+		sample(b, "command_duration_seconds_sum", h.sum, "command", command)
+		sample(b, "command_duration_seconds_count", float64(h.count), "command", command)
+	}
+
+	header(b, "commands_in_flight", "gauge", "Commands awaiting their reply, including active listen commands.")
+	sample(b, "commands_in_flight", float64(col.inFlight))
+	col.mu.Unlock()
+
+	// InFlight locks the client, so it is not called with col.mu held.
+	header(b, "listen_queue_length", "gauge", "Sentences waiting in the channels of listen commands.")
+	queues := make(map[string]int)
+	for _, c := range clients {
+		for _, cmd := range c.InFlight() {
+			if cmd.Listen {
+				queues[cmd.Command] += cmd.Queued
+			}
+		}
+	}
+	for _, command := range sortedKeys(queues) {
+		sample(b, "listen_queue_length", float64(queues[command]), "command", command)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func header(b *strings.Builder, name, typ, help string) {
+	b.WriteString(Header("routeros_client_"+name, typ, help))
+}
+
+func sample(b *strings.Builder, name string, v float64, labels ...string) {
+	b.WriteString(Sample("routeros_client_"+name, v, labels...))
+}
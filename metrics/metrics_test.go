@@ -0,0 +1,115 @@
+package metrics_test
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/metrics"
+	"github.com/swoga/go-routeros/server/servertest"
+	"github.com/swoga/go-routeros/sim"
+)
+
+func TestCollector(t *testing.T) {
+	d := sim.New()
+	col := metrics.NewWithBuckets([]float64{10, 1})
+	c := servertest.Dial(t, d,
+		routeros.WithAsync(),
+		routeros.WithQueueSize(4),
+		routeros.WithMetrics(col),
+	)
+	col.Watch(c)
+
+	_, err := c.Run("/ip/address/print")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Run("/ip/address/add", "=address=bogus", "=interface=ether1")
+	if err == nil {
+		t.Fatal("add of an invalid address succeeded")
+	}
+	listen, err := c.Listen("/interface/listen", ".tag=ifaces")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// listens of the same path share a series
+	other, err := c.Listen("/interface/listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for d.Listeners("/interface") < 2 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	d.Set("/interface", "ether1", map[string]string{"comment": "x"})
+	for len(listen.Chan()) == 0 || len(other.Chan()) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rec := httptest.NewRecorder()
+	col.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type=%q", ct)
+	}
+	for _, want := range []string{
+		`routeros_client_commands_total{command="/login",outcome="ok"} 1`,
+		`routeros_client_commands_total{command="/ip/address/print",outcome="ok"} 1`,
+		`routeros_client_commands_total{command="/ip/address/add",outcome="trap"} 1`,
+		`routeros_client_traps_total{category="none"} 1`,
+		`routeros_client_written_sentences_total 5`,
+		`routeros_client_command_duration_seconds_bucket{command="/ip/address/print",le="1"} 1`,
+		`routeros_client_command_duration_seconds_bucket{command="/ip/address/print",le="10"} 1`,
+		`routeros_client_command_duration_seconds_bucket{command="/ip/address/print",le="+Inf"} 1`,
+		`routeros_client_command_duration_seconds_count{command="/ip/address/print"} 1`,
+		`routeros_client_commands_in_flight 2`,
+		`routeros_client_listen_queue_length{command="/interface/listen"} 2`,
+		"# TYPE routeros_client_command_duration_seconds histogram",
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+	for _, name := range []string{"read_bytes_total", "written_bytes_total", "read_sentences_total"} {
+		if strings.Contains(body, "routeros_client_"+name+" 0\n") {
+			t.Errorf("%s not counted", name)
+		}
+	}
+	if t.Failed() {
+		t.Log(body)
+	}
+
+	for _, l := range []*routeros.ListenReply{listen, other} {
+		_, err = l.Cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var b strings.Builder
+	col.WriteTo(&b)
+	for _, want := range []string{
+		`routeros_client_commands_total{command="/interface/listen",outcome="ok"} 2`,
+		`routeros_client_commands_in_flight 0`,
+	} {
+		if !strings.Contains(b.String(), want+"\n") {
+			t.Errorf("metrics lack %s after cancel", want)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	for _, tc := range []struct {
+		got, want string
+	}{
+		{metrics.Sample("up", 1), "up 1\n"},
+		{metrics.Sample("temp", 48.5, "target", `core "1"`, "sensor", "a\\b\nc"), `temp{target="core \"1\"",sensor="a\\b\nc"} 48.5` + "\n"},
+		{metrics.Sample("le", math.Inf(1)), "le +Inf\n"},
+		{metrics.Header("up", "gauge", "Whether it is up."), "# HELP up Whether it is up.\n# TYPE up gauge\n"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("got %q; want %q", tc.got, tc.want)
+		}
+	}
+}
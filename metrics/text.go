@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Header returns the HELP and TYPE lines of the metric name in the
+// Prometheus text format.
+func Header(name, typ, help string) string {
+	return "# HELP " + name + " " + help + "\n# TYPE " + name + " " + typ + "\n"
+}
+
+// Sample returns the line of a sample of the metric name with value v and
+// the given label name/value pairs in the Prometheus text format.
+func Sample(name string, v float64, labels ...string) string {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(labels[i] + `="` + escaper.Replace(labels[i+1]) + `"`)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteString(" " + formatFloat(v) + "\n")
+	return b.String()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
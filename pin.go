@@ -0,0 +1,240 @@
+package routeros
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Fingerprint returns the SHA-256 fingerprint of the public key (SPKI) of
+// cert, formatted like SHA256:<base64>. Pinning the key instead of the whole
+// certificate keeps the pin valid when the device renews a certificate for
+// the same key.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// PinMismatchError is returned when the device presents a certificate whose
+// fingerprint is not pinned.
+type PinMismatchError struct {
+	Address   string
+	Presented string
+	Expected  []string
+}
+
+func (err *PinMismatchError) Error() string {
+	msg := "RouterOS: certificate fingerprint mismatch"
+	if err.Address != "" {
+		msg += " for " + err.Address
+	}
+	return msg + ": presented " + err.Presented + ", expected " + strings.Join(err.Expected, " or ")
+}
+
+// VerifyPinned returns a function for tls.Config.VerifyPeerCertificate that
+// accepts only a leaf certificate with one of the given fingerprints. As
+// RouterOS devices usually have self-signed certificates, it is meant to be
+// used together with InsecureSkipVerify.
+func VerifyPinned(fingerprints ...string) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		presented, err := leafFingerprint(rawCerts)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(fingerprints, presented) {
+			return &PinMismatchError{Presented: presented, Expected: fingerprints}
+		}
+		return nil
+	}
+}
+
+func leafFingerprint(rawCerts [][]byte) (string, error) {
+	if len(rawCerts) == 0 {
+		return "", errors.New("RouterOS: no certificate presented")
+	}
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return "", fmt.Errorf("RouterOS: invalid certificate: %w", err)
+	}
+	return Fingerprint(cert), nil
+}
+
+// PinStore is a file of pinned fingerprints in the style of OpenSSH's
+// known_hosts. Every line holds an address and a fingerprint separated by
+// white space, empty lines and lines starting with # are ignored:
+//
+//	# router.example.com
+//	192.168.88.1:8729 SHA256:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU
+type PinStore struct {
+	path string
+
+	mu   sync.Mutex
+	pins map[string][]string
+}
+
+// OpenPinStore reads the pin store at path. A missing file is treated as
+// empty and created when the first pin is added.
+func OpenPinStore(path string) (*PinStore, error) {
+	s := &PinStore{path: path, pins: make(map[string][]string)}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("RouterOS: %s:%d: expected address and fingerprint", path, line)
+		}
+		s.pins[fields[0]] = append(s.pins[fields[0]], fields[1])
+	}
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Lookup returns the fingerprints pinned for address.
+func (s *PinStore) Lookup(address string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.pins[address])
+}
+
+// Add pins fingerprint for address and appends it to the file.
+func (s *PinStore) Add(address, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(address, fingerprint)
+}
+
+func (s *PinStore) add(address, fingerprint string) error {
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "%s %s\n", address, fingerprint)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	s.pins[address] = append(s.pins[address], fingerprint)
+	return nil
+}
+
+// VerifyFunc returns a function for tls.Config.VerifyPeerCertificate that
+// implements trust on first use for address: the first certificate presented
+// is pinned, later connections must present a certificate with a pinned
+// fingerprint.
+func (s *PinStore) VerifyFunc(address string) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		presented, err := leafFingerprint(rawCerts)
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		pins := s.pins[address]
+		if len(pins) == 0 {
+			return s.add(address, presented)
+		}
+		if !slices.Contains(pins, presented) {
+			return &PinMismatchError{Address: address, Presented: presented, Expected: slices.Clone(pins)}
+		}
+		return nil
+	}
+}
+
+// WithPinnedFingerprints connects using TLS and accepts only a device
+// certificate with one of the given fingerprints, see Fingerprint. The
+// certificate chain is not verified. A VerifyPeerCertificate set with
+// WithTLS is called after the pin check.
+func WithPinnedFingerprints(fingerprints ...string) Option {
+	return func(cc *connectConfig) {
+		cc.useTLS = true
+		cc.verify = func(string) func([][]byte, [][]*x509.Certificate) error {
+			return VerifyPinned(fingerprints...)
+		}
+	}
+}
+
+// WithPinStore connects using TLS and verifies the device certificate with
+// trust on first use against store, see PinStore.VerifyFunc. The certificate
+// chain is not verified. A VerifyPeerCertificate set with WithTLS is called
+// after the pin check.
+func WithPinStore(store *PinStore) Option {
+	return func(cc *connectConfig) {
+		cc.useTLS = true
+		cc.verify = store.VerifyFunc
+	}
+}
+
+// WithClientCertificate connects using TLS and authenticates with the
+// certificate and key in the given PEM files.
+func WithClientCertificate(certFile, keyFile string) Option {
+	return func(cc *connectConfig) {
+		cc.useTLS = true
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			cc.err = fmt.Errorf("RouterOS: client certificate: %w", err)
+			return
+		}
+		cc.certificates = append(cc.certificates, cert)
+	}
+}
+
+// tlsConfigFor returns the TLS configuration for address with server name,
+// pinning and client certificates applied.
+func (cc *connectConfig) tlsConfigFor(address string) *tls.Config {
+	cfg := cc.tlsConfig
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	cfg = cfg.Clone()
+	if cfg.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		cfg.ServerName = host
+	}
+	if cc.verify != nil {
+		cfg.InsecureSkipVerify = true
+		pinned, verify := cc.verify(address), cfg.VerifyPeerCertificate
+		cfg.VerifyPeerCertificate = pinned
+		if verify != nil {
+			cfg.VerifyPeerCertificate = func(rawCerts [][]byte, chains [][]*x509.Certificate) error {
+				err := pinned(rawCerts, chains)
+				if err != nil {
+					return err
+				}
+				return verify(rawCerts, chains)
+			}
+		}
+	}
+	cfg.Certificates = append(cfg.Certificates, cc.certificates...)
+	return cfg
+}
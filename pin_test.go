@@ -0,0 +1,214 @@
+package routeros_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/sim"
+)
+
+// selfSigned returns a new self-signed certificate like the one RouterOS
+// generates for api-ssl.
+func selfSigned(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "router"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func startTLSSim(t *testing.T, cfg *tls.Config) string {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go sim.New().Serve(l)
+	return l.Addr().String()
+}
+
+func TestConnectPinnedFingerprint(t *testing.T) {
+	cert := selfSigned(t)
+	addr := startTLSSim(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	fp := routeros.Fingerprint(cert.Leaf)
+
+	c, err := routeros.Connect(context.Background(), addr,
+		routeros.WithPinnedFingerprints(fp),
+		routeros.WithCredentials("admin", ""),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	other := routeros.Fingerprint(selfSigned(t).Leaf)
+	_, err = routeros.Connect(context.Background(), addr, routeros.WithPinnedFingerprints(other))
+	var mismatch *routeros.PinMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Presented != fp {
+		t.Fatalf("Connect()=%v; want mismatch presenting %s", err, fp)
+	}
+	if !strings.Contains(err.Error(), "presented "+fp) {
+		t.Fatalf("error %q does not show the presented fingerprint", err)
+	}
+
+	// the verifier works with a plain tls.Config as well
+	c, err = routeros.DialTLS(addr, "admin", "", &tls.Config{
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: routeros.VerifyPinned(fp),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+}
+
+func TestConnectPinnedFingerprintWithVerifier(t *testing.T) {
+	cert := selfSigned(t)
+	addr := startTLSSim(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	fp := routeros.Fingerprint(cert.Leaf)
+
+	calls := 0
+	rejected := errors.New("rejected by caller")
+	connect := func(pin string, verifyErr error) error {
+		c, err := routeros.Connect(context.Background(), addr,
+			routeros.WithTLS(&tls.Config{
+				VerifyPeerCertificate: func([][]byte, [][]*x509.Certificate) error {
+					calls++
+					return verifyErr
+				},
+			}),
+			routeros.WithPinnedFingerprints(pin),
+			routeros.WithCredentials("admin", ""),
+		)
+		if err == nil {
+			c.Close()
+		}
+		return err
+	}
+
+	err := connect(fp, nil)
+	if err != nil || calls != 1 {
+		t.Fatalf("Connect()=%v with %d verifier calls; want success with 1", err, calls)
+	}
+	err = connect(fp, rejected)
+	if !errors.Is(err, rejected) {
+		t.Fatalf("Connect()=%v; want the caller's verifier error", err)
+	}
+	err = connect(routeros.Fingerprint(selfSigned(t).Leaf), nil)
+	var mismatch *routeros.PinMismatchError
+	if !errors.As(err, &mismatch) || calls != 2 {
+		t.Fatalf("Connect()=%v with %d verifier calls; want mismatch without calling the verifier", err, calls)
+	}
+}
+
+func TestConnectPinStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	cert := selfSigned(t)
+	addr := startTLSSim(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	fp := routeros.Fingerprint(cert.Leaf)
+
+	store, err := routeros.OpenPinStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := routeros.Connect(context.Background(), addr, routeros.WithPinStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	b, _ := os.ReadFile(path)
+	if string(b) != addr+" "+fp+"\n" {
+		t.Fatalf("pin store=%q; want %q", b, addr+" "+fp+"\n")
+	}
+
+	// a device with a new key at the same address is rejected
+	os.WriteFile(path, []byte("# test\n\n"+addr+" SHA256:old\n"), 0o600)
+	store, err = routeros.OpenPinStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pins := store.Lookup(addr); len(pins) != 1 || pins[0] != "SHA256:old" {
+		t.Fatalf("Lookup=%v; want SHA256:old", pins)
+	}
+	_, err = routeros.Connect(context.Background(), addr, routeros.WithPinStore(store))
+	want := "RouterOS: certificate fingerprint mismatch for " + addr + ": presented " + fp + ", expected SHA256:old"
+	if err == nil || err.Error() != want {
+		t.Fatalf("Connect()=%v; want %s", err, want)
+	}
+
+	os.WriteFile(path, []byte("invalid\n"), 0o600)
+	_, err = routeros.OpenPinStore(path)
+	if err == nil {
+		t.Fatal("OpenPinStore accepted an invalid line")
+	}
+}
+
+func TestConnectClientCertificate(t *testing.T) {
+	server := selfSigned(t)
+	client := selfSigned(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(client.Leaf)
+	addr := startTLSSim(t, &tls.Config{
+		Certificates: []tls.Certificate{server},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	})
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	key, err := x509.MarshalECPrivateKey(client.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: client.Certificate[0]}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0o600)
+
+	c, err := routeros.Connect(context.Background(), addr,
+		routeros.WithPinnedFingerprints(routeros.Fingerprint(server.Leaf)),
+		routeros.WithClientCertificate(certFile, keyFile),
+		routeros.WithCredentials("admin", ""),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	_, err = routeros.Connect(context.Background(), addr,
+		routeros.WithClientCertificate(filepath.Join(dir, "missing.pem"), keyFile),
+	)
+	if err == nil || !strings.HasPrefix(err.Error(), "RouterOS: client certificate: ") {
+		t.Fatalf("Connect()=%v; want client certificate error", err)
+	}
+}
@@ -79,6 +79,11 @@ func (r *reader) ReadSentence(setDeadline bool) (*Sentence, error) {
 			sen.Map[p.Key] = p.Value
 			continue
 		}
+		// Query word of a command. Ex.: ?name=ether1, ?#|
+		if bytes.HasPrefix(b, []byte("?")) {
+			sen.Query = append(sen.Query, string(b[1:]))
+			continue
+		}
 		return nil, fmt.Errorf("invalid RouterOS sentence word: %#q", b)
 	}
 }
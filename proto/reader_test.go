@@ -2,6 +2,7 @@ package proto
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"testing"
 	"time"
@@ -42,3 +43,27 @@ func TestReadLength(t *testing.T) {
 		}
 	}
 }
+
+func TestReadQuery(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewWriter(newFakeWriterDeadline(buf), time.Second)
+	w.BeginSentence()
+	for _, word := range []string{"/interface/print", "?type=ether", "?disabled=false", "?#|", "=.proplist=name"} {
+		w.WriteWord(word)
+	}
+	if err := w.EndSentence(); err != nil {
+		t.Fatal(err)
+	}
+	sen, err := NewReader(newFakeReaderDeadline(buf), time.Second).ReadSentence(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := fmt.Sprintf("%#q", sen.Query)
+	want := "[`type=ether` `disabled=false` `#|`]"
+	if got != want {
+		t.Fatalf("Query=%s; want %s", got, want)
+	}
+	if sen.Map[".proplist"] != "name" {
+		t.Fatalf("Map=%v; want .proplist=name", sen.Map)
+	}
+}
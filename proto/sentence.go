@@ -9,6 +9,9 @@ type Sentence struct {
 	Tag  string
 	List []Pair
 	Map  map[string]string
+	// Query holds the query words of a command sentence without the
+	// leading '?'. Replies from a device never carry queries.
+	Query []string
 }
 
 type Pair struct {
@@ -1,6 +1,7 @@
 package routeros_test
 
 import (
+	"context"
 	"io"
 	"net"
 	"testing"
@@ -392,6 +393,194 @@ func TestListen(t *testing.T) {
 	}
 }
 
+func TestListenAll(t *testing.T) {
+	c, s := newPair(t)
+	defer c.Close()
+
+	go func() {
+		defer s.Close()
+		s.readSentence(t, "/ip/address/listen @l1 []")
+		s.writeSentence(t, "!re", ".tag=l1", "=address=1.2.3.4/32")
+		s.writeSentence(t, "!re", ".tag=l1", "=address=5.6.7.8/32")
+		s.readSentence(t, "/cancel @r2 [{`tag` `l1`}]")
+		s.writeSentence(t, "!trap", "=category=2", ".tag=l1")
+		s.writeSentence(t, "!done", ".tag=l1")
+		s.writeSentence(t, "!done", ".tag=r2")
+	}()
+
+	listen, err := c.Listen("/ip/address/listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for sen, err := range listen.All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, sen.Map["address"])
+		if len(got) == 2 {
+			break
+		}
+	}
+	if len(got) != 2 || got[1] != "5.6.7.8/32" {
+		t.Fatalf("All()=%v; want two addresses", got)
+	}
+	if _, open := <-listen.Chan(); open {
+		t.Fatal("channel not closed after break")
+	}
+}
+
+func TestListenAllError(t *testing.T) {
+	c, s := newPair(t)
+	defer c.Close()
+
+	go func() {
+		defer s.Close()
+		s.readSentence(t, "/ip/address/listen @l1 []")
+		s.writeSentence(t, "!re", ".tag=l1", "=address=1.2.3.4/32")
+		s.writeSentence(t, "!trap", ".tag=l1", "=message=no such command")
+	}()
+
+	listen, err := c.Listen("/ip/address/listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sentences, errs int
+	for sen, err := range listen.All() {
+		if err != nil {
+			errs++
+			if err.Error() != "from RouterOS device: no such command" {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if sen == nil {
+			t.Fatal("nil sentence without error")
+		}
+		sentences++
+	}
+	if sentences != 1 || errs != 1 {
+		t.Fatalf("got %d sentences and %d errors; want 1 and 1", sentences, errs)
+	}
+}
+
+func TestListenCancelWaits(t *testing.T) {
+	c, s := newPair(t)
+	defer c.Close()
+
+	release := make(chan struct{})
+	go func() {
+		defer s.Close()
+		s.readSentence(t, "/ip/address/listen @l1 []")
+		s.writeSentence(t, "!re", ".tag=l1", "=address=1.2.3.4/32")
+		s.readSentence(t, "/cancel @r2 [{`tag` `l1`}]")
+		// the cancel is confirmed before the listen ends
+		s.writeSentence(t, "!done", ".tag=r2")
+		<-release
+		s.writeSentence(t, "!re", ".tag=l1", "=address=5.6.7.8/32")
+		s.writeSentence(t, "!trap", "=category=2", ".tag=l1")
+		s.writeSentence(t, "!done", ".tag=l1")
+		s.readSentence(t, "/system/identity/print @r3 []")
+		s.writeSentence(t, "!done", ".tag=r3")
+	}()
+
+	listen, err := c.Listen("/ip/address/listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+	// nobody receives from the unbuffered channel
+	_, err = listen.Cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, open := <-listen.Chan(); open {
+		t.Fatal("channel not closed after Cancel")
+	}
+	if listen.Done == nil || listen.Done.Map["category"] != "2" {
+		t.Fatalf("Done=%v; want interrupted", listen.Done)
+	}
+	_, err = c.Run("/system/identity/print")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// cancelling an ended listen does nothing
+	r, err := listen.Cancel()
+	if r != nil || err != nil {
+		t.Fatalf("Cancel()=%v, %v; want nil, nil", r, err)
+	}
+}
+
+func TestListenCancelBrokenConnection(t *testing.T) {
+	c, s := newPair(t)
+	defer c.Close()
+
+	go func() {
+		s.readSentence(t, "/ip/address/listen @l1 []")
+		s.readSentence(t, "/cancel @r2 [{`tag` `l1`}]")
+		s.Close()
+	}()
+
+	listen, err := c.Listen("/ip/address/listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = listen.CancelContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, open := <-listen.Chan(); open {
+		t.Fatal("channel not closed after Cancel")
+	}
+	if listen.Err() == nil {
+		t.Fatal("Err()=nil; want the read error")
+	}
+}
+
+func TestAddSetRemove(t *testing.T) {
+	c, s := newPair(t)
+	defer c.Close()
+
+	go func() {
+		defer s.Close()
+		s.readSentence(t, "/ip/address/add @ [{`address` `1.2.3.4/32`} {`interface` `ether1`}]")
+		s.writeSentence(t, "!done", "=ret=*1")
+		s.readSentence(t, "/ip/address/set @ [{`.id` `*1`} {`comment` `x`}]")
+		s.writeSentence(t, "!done")
+		s.readSentence(t, "/ip/address/print @ [{`.proplist` `address`}]")
+		s.writeSentence(t, "!re", "=address=1.2.3.4/32")
+		s.writeSentence(t, "!done")
+		s.readSentence(t, "/ip/address/remove @ [{`.id` `*1,*2`}]")
+		s.writeSentence(t, "!done")
+	}()
+
+	id, err := c.Add("/ip/address", map[string]string{"interface": "ether1", "address": "1.2.3.4/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "*1" {
+		t.Fatalf("Add()=%s; want *1", id)
+	}
+	err = c.Set("/ip/address", id, map[string]string{"comment": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := c.Print("/ip/address", "=.proplist=address")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Re) != 1 {
+		t.Fatalf("Print()=%s; want one !re", r)
+	}
+	err = c.Remove("/ip/address", "*1", "*2")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func newPair(t *testing.T) (*routeros.Client, *fakeServer) {
 	server, client := net.Pipe()
 
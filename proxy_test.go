@@ -0,0 +1,256 @@
+package routeros_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/swoga/go-routeros"
+)
+
+// startProxy listens on a local port and runs handle for every connection.
+func startProxy(t *testing.T, handle func(conn net.Conn)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func relay(a, b net.Conn) {
+	go io.Copy(a, b)
+	io.Copy(b, a)
+}
+
+// socks5Proxy is a minimal SOCKS5 server. If user is not empty, it requires
+// username and password authentication.
+func socks5Proxy(t *testing.T, user, pass string) string {
+	return startProxy(t, func(conn net.Conn) {
+		b := make([]byte, 2)
+		io.ReadFull(conn, b)
+		methods := make([]byte, b[1])
+		io.ReadFull(conn, methods)
+		if user == "" {
+			conn.Write([]byte{5, 0})
+		} else if !bytes.Contains(methods, []byte{2}) {
+			conn.Write([]byte{5, 0xff})
+			return
+		} else {
+			conn.Write([]byte{5, 2})
+			io.ReadFull(conn, b)
+			u := make([]byte, b[1])
+			io.ReadFull(conn, u)
+			io.ReadFull(conn, b[:1])
+			p := make([]byte, b[0])
+			io.ReadFull(conn, p)
+			if string(u) != user || string(p) != pass {
+				conn.Write([]byte{1, 1})
+				return
+			}
+			conn.Write([]byte{1, 0})
+		}
+
+		head := make([]byte, 4)
+		io.ReadFull(conn, head)
+		var host string
+		switch head[3] {
+		case 1:
+			ip := make([]byte, 4)
+			io.ReadFull(conn, ip)
+			host = net.IP(ip).String()
+		case 3:
+			io.ReadFull(conn, b[:1])
+			name := make([]byte, b[0])
+			io.ReadFull(conn, name)
+			host = string(name)
+		}
+		io.ReadFull(conn, b)
+		port := binary.BigEndian.Uint16(b)
+		target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+		if err != nil {
+			conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		defer target.Close()
+		conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+		relay(conn, target)
+	})
+}
+
+// httpProxy is a minimal HTTP CONNECT proxy requiring basic authentication.
+func httpProxy(t *testing.T, user, pass string) string {
+	return startProxy(t, func(conn net.Conn) {
+		br := bufio.NewReader(conn)
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		u, p, _ := proxyAuth(req)
+		if req.Method != http.MethodConnect || u != user || p != pass {
+			io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			return
+		}
+		target, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+			return
+		}
+		defer target.Close()
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		relay(conn, target)
+	})
+}
+
+func proxyAuth(req *http.Request) (string, string, bool) {
+	auth := req.Header.Get("Proxy-Authorization")
+	if auth == "" {
+		return "", "", false
+	}
+	r := &http.Request{Header: http.Header{"Authorization": {auth}}}
+	return r.BasicAuth()
+}
+
+func TestConnectProxy(t *testing.T) {
+	_, addr := startSim(t)
+	socks := socks5Proxy(t, "", "")
+	socksAuth := socks5Proxy(t, "u", "p")
+	connect := httpProxy(t, "u", "p")
+
+	tests := []struct {
+		name  string
+		proxy string
+		err   string
+	}{
+		{"socks5", "socks5://" + socks, ""},
+		{"socks5 auth", "socks5h://u:p@" + socksAuth, ""},
+		{"socks5 wrong password", "socks5://u:x@" + socksAuth, "RouterOS: proxy socks5://u:xxxxx@" + socksAuth + ": authentication failed"},
+		{"socks5 missing auth", "socks5://" + socksAuth, "RouterOS: proxy socks5://" + socksAuth + ": no acceptable authentication method"},
+		{"http", "http://u:p@" + connect, ""},
+		{"http wrong password", "http://u:x@" + connect, "RouterOS: proxy http://u:xxxxx@" + connect + ": connect refused by proxy: 407 Proxy Authentication Required"},
+		{"unsupported", "ftp://" + connect, `RouterOS: unsupported proxy scheme "ftp"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := routeros.Connect(context.Background(), addr,
+				routeros.WithProxy(tt.proxy),
+				routeros.WithCredentials("admin", ""),
+			)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("Connect()=%v; want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			r, err := c.Run("/system/identity/print")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Re[0].Map["name"] != "MikroTik" {
+				t.Fatalf("identity=%s; want MikroTik", r)
+			}
+		})
+	}
+}
+
+func TestConnectProxyRefused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	socks := socks5Proxy(t, "", "")
+	_, err = routeros.Connect(context.Background(), addr, routeros.WithProxy("socks5://"+socks))
+	want := "RouterOS: proxy socks5://" + socks + ": connect refused by proxy: connection refused"
+	if err == nil || err.Error() != want {
+		t.Fatalf("Connect()=%v; want %s", err, want)
+	}
+}
+
+func TestDialProxyFromEnvironment(t *testing.T) {
+	_, addr := startSim(t)
+	var used atomic.Bool
+	socks := startProxy(t, func(conn net.Conn) {
+		used.Store(true)
+	})
+
+	t.Setenv("ALL_PROXY", "socks5://"+socks)
+	t.Setenv("NO_PROXY", "")
+	_, err := routeros.Dial(addr, "admin", "")
+	if err == nil || !strings.HasPrefix(err.Error(), "RouterOS: proxy socks5://"+socks) {
+		t.Fatalf("Dial()=%v; want proxy error", err)
+	}
+	if !used.Load() {
+		t.Fatal("proxy from ALL_PROXY not used")
+	}
+
+	t.Setenv("NO_PROXY", "example.com,127.0.0.0/8")
+	c, err := routeros.Dial(addr, "admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	t.Setenv("NO_PROXY", "")
+	c, err = routeros.Connect(context.Background(), addr, routeros.WithProxy(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+}
+
+func TestConnectProxyResolve(t *testing.T) {
+	requested := make(chan byte, 1)
+	socks := startProxy(t, func(conn net.Conn) {
+		b := make([]byte, 3)
+		io.ReadFull(conn, b)
+		conn.Write([]byte{5, 0})
+		head := make([]byte, 4)
+		io.ReadFull(conn, head)
+		requested <- head[3]
+		conn.Write([]byte{5, 4, 0, 1, 0, 0, 0, 0, 0, 0})
+	})
+
+	for _, tc := range []struct {
+		scheme string
+		atyp   byte
+	}{
+		{"socks5", 1},
+		{"socks5h", 3},
+	} {
+		_, err := routeros.Connect(context.Background(), "localhost:8728", routeros.WithProxy(tc.scheme+"://"+socks))
+		if err == nil {
+			t.Fatalf("%s: Connect() succeeded", tc.scheme)
+		}
+		// localhost may resolve to ::1 first
+		if atyp := <-requested; atyp != tc.atyp && !(tc.atyp == 1 && atyp == 4) {
+			t.Errorf("%s: proxy got address type %d; want %d", tc.scheme, atyp, tc.atyp)
+		}
+	}
+}
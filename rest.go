@@ -0,0 +1,361 @@
+package routeros
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/swoga/go-routeros/proto"
+)
+
+// RESTClient is a client for the REST API of RouterOS 7. It translates API
+// sentences to REST requests and the JSON responses back to sentences, so it
+// can be used wherever a Runner is expected.
+type RESTClient struct {
+	// PollInterval is the interval at which Listen polls the menu. Zero or
+	// negative means five seconds.
+	PollInterval time.Duration
+	// Queue is the queue size of the channel returned by Listen.
+	Queue int
+
+	baseURL  string
+	username string
+	password string
+	http     *http.Client
+}
+
+const defaultPollInterval = 5 * time.Second
+
+// NewRESTClient returns a RESTClient for the REST API at baseURL, e.g.
+// https://192.168.88.1/rest. If httpClient is nil, http.DefaultClient is used.
+func NewRESTClient(baseURL, username, password string, httpClient *http.Client) *RESTClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &RESTClient{
+		PollInterval: defaultPollInterval,
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		username:     username,
+		password:     password,
+		http:         httpClient,
+	}
+}
+
+// Run simply calls RunArgs().
+func (c *RESTClient) Run(sentence ...string) (*Reply, error) {
+	return c.RunArgs(sentence)
+}
+
+// RunArgs sends the command as POST request to the REST API and converts the
+// response into a Reply. Attribute words become members of the JSON body,
+// query words are collected in .query and =.proplist= in .proplist.
+func (c *RESTClient) RunArgs(sentence []string) (*Reply, error) {
+	return c.runContext(context.Background(), sentence)
+}
+
+func (c *RESTClient) runContext(ctx context.Context, sentence []string) (*Reply, error) {
+	if len(sentence) == 0 {
+		return nil, errEmptyWord
+	}
+	for _, word := range sentence {
+		if len(strings.Trim(word, " ")) == 0 {
+			return nil, errEmptyWord
+		}
+	}
+	body, err := restBody(sentence[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+sentence[0], bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode/100 != 2 {
+		return nil, restError(res, b)
+	}
+	return restReply(b)
+}
+
+func restBody(words []string) ([]byte, error) {
+	body := make(map[string]any)
+	var query []string
+	for _, word := range words {
+		switch {
+		case strings.HasPrefix(word, "=.proplist="):
+			body[".proplist"] = strings.Split(strings.TrimPrefix(word, "=.proplist="), ",")
+		case strings.HasPrefix(word, "="):
+			k, v, _ := strings.Cut(word[1:], "=")
+			body[k] = v
+		case strings.HasPrefix(word, "?"):
+			query = append(query, word[1:])
+		case strings.HasPrefix(word, ".tag="):
+			// tags have no meaning over HTTP
+		default:
+			return nil, fmt.Errorf("RouterOS REST: unsupported word %#q", word)
+		}
+	}
+	if query != nil {
+		body[".query"] = query
+	}
+	return json.Marshal(body)
+}
+
+// restError converts an error response like
+// {"error":400,"message":"Bad Request","detail":"no such item"} into a
+// DeviceError.
+func restError(res *http.Response, b []byte) error {
+	var e struct {
+		Message string `json:"message"`
+		Detail  string `json:"detail"`
+	}
+	json.Unmarshal(b, &e)
+	msg := e.Detail
+	if msg == "" {
+		msg = e.Message
+	}
+	if msg == "" {
+		msg = res.Status
+	}
+	sen := proto.NewSentence()
+	sen.Word = "!trap"
+	addPair(sen, "message", msg)
+	return &DeviceError{sen}
+}
+
+// restReply converts a JSON array of objects into !re sentences and a JSON
+// object into the attributes of !done.
+func restReply(b []byte) (*Reply, error) {
+	r := &Reply{Done: proto.NewSentence()}
+	r.Done.Word = "!done"
+	if len(bytes.TrimSpace(b)) == 0 {
+		return r, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case json.Delim('['):
+		for dec.More() {
+			sen := proto.NewSentence()
+			sen.Word = "!re"
+			err = decodeObject(dec, sen)
+			if err != nil {
+				return nil, err
+			}
+			r.Re = append(r.Re, sen)
+		}
+	case json.Delim('{'):
+		err = decodeMembers(dec, r.Done)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("RouterOS REST: unexpected response %s", b)
+	}
+	return r, nil
+}
+
+func decodeObject(dec *json.Decoder, sen *proto.Sentence) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != json.Delim('{') {
+		return fmt.Errorf("RouterOS REST: expected object, got %v", t)
+	}
+	return decodeMembers(dec, sen)
+}
+
+// decodeMembers reads the members of an object up to the closing brace,
+// keeping their order.
+func decodeMembers(dec *json.Decoder, sen *proto.Sentence) error {
+	for dec.More() {
+		k, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var v json.RawMessage
+		err = dec.Decode(&v)
+		if err != nil {
+			return err
+		}
+		var s string
+		if json.Unmarshal(v, &s) != nil {
+			s = string(v)
+		}
+		addPair(sen, k.(string), s)
+	}
+	_, err := dec.Token()
+	return err
+}
+
+func addPair(sen *proto.Sentence, key, value string) {
+	sen.List = append(sen.List, proto.Pair{Key: key, Value: value})
+	sen.Map[key] = value
+}
+
+// Print runs the print command of the menu at path.
+func (c *RESTClient) Print(path string, args ...string) (*Reply, error) {
+	return c.RunArgs(printSentence(path, args))
+}
+
+// Add runs the add command of the menu at path and returns the .id of the
+// new item.
+func (c *RESTClient) Add(path string, attrs map[string]string) (string, error) {
+	r, err := c.RunArgs(addSentence(path, attrs))
+	if err != nil {
+		return "", err
+	}
+	return r.Done.Map["ret"], nil
+}
+
+// Set runs the set command of the menu at path for the item with id.
+func (c *RESTClient) Set(path, id string, attrs map[string]string) error {
+	_, err := c.RunArgs(setSentence(path, id, attrs))
+	return err
+}
+
+// Remove runs the remove command of the menu at path for the items with ids.
+func (c *RESTClient) Remove(path string, ids ...string) error {
+	_, err := c.RunArgs(removeSentence(path, ids))
+	return err
+}
+
+// Listen emulates a listen command, e.g. /ip/address/listen, by polling the
+// print command of the menu every PollInterval. Added and changed items are
+// sent as !re with all their properties and removed items as !re with .id
+// and .dead=yes, like the binary API does.
+func (c *RESTClient) Listen(sentence ...string) (*ListenReply, error) {
+	if len(sentence) == 0 || !strings.HasSuffix(sentence[0], "/listen") {
+		return nil, fmt.Errorf("RouterOS REST: only listen commands can be emulated, got %#q", sentence)
+	}
+	printCmd := printSentence(strings.TrimSuffix(sentence[0], "/listen"), sentence[1:])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l := newListenReply(c.Queue)
+
+	prev, err := c.poll(ctx, printCmd)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	var once sync.Once
+	stopped := make(chan struct{})
+	l.cancel = func() (*Reply, error) {
+		once.Do(cancel)
+		<-stopped
+		return &Reply{Done: &proto.Sentence{Word: "!done", Map: map[string]string{}}}, nil
+	}
+
+	go func() {
+		defer close(stopped)
+		interval := c.PollInterval
+		if interval <= 0 {
+			interval = defaultPollInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				l.Done = proto.NewSentence()
+				l.Done.Word = "!trap"
+				addPair(l.Done, "category", "2")
+				addPair(l.Done, "message", "interrupted")
+				l.close(nil)
+				return
+			case <-ticker.C:
+			}
+			cur, err := c.poll(ctx, printCmd)
+			if err != nil {
+				if ctx.Err() != nil {
+					continue
+				}
+				l.close(err)
+				return
+			}
+			for _, sen := range diffItems(prev, cur) {
+				select {
+				case l.reC <- sen:
+				case <-ctx.Done():
+				}
+			}
+			prev = cur
+		}
+	}()
+	return l, nil
+}
+
+// poll returns the items of a print command by .id, in order.
+func (c *RESTClient) poll(ctx context.Context, printCmd []string) ([]*proto.Sentence, error) {
+	r, err := c.runContext(ctx, printCmd)
+	if err != nil {
+		return nil, err
+	}
+	return r.Re, nil
+}
+
+func diffItems(prev, cur []*proto.Sentence) []*proto.Sentence {
+	old := make(map[string]*proto.Sentence, len(prev))
+	for _, sen := range prev {
+		old[sen.Map[".id"]] = sen
+	}
+	var res []*proto.Sentence
+	for _, sen := range cur {
+		id := sen.Map[".id"]
+		o, ok := old[id]
+		delete(old, id)
+		if ok && sameSentence(o, sen) {
+			continue
+		}
+		res = append(res, sen)
+	}
+	for _, sen := range prev {
+		id := sen.Map[".id"]
+		if _, ok := old[id]; !ok {
+			continue
+		}
+		dead := proto.NewSentence()
+		dead.Word = "!re"
+		addPair(dead, ".id", id)
+		addPair(dead, ".dead", "yes")
+		res = append(res, dead)
+	}
+	return res
+}
+
+func sameSentence(a, b *proto.Sentence) bool {
+	if len(a.List) != len(b.List) {
+		return false
+	}
+	for i := range a.List {
+		if a.List[i] != b.List[i] {
+			return false
+		}
+	}
+	return true
+}
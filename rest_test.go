@@ -0,0 +1,177 @@
+package routeros_test
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/sim"
+)
+
+// newRESTServer returns a stand-in for the RouterOS 7 REST API that runs the
+// requests on a simulated device.
+func newRESTServer(t *testing.T) (*sim.Device, *httptest.Server) {
+	d := sim.New()
+	s, conn := net.Pipe()
+	go d.ServeConn(s)
+	c, err := routeros.NewClient(conn, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Login("admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Async()
+	t.Cleanup(c.Close)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if r.Method != http.MethodPost || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		sentence := []string{strings.TrimPrefix(r.URL.Path, "/rest")}
+		for k, v := range body {
+			switch k {
+			case ".query":
+				for _, q := range v.([]any) {
+					sentence = append(sentence, "?"+q.(string))
+				}
+			case ".proplist":
+				var names []string
+				for _, n := range v.([]any) {
+					names = append(names, n.(string))
+				}
+				sentence = append(sentence, "=.proplist="+strings.Join(names, ","))
+			default:
+				sentence = append(sentence, "="+k+"="+v.(string))
+			}
+		}
+		reply, err := c.RunArgs(sentence)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{
+				"error":   400,
+				"message": "Bad Request",
+				"detail":  err.(*routeros.DeviceError).Sentence.Map["message"],
+			})
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/print") {
+			items := []map[string]string{}
+			for _, re := range reply.Re {
+				items = append(items, re.Map)
+			}
+			json.NewEncoder(w).Encode(items)
+			return
+		}
+		json.NewEncoder(w).Encode(reply.Done.Map)
+	}))
+	t.Cleanup(ts.Close)
+	return d, ts
+}
+
+func TestRESTClient(t *testing.T) {
+	d, ts := newRESTServer(t)
+	var c routeros.Runner = routeros.NewRESTClient(ts.URL+"/rest", "admin", "secret", nil)
+
+	id, err := c.Add("/ip/address", map[string]string{"address": "192.0.2.1/24", "interface": "ether1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "*1" {
+		t.Fatalf("Add=%s; want *1", id)
+	}
+
+	_, err = c.Add("/ip/address", map[string]string{"address": "192.0.2.1/24", "interface": "ether1"})
+	if err == nil || err.Error() != "from RouterOS device: failure: already have such address" {
+		t.Fatalf("Add duplicate=%v; want device error", err)
+	}
+
+	err = c.Set("/ip/address", id, map[string]string{"comment": "uplink"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := c.Print("/ip/address", "?comment=uplink", "?disabled=false", "=.proplist=.id,address,comment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "!re @ [{`.id` `*1`} {`address` `192.0.2.1/24`} {`comment` `uplink`}]"
+	if len(r.Re) != 1 || r.Re[0].String() != want {
+		t.Fatalf("Print=%s; want %s", r, want)
+	}
+
+	err = c.Remove("/ip/address", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Items("/ip/address")) != 0 {
+		t.Fatal("Remove did not remove the item")
+	}
+
+	_, err = routeros.NewRESTClient(ts.URL+"/rest", "admin", "wrong", nil).Run("/system/identity/print")
+	if err == nil || err.Error() != "from RouterOS device: 401 Unauthorized" {
+		t.Fatalf("Run with wrong password=%v; want 401", err)
+	}
+}
+
+func TestRESTClientListen(t *testing.T) {
+	d, ts := newRESTServer(t)
+	c := routeros.NewRESTClient(ts.URL+"/rest", "admin", "secret", nil)
+	c.PollInterval = 10 * time.Millisecond
+
+	id, _ := d.Add("/ip/firewall/address-list", map[string]string{"list": "a", "address": "192.0.2.1"})
+	l, err := c.Listen("/ip/firewall/address-list/listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.Set("/ip/firewall/address-list", id, map[string]string{"comment": "changed"})
+	sen := <-l.Chan()
+	if sen.Map[".id"] != id || sen.Map["comment"] != "changed" {
+		t.Fatalf("listen=%s; want changed item", sen)
+	}
+	d.Remove("/ip/firewall/address-list", id)
+	sen = <-l.Chan()
+	if sen.Map[".id"] != id || sen.Map[".dead"] != "yes" {
+		t.Fatalf("listen=%s; want .dead", sen)
+	}
+
+	_, err = l.Cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range l.Chan() {
+	}
+	if l.Err() != nil || l.Done.Map["category"] != "2" {
+		t.Fatalf("Err=%v Done=%s; want interrupted", l.Err(), l.Done)
+	}
+}
+
+func TestRESTClientListenDefaultInterval(t *testing.T) {
+	_, ts := newRESTServer(t)
+	c := routeros.NewRESTClient(ts.URL+"/rest", "admin", "secret", nil)
+	c.PollInterval = 0
+
+	l, err := c.Listen("/ip/firewall/address-list/listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = l.Cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range l.Chan() {
+	}
+	if l.Err() != nil || l.Done.Map["category"] != "2" {
+		t.Fatalf("Err=%v Done=%s; want interrupted", l.Err(), l.Done)
+	}
+}
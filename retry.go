@@ -0,0 +1,248 @@
+package routeros
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy configures how a Retrier retries failed commands.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one. Values
+	// below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. It is multiplied
+	// by Multiplier for every further retry, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter is the fraction, between 0 and 1, by which a delay is randomly
+	// shortened so that clients do not retry in lockstep.
+	Jitter float64
+	// Retryable reports whether err is worth retrying. If nil, IsTransient
+	// is used.
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy makes up to three attempts with a backoff starting at
+// 100ms.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// backoff returns the delay before retry number n, starting at 1.
+func (p *RetryPolicy) backoff(n int) time.Duration {
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = 1
+	}
+	d := float64(p.InitialBackoff) * math.Pow(mult, float64(n-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d -= d * min(p.Jitter, 1) * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsTransient(err)
+}
+
+// IsTransient reports whether err is a timeout or a broken connection, as
+// opposed to an error returned by the device.
+func IsTransient(err error) bool {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, errAsyncTimeout), errors.Is(err, errAsyncLoopEnded):
+		return true
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNABORTED),
+		errors.Is(err, syscall.EPIPE), errors.Is(err, syscall.ECONNREFUSED):
+		return true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	}
+	return false
+}
+
+// IsIdempotent reports whether the command in sentence only reads data and
+// completes, so running it again after a failure has no side effects. These
+// are print without =follow=, =follow-only= or =interval=, get, getall and
+// export, and monitor with =once=.
+func IsIdempotent(sentence []string) bool {
+	if len(sentence) == 0 {
+		return false
+	}
+	cmd := sentence[0][strings.LastIndex(sentence[0], "/")+1:]
+	switch cmd {
+	case "print":
+		for _, word := range sentence[1:] {
+			if strings.HasPrefix(word, "=interval=") {
+				return false
+			}
+		}
+		return !hasFlag(sentence, "follow") && !hasFlag(sentence, "follow-only")
+	case "get", "getall", "export":
+		return true
+	case "monitor":
+		return hasFlag(sentence, "once")
+	}
+	return false
+}
+
+// hasFlag reports whether the arguments of sentence set the flag name.
+func hasFlag(sentence []string, name string) bool {
+	for _, word := range sentence[1:] {
+		switch word {
+		case "=" + name + "=", "=" + name + "=yes", "=" + name + "=true":
+			return true
+		}
+	}
+	return false
+}
+
+// Retrier runs commands on a Client and retries them according to a
+// RetryPolicy. Only idempotent commands (see IsIdempotent) are retried
+// automatically, others only when run with RunIdempotent. If the connection
+// broke, the Client is replaced using Client.Redial before retrying, which
+// requires a Client created by Connect. With other clients, or if the redial
+// fails, the error of the command is returned, wrapping the redial error.
+type Retrier struct {
+	Policy RetryPolicy
+
+	mu sync.Mutex
+	c  *Client
+}
+
+var _ Runner = (*Retrier)(nil)
+
+// NewRetrier returns a Retrier for c using policy.
+func NewRetrier(c *Client, policy RetryPolicy) *Retrier {
+	return &Retrier{Policy: policy, c: c}
+}
+
+// Client returns the current client.
+func (r *Retrier) Client() *Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.c
+}
+
+// Close closes the current client.
+func (r *Retrier) Close() {
+	r.Client().Close()
+}
+
+// Run simply calls RunArgs().
+func (r *Retrier) Run(sentence ...string) (*Reply, error) {
+	return r.RunArgs(sentence)
+}
+
+// RunArgs calls RunArgsContext() with the background context.
+func (r *Retrier) RunArgs(sentence []string) (*Reply, error) {
+	return r.RunArgsContext(context.Background(), sentence)
+}
+
+// RunArgsContext runs sentence and retries it if it is idempotent. ctx
+// aborts waiting between attempts.
+func (r *Retrier) RunArgsContext(ctx context.Context, sentence []string) (*Reply, error) {
+	return r.run(ctx, sentence, IsIdempotent(sentence))
+}
+
+// RunIdempotent runs sentence and retries it even if it changes data, e.g.
+// a set of fixed values or a script known to be safe to repeat.
+func (r *Retrier) RunIdempotent(ctx context.Context, sentence ...string) (*Reply, error) {
+	return r.run(ctx, sentence, true)
+}
+
+func (r *Retrier) run(ctx context.Context, sentence []string, idempotent bool) (*Reply, error) {
+	c := r.Client()
+	for attempt := 1; ; attempt++ {
+		reply, err := c.RunArgs(sentence)
+		if err == nil || !idempotent || attempt >= r.Policy.MaxAttempts || !r.Policy.retryable(err) {
+			return reply, err
+		}
+
+		t := time.NewTimer(r.Policy.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, err
+		case <-t.C:
+		}
+
+		if !errors.Is(err, errAsyncTimeout) {
+			// the connection is broken
+			next, redialErr := r.redial(ctx, c)
+			if redialErr != nil {
+				return nil, fmt.Errorf("%w (redial failed: %w)", err, redialErr)
+			}
+			c = next
+		}
+	}
+}
+
+// redial replaces broken with a new connection, unless another command has
+// already done so.
+func (r *Retrier) redial(ctx context.Context, broken *Client) (*Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.c != broken {
+		return r.c, nil
+	}
+	c, err := broken.Redial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	broken.Close()
+	r.c = c
+	return c, nil
+}
+
+// Print runs the print command of the menu at path.
+func (r *Retrier) Print(path string, args ...string) (*Reply, error) {
+	return r.RunArgs(printSentence(path, args))
+}
+
+// Add runs the add command of the menu at path and returns the .id of the
+// new item. It is not retried.
+func (r *Retrier) Add(path string, attrs map[string]string) (string, error) {
+	return r.Client().Add(path, attrs)
+}
+
+// Set runs the set command of the menu at path for the item with id. It is
+// not retried.
+func (r *Retrier) Set(path, id string, attrs map[string]string) error {
+	return r.Client().Set(path, id, attrs)
+}
+
+// Remove runs the remove command of the menu at path for the items with ids.
+// It is not retried.
+func (r *Retrier) Remove(path string, ids ...string) error {
+	return r.Client().Remove(path, ids...)
+}
+
+// Listen runs a listen command on the current client. It is not retried.
+func (r *Retrier) Listen(sentence ...string) (*ListenReply, error) {
+	return r.Client().Listen(sentence...)
+}
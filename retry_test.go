@@ -0,0 +1,198 @@
+package routeros_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/sim"
+)
+
+// dropListener records the accepted connections so a test can break them.
+type dropListener struct {
+	net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func (l *dropListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.mu.Lock()
+		l.conns = append(l.conns, conn)
+		l.mu.Unlock()
+	}
+	return conn, err
+}
+
+func (l *dropListener) drop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, conn := range l.conns {
+		conn.Close()
+	}
+	l.conns = nil
+}
+
+func (l *dropListener) accepted() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.conns)
+}
+
+func startDropSim(t *testing.T) (*sim.Device, *dropListener) {
+	d := sim.New()
+	nl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &dropListener{Listener: nl}
+	t.Cleanup(func() { l.Close() })
+	go d.Serve(l)
+	return d, l
+}
+
+func TestIsIdempotent(t *testing.T) {
+	tests := []struct {
+		sentence []string
+		want     bool
+	}{
+		{[]string{"/system/resource/print"}, true},
+		{[]string{"/ip/address/print", "?disabled=false"}, true},
+		{[]string{"/ip/address/print", "=follow="}, false},
+		{[]string{"/ip/address/print", "=follow-only=yes"}, false},
+		{[]string{"/interface/print", "=stats=", "=interval=1s"}, false},
+		{[]string{"/system/identity/get", "=value-name=name"}, true},
+		{[]string{"/interface/monitor-traffic", "=interface=ether1", "=once="}, false},
+		{[]string{"/interface/ethernet/monitor", "=numbers=ether1", "=once="}, true},
+		{[]string{"/interface/ethernet/monitor", "=numbers=ether1"}, false},
+		{[]string{"/ip/address/add", "=address=192.0.2.1/24"}, false},
+		{[]string{"/ip/address/remove", "=.id=*1"}, false},
+		{[]string{"/system/script/run", "=number=backup"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := routeros.IsIdempotent(tt.sentence); got != tt.want {
+			t.Errorf("IsIdempotent(%q)=%v; want %v", tt.sentence, got, tt.want)
+		}
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	if !routeros.IsTransient(io.EOF) || !routeros.IsTransient(&net.OpError{Err: errTimeout{}}) {
+		t.Fatal("EOF and timeouts must be transient")
+	}
+	if routeros.IsTransient(&routeros.DeviceError{}) || routeros.IsTransient(errors.New("x")) || routeros.IsTransient(nil) {
+		t.Fatal("device errors must not be transient")
+	}
+}
+
+type errTimeout struct{}
+
+func (errTimeout) Error() string   { return "i/o timeout" }
+func (errTimeout) Timeout() bool   { return true }
+func (errTimeout) Temporary() bool { return true }
+
+func TestRetrier(t *testing.T) {
+	_, l := startDropSim(t)
+	c, err := routeros.Connect(context.Background(), l.Addr().String(), routeros.WithCredentials("admin", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := routeros.DefaultRetryPolicy
+	policy.InitialBackoff = time.Millisecond
+	r := routeros.NewRetrier(c, policy)
+	defer r.Close()
+
+	// idempotent commands are retried on a new connection
+	l.drop()
+	reply, err := r.Print("/system/identity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Re[0].Map["name"] != "MikroTik" {
+		t.Fatalf("Print=%s", reply)
+	}
+	if r.Client() == c {
+		t.Fatal("client was not redialed")
+	}
+
+	// others are not
+	l.drop()
+	_, err = r.Add("/ip/firewall/address-list", map[string]string{"list": "a", "address": "192.0.2.1"})
+	if !routeros.IsTransient(err) {
+		t.Fatalf("Add()=%v; want transient error", err)
+	}
+
+	// unless marked idempotent
+	before := l.accepted()
+	_, err = r.RunIdempotent(context.Background(), "/ip/firewall/address-list/add", "=list=a", "=address=192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.accepted() != before+1 {
+		t.Fatalf("accepted %d connections; want %d", l.accepted(), before+1)
+	}
+
+	// device errors are not retried
+	_, err = r.Print("/nonexistent")
+	var devErr *routeros.DeviceError
+	if !errors.As(err, &devErr) || l.accepted() != before+1 {
+		t.Fatalf("Print()=%v; want device error without redial", err)
+	}
+}
+
+func TestRetrierAttempts(t *testing.T) {
+	_, l := startDropSim(t)
+	c, err := routeros.Connect(context.Background(), l.Addr().String(), routeros.WithCredentials("admin", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var attempts int
+	r := routeros.NewRetrier(c, routeros.RetryPolicy{
+		MaxAttempts: 3,
+		Retryable: func(err error) bool {
+			attempts++
+			return true
+		},
+	})
+	defer r.Close()
+
+	_, err = r.Print("/nonexistent")
+	if err == nil || attempts != 2 {
+		t.Fatalf("Print()=%v after %d retries; want error after 2", err, attempts)
+	}
+}
+
+func TestRetrierRedialFails(t *testing.T) {
+	_, l := startDropSim(t)
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a Client not created by Connect cannot be redialed
+	c, err := routeros.NewClient(conn, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Login("admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := routeros.DefaultRetryPolicy
+	policy.InitialBackoff = time.Millisecond
+	r := routeros.NewRetrier(c, policy)
+	defer r.Close()
+
+	l.drop()
+	_, err = r.Print("/system/identity")
+	if !routeros.IsTransient(err) || !strings.Contains(err.Error(), "Redial requires a Client created by Connect") {
+		t.Fatalf("Print()=%v; want the command error with the redial error", err)
+	}
+}
@@ -1,7 +1,6 @@
 package routeros
 
 import (
-	"strconv"
 	"strings"
 	"time"
 
@@ -19,24 +18,54 @@ func (c *Client) Run(sentence ...string) (*Reply, error) {
 }
 
 // RunArgs sends a sentence to the RouterOS device and waits for the reply.
-func (c *Client) RunArgs(sentence []string) (*Reply, error) {
+// In asynchronous mode a .tag word in sentence sets the tag of the command,
+// which must not be in use, instead of a generated one.
+func (c *Client) RunArgs(sentence []string) (reply *Reply, err error) {
 	for _, word := range sentence {
 		// check if word is empty or only contains spaces
 		if len(strings.Trim(word, " ")) == 0 {
 			return nil, errEmptyWord
 		}
 	}
-	c.w.BeginSentence()
-	for _, word := range sentence {
-		c.w.WriteWord(word)
+	err = c.begin()
+	if err != nil {
+		return nil, err
 	}
+	defer c.finish()
+	return c.run(sentence)
+}
+
+// run sends sentence and waits for the reply. The caller accounts for the
+// command with begin and finish.
+func (c *Client) run(sentence []string) (reply *Reply, err error) {
 	if !c.async {
+		start := time.Now()
+		c.commandStarted(command(sentence), false)
+		defer func() { c.commandDone(command(sentence), "", false, start, err) }()
+		c.w.BeginSentence()
+		for _, word := range sentence {
+			c.w.WriteWord(word)
+		}
 		return c.endCommandSync()
 	}
-	a, err := c.endCommandAsync()
+	sentence, tag := splitTag(sentence)
+	a := newAsyncReply(command(sentence))
+	err = c.register(a, "r", tag)
 	if err != nil {
 		return nil, err
 	}
+	c.commandStarted(a.command, false)
+	defer func() { c.commandDone(a.command, a.tag, false, a.started, err) }()
+	c.w.BeginSentence()
+	for _, word := range sentence {
+		c.w.WriteWord(word)
+	}
+	c.w.WriteWord(".tag=" + a.tag)
+	err = c.w.EndSentence()
+	if err != nil {
+		c.unregister(a.tag)
+		return nil, err
+	}
 
 readAllSentences:
 	for {
@@ -64,23 +93,31 @@ func (c *Client) endCommandSync() (*Reply, error) {
 	return c.readReply()
 }
 
-func (c *Client) endCommandAsync() (*asyncReply, error) {
+func newAsyncReply(command string) *asyncReply {
 	a := &asyncReply{}
 	a.reC = make(chan *proto.Sentence)
-	a.tag = "r" + strconv.FormatUint(c.nextTag(), 10)
-	c.w.WriteWord(".tag=" + a.tag)
-
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	a.command = command
+	return a
+}
 
-	err := c.w.EndSentence()
+// sendAsync registers a reply with a generated tag and sends sentence with
+// that tag.
+func (c *Client) sendAsync(sentence []string) (*asyncReply, error) {
+	a := newAsyncReply(command(sentence))
+	err := c.register(a, "r", "")
 	if err != nil {
 		return nil, err
 	}
-	if c.tags == nil {
-		return nil, errAsyncLoopEnded
+	c.w.BeginSentence()
+	for _, word := range sentence {
+		c.w.WriteWord(word)
+	}
+	c.w.WriteWord(".tag=" + a.tag)
+	err = c.w.EndSentence()
+	if err != nil {
+		c.unregister(a.tag)
+		return nil, err
 	}
-	c.tags[a.tag] = a
 	return a, nil
 }
 
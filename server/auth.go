@@ -0,0 +1,56 @@
+package server
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"fmt"
+	"io"
+)
+
+// Authenticator checks the credentials sent with /login.
+type Authenticator interface {
+	// Authenticate reports whether password is valid for user.
+	Authenticate(user, password string) bool
+}
+
+// ChallengeAuthenticator is an Authenticator that knows the plaintext
+// passwords of its users and thereby supports the challenge-response login
+// of RouterOS before 6.43.
+type ChallengeAuthenticator interface {
+	Authenticator
+	// Password returns the password of user.
+	Password(user string) (string, bool)
+}
+
+// AuthenticatorFunc adapts an ordinary function to the Authenticator
+// interface.
+type AuthenticatorFunc func(user, password string) bool
+
+// Authenticate calls f(user, password).
+func (f AuthenticatorFunc) Authenticate(user, password string) bool {
+	return f(user, password)
+}
+
+// Users is a ChallengeAuthenticator mapping user names to passwords.
+type Users map[string]string
+
+// Authenticate reports whether password is the password of user.
+func (u Users) Authenticate(user, password string) bool {
+	want, ok := u[user]
+	return ok && subtle.ConstantTimeCompare([]byte(want), []byte(password)) == 1
+}
+
+// Password returns the password of user.
+func (u Users) Password(user string) (string, bool) {
+	p, ok := u[user]
+	return p, ok
+}
+
+// challengeResponse computes the response a client sends for challenge.
+func challengeResponse(challenge []byte, password string) string {
+	h := md5.New()
+	h.Write([]byte{0})
+	io.WriteString(h, password)
+	h.Write(challenge)
+	return fmt.Sprintf("00%x", h.Sum(nil))
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"sync"
+
+	"github.com/swoga/go-routeros/proto"
+)
+
+type conn struct {
+	srv *Server
+	nc  net.Conn
+	r   proto.Reader
+	w   proto.Writer
+
+	loggedIn  bool
+	user      string
+	challenge []byte
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	active map[string]*response
+	// untagged commands run one after the other, last is the done channel
+	// of the latest one
+	untagged map[*response]struct{}
+	last     chan struct{}
+}
+
+func (srv *Server) newConn(nc net.Conn) *conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &conn{
+		srv:      srv,
+		nc:       nc,
+		r:        proto.NewReader(nc, srv.timeout()),
+		w:        proto.NewWriter(nc, srv.timeout()),
+		ctx:      ctx,
+		cancel:   cancel,
+		active:   make(map[string]*response),
+		untagged: make(map[*response]struct{}),
+	}
+}
+
+func (c *conn) write(word, tag string, pairs []proto.Pair) error {
+	c.w.BeginSentence()
+	c.w.WriteWord(word)
+	if tag != "" {
+		c.w.WriteWord(".tag=" + tag)
+	}
+	for _, p := range pairs {
+		c.w.WriteWord("=" + p.Key + "=" + p.Value)
+	}
+	return c.w.EndSentence()
+}
+
+func (c *conn) trap(tag, message string) error {
+	err := c.write("!trap", tag, []proto.Pair{{Key: "message", Value: message}})
+	if err != nil {
+		return err
+	}
+	return c.write("!done", tag, nil)
+}
+
+func (c *conn) serve() error {
+	defer c.wg.Wait()
+	defer c.cancel()
+	defer c.nc.Close()
+
+	for {
+		sen, err := c.r.ReadSentence(false)
+		if err != nil {
+			return err
+		}
+		switch {
+		case sen.Word == "":
+			// API docs say that empty sentences should be ignored
+		case sen.Word == "/login":
+			err = c.login(sen)
+		case !c.loggedIn:
+			err = c.trap(sen.Tag, "not logged in")
+		case sen.Word == "/quit":
+			c.write("!fatal", sen.Tag, []proto.Pair{{Key: "message", Value: "session terminated on request"}})
+			return nil
+		case sen.Word == "/cancel":
+			err = c.cancelCommand(sen)
+		default:
+			err = c.dispatch(sen)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *conn) login(sen *proto.Sentence) error {
+	user := sen.Map["name"]
+	password, hasPassword := sen.Map["password"]
+	response, hasResponse := sen.Map["response"]
+
+	auth := c.srv.Auth
+	ca, canChallenge := auth.(ChallengeAuthenticator)
+
+	switch {
+	case hasResponse:
+		challenge := c.challenge
+		c.challenge = nil
+		if challenge == nil || !canChallenge {
+			return c.trap(sen.Tag, "invalid user name or password (6)")
+		}
+		want, ok := ca.Password(user)
+		if !ok || challengeResponse(challenge, want) != response {
+			return c.trap(sen.Tag, "invalid user name or password (6)")
+		}
+	case auth == nil:
+		// all logins succeed, with or without password
+	case !hasPassword || (c.srv.Challenge && canChallenge):
+		if !canChallenge {
+			return c.trap(sen.Tag, "invalid user name or password (6)")
+		}
+		c.challenge = make([]byte, 16)
+		rand.Read(c.challenge)
+		return c.write("!done", sen.Tag, []proto.Pair{{Key: "ret", Value: hex.EncodeToString(c.challenge)}})
+	case auth != nil && !auth.Authenticate(user, password):
+		return c.trap(sen.Tag, "invalid user name or password (6)")
+	}
+	c.loggedIn = true
+	c.user = user
+	return c.write("!done", sen.Tag, nil)
+}
+
+// cancelCommand cancels the command with the given tag, or all commands if
+// no tag is given. The /cancel is done once their replies are finished,
+// which is awaited off the read loop.
+func (c *conn) cancelCommand(sen *proto.Sentence) error {
+	tag, ok := sen.Map["tag"]
+	var rs []*response
+	c.mu.Lock()
+	if !ok {
+		for _, r := range c.active {
+			rs = append(rs, r)
+		}
+		for r := range c.untagged {
+			rs = append(rs, r)
+		}
+	} else if r, found := c.active[tag]; found {
+		rs = append(rs, r)
+	}
+	c.mu.Unlock()
+
+	if ok && len(rs) == 0 {
+		return c.trap(sen.Tag, "no such command")
+	}
+	for _, r := range rs {
+		r.cancel()
+	}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for _, r := range rs {
+			<-r.done
+		}
+		c.write("!done", sen.Tag, nil)
+	}()
+	return nil
+}
+
+func (c *conn) dispatch(sen *proto.Sentence) error {
+	ctx, cancel := context.WithCancel(c.ctx)
+	r := &response{
+		c:      c,
+		tag:    sen.Tag,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	cmd := &Command{
+		Sentence:   sen,
+		User:       c.user,
+		RemoteAddr: c.nc.RemoteAddr(),
+		ctx:        ctx,
+	}
+
+	if sen.Tag == "" {
+		// Untagged commands are answered in order, but not on the read
+		// loop, so a /cancel is read while e.g. a listen runs.
+		c.mu.Lock()
+		prev := c.last
+		c.last = r.done
+		c.untagged[r] = struct{}{}
+		c.mu.Unlock()
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			if prev != nil {
+				<-prev
+			}
+			c.run(r, cmd)
+			c.mu.Lock()
+			delete(c.untagged, r)
+			c.mu.Unlock()
+		}()
+		return nil
+	}
+
+	c.mu.Lock()
+	_, dup := c.active[sen.Tag]
+	if !dup {
+		c.active[sen.Tag] = r
+	}
+	c.mu.Unlock()
+	if dup {
+		cancel()
+		return c.trap(sen.Tag, "duplicate tag")
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.run(r, cmd)
+		c.mu.Lock()
+		delete(c.active, sen.Tag)
+		c.mu.Unlock()
+	}()
+	return nil
+}
+
+func (c *conn) run(r *response, cmd *Command) {
+	defer r.cancel()
+	defer r.finish()
+	switch {
+	case r.ctx.Err() != nil:
+		// cancelled while queued behind other untagged commands
+	case c.srv.Handler == nil:
+		r.Trap(0, "no such command")
+	default:
+		c.srv.Handler.ServeAPI(r, cmd)
+	}
+}
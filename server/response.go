@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/swoga/go-routeros/proto"
+)
+
+// NoCategory omits the category of a !trap.
+const NoCategory = -1
+
+var errFinished = errors.New("RouterOS API server: reply already finished")
+
+// ResponseWriter writes the reply sentences of one command. The .tag of the
+// command is added to every sentence. It is safe for concurrent use.
+type ResponseWriter interface {
+	// Re writes a !re sentence.
+	Re(pairs ...proto.Pair) error
+	// Done writes the final !done sentence.
+	Done(pairs ...proto.Pair) error
+	// Trap writes a !trap sentence. The reply must still be finished with
+	// Done. Use NoCategory to omit the category.
+	Trap(category int, message string) error
+	// Fatal writes a !fatal sentence and closes the connection.
+	Fatal(message string) error
+}
+
+type response struct {
+	c      *conn
+	tag    string
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.Mutex
+	finished bool
+}
+
+func (r *response) write(word string, pairs []proto.Pair) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.finished {
+		return errFinished
+	}
+	if word == "!done" || word == "!fatal" {
+		r.finished = true
+	}
+	return r.c.write(word, r.tag, pairs)
+}
+
+func (r *response) Re(pairs ...proto.Pair) error {
+	return r.write("!re", pairs)
+}
+
+func (r *response) Done(pairs ...proto.Pair) error {
+	return r.write("!done", pairs)
+}
+
+func (r *response) Trap(category int, message string) error {
+	var pairs []proto.Pair
+	if category != NoCategory {
+		pairs = append(pairs, proto.Pair{Key: "category", Value: strconv.Itoa(category)})
+	}
+	pairs = append(pairs, proto.Pair{Key: "message", Value: message})
+	return r.write("!trap", pairs)
+}
+
+func (r *response) Fatal(message string) error {
+	err := r.write("!fatal", []proto.Pair{{Key: "message", Value: message}})
+	r.c.nc.Close()
+	return err
+}
+
+// finish writes !done unless the handler has finished the reply. A cancelled
+// command is reported as interrupted first.
+func (r *response) finish() {
+	defer close(r.done)
+	r.mu.Lock()
+	finished := r.finished
+	r.mu.Unlock()
+	if finished {
+		return
+	}
+	if r.ctx.Err() != nil {
+		r.Trap(2, "interrupted")
+	}
+	r.Done()
+}
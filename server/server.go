@@ -0,0 +1,194 @@
+/*
+Package server implements the server side of the RouterOS API protocol.
+
+A Server accepts connections, handles /login, /cancel and /quit itself and
+passes every other command to a Handler:
+
+	srv := &server.Server{
+		Handler: server.HandlerFunc(func(w server.ResponseWriter, cmd *server.Command) {
+			w.Re(proto.Pair{Key: "name", Value: "router1"})
+			w.Done()
+		}),
+		Auth: server.Users{"admin": "secret"},
+	}
+	srv.Serve(l)
+
+Commands carrying a .tag are handled concurrently, each in its own goroutine;
+untagged commands are handled one after another in the order they arrive.
+*/
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/swoga/go-routeros/proto"
+)
+
+// ErrServerClosed is returned by Serve after a call to Close.
+var ErrServerClosed = errors.New("RouterOS API server closed")
+
+// Handler responds to a RouterOS API command.
+//
+// ServeAPI should write any number of !re sentences followed by exactly one
+// of Done or Fatal. If it returns without doing so, the server writes !done
+// on its behalf, preceded by an "interrupted" !trap if the command has been
+// cancelled.
+type Handler interface {
+	ServeAPI(w ResponseWriter, cmd *Command)
+}
+
+// HandlerFunc adapts an ordinary function to the Handler interface.
+type HandlerFunc func(w ResponseWriter, cmd *Command)
+
+// ServeAPI calls f(w, cmd).
+func (f HandlerFunc) ServeAPI(w ResponseWriter, cmd *Command) {
+	f(w, cmd)
+}
+
+// Command is a command sentence received from a client. The embedded
+// Sentence's Word holds the command path, e.g. /ip/address/print.
+type Command struct {
+	*proto.Sentence
+	// User is the name the client logged in with.
+	User string
+	// RemoteAddr is the network address of the client.
+	RemoteAddr net.Addr
+
+	ctx context.Context
+}
+
+// Context returns the context of the command. It is cancelled when the
+// client sends /cancel for the command's tag or the connection ends.
+func (cmd *Command) Context() context.Context {
+	return cmd.ctx
+}
+
+// Server serves the RouterOS API protocol.
+type Server struct {
+	// Handler handles all commands except /login, /cancel and /quit.
+	Handler Handler
+	// Auth checks the credentials of /login. All logins succeed if Auth is nil.
+	Auth Authenticator
+	// Challenge makes the server answer /login with a challenge as devices
+	// before RouterOS 6.43 did. Auth must be a ChallengeAuthenticator.
+	Challenge bool
+	// Timeout is the read and write timeout for a single sentence.
+	// Zero means one minute.
+	Timeout time.Duration
+	// ErrorLog logs connection errors. If nil, the log package's standard
+	// logger is used.
+	ErrorLog *log.Logger
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*conn]struct{}
+	closed    bool
+}
+
+// Serve accepts connections on l and serves each of them in a new goroutine.
+// It always returns a non-nil error; after Close it is ErrServerClosed.
+func (srv *Server) Serve(l net.Listener) error {
+	srv.mu.Lock()
+	if srv.closed {
+		srv.mu.Unlock()
+		return ErrServerClosed
+	}
+	if srv.listeners == nil {
+		srv.listeners = make(map[net.Listener]struct{})
+	}
+	srv.listeners[l] = struct{}{}
+	srv.mu.Unlock()
+
+	defer func() {
+		srv.mu.Lock()
+		delete(srv.listeners, l)
+		srv.mu.Unlock()
+	}()
+
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			srv.mu.Lock()
+			closed := srv.closed
+			srv.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		go func() {
+			err := srv.ServeConn(nc)
+			if err != nil {
+				srv.logf("RouterOS API server: %s: %s", nc.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// ServeConn serves the API protocol on nc until the client quits or the
+// connection fails. nc is closed on return.
+func (srv *Server) ServeConn(nc net.Conn) error {
+	c := srv.newConn(nc)
+
+	srv.mu.Lock()
+	if srv.closed {
+		srv.mu.Unlock()
+		nc.Close()
+		return ErrServerClosed
+	}
+	if srv.conns == nil {
+		srv.conns = make(map[*conn]struct{})
+	}
+	srv.conns[c] = struct{}{}
+	srv.mu.Unlock()
+
+	defer func() {
+		srv.mu.Lock()
+		delete(srv.conns, c)
+		srv.mu.Unlock()
+	}()
+
+	err := c.serve()
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// Close closes all listeners and connections of srv.
+func (srv *Server) Close() error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.closed = true
+	var err error
+	for l := range srv.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for c := range srv.conns {
+		c.nc.Close()
+	}
+	return err
+}
+
+func (srv *Server) timeout() time.Duration {
+	if srv.Timeout > 0 {
+		return srv.Timeout
+	}
+	return time.Minute
+}
+
+func (srv *Server) logf(format string, args ...any) {
+	if srv.ErrorLog != nil {
+		srv.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
@@ -0,0 +1,264 @@
+package server_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/proto"
+	"github.com/swoga/go-routeros/server"
+)
+
+func echoHandler(w server.ResponseWriter, cmd *server.Command) {
+	switch cmd.Word {
+	case "/echo":
+		for _, p := range cmd.List {
+			w.Re(p)
+		}
+		w.Done(proto.Pair{Key: "user", Value: cmd.User})
+	case "/wait":
+		<-cmd.Context().Done()
+	case "/trap":
+		w.Trap(server.NoCategory, "some error")
+		w.Done()
+	case "/fatal":
+		w.Fatal("going away")
+	default:
+		w.Trap(0, "no such command")
+	}
+}
+
+func newPair(t *testing.T, srv *server.Server) *routeros.Client {
+	s, c := net.Pipe()
+	go srv.ServeConn(s)
+	client, err := routeros.NewClient(c, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestLogin(t *testing.T) {
+	for _, challenge := range []bool{false, true} {
+		srv := &server.Server{
+			Handler:   server.HandlerFunc(echoHandler),
+			Auth:      server.Users{"admin": "secret"},
+			Challenge: challenge,
+		}
+
+		c := newPair(t, srv)
+		_, err := c.Run("/echo")
+		if err == nil || err.Error() != "from RouterOS device: not logged in" {
+			t.Fatalf("challenge=%v: Run before login=%v; want not logged in", challenge, err)
+		}
+		err = c.Login("admin", "wrong")
+		if err == nil || err.Error() != "from RouterOS device: invalid user name or password (6)" {
+			t.Fatalf("challenge=%v: Login=%v; want invalid user name or password", challenge, err)
+		}
+		err = c.Login("admin", "secret")
+		if err != nil {
+			t.Fatalf("challenge=%v: Login=%v", challenge, err)
+		}
+		r, err := c.Run("/echo", "=a=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "!re @ [{`a` `1`}]\n!done @ [{`user` `admin`}]"
+		if r.String() != want {
+			t.Fatalf("challenge=%v: Run=%s; want %s", challenge, r, want)
+		}
+	}
+}
+
+func TestAuthenticatorFunc(t *testing.T) {
+	srv := &server.Server{
+		Handler: server.HandlerFunc(echoHandler),
+		Auth: server.AuthenticatorFunc(func(user, password string) bool {
+			return user == "ops"
+		}),
+	}
+	err := newPair(t, srv).Login("ops", "anything")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = newPair(t, srv).Login("dev", "anything")
+	if err == nil {
+		t.Fatal("Login succeeded; want error")
+	}
+}
+
+func TestLoginWithoutAuth(t *testing.T) {
+	r, send := rawConn(t, &server.Server{Handler: server.HandlerFunc(echoHandler)})
+	send("/login", "=name=guest")
+	expect(t, r, "!done")
+	send("/echo")
+	expect(t, r, "!done")
+}
+
+func TestTrapAndFatal(t *testing.T) {
+	c := newPair(t, &server.Server{Handler: server.HandlerFunc(echoHandler)})
+	err := c.Login("admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Run("/trap")
+	if err == nil || err.Error() != "from RouterOS device: some error" {
+		t.Fatalf("Run(/trap)=%v; want some error", err)
+	}
+	_, err = c.Run("/xxx")
+	if err == nil || err.Error() != "from RouterOS device: no such command" {
+		t.Fatalf("Run(/xxx)=%v; want no such command", err)
+	}
+	_, err = c.Run("/fatal")
+	if err == nil || err.Error() != "from RouterOS device: going away" {
+		t.Fatalf("Run(/fatal)=%v; want going away", err)
+	}
+}
+
+func TestConcurrentCancel(t *testing.T) {
+	c := newPair(t, &server.Server{Handler: server.HandlerFunc(echoHandler)})
+	err := c.Login("admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := c.Listen("/wait")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The tagged /wait command must not block other commands.
+	r, err := c.Run("/echo", "=x=y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Re) != 1 {
+		t.Fatalf("Run(/echo)=%s; want one !re", r)
+	}
+
+	_, err = l.Cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range l.Chan() {
+	}
+	if l.Err() != nil {
+		t.Fatal(l.Err())
+	}
+	if l.Done.Word != "!trap" || l.Done.Map["message"] != "interrupted" {
+		t.Fatalf("Done=%s; want interrupted !trap", l.Done)
+	}
+
+	_, err = c.Run("/cancel", "=tag=unknown")
+	if err == nil || err.Error() != "from RouterOS device: no such command" {
+		t.Fatalf("Run(/cancel)=%v; want no such command", err)
+	}
+}
+
+func TestServeClose(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &server.Server{Handler: server.HandlerFunc(echoHandler)}
+	errC := make(chan error, 1)
+	go func() {
+		errC <- srv.Serve(l)
+	}()
+
+	c, err := routeros.Dial(l.Addr().String(), "admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	srv.Close()
+	err = <-errC
+	if err != server.ErrServerClosed {
+		t.Fatalf("Serve()=%v; want ErrServerClosed", err)
+	}
+	_, err = c.Run("/echo")
+	if err == nil {
+		t.Fatal("Run succeeded after Close; want error")
+	}
+}
+
+// rawConn logs in on a connection to srv and returns it for writing
+// sentences by hand.
+func rawConn(t *testing.T, srv *server.Server) (proto.Reader, func(words ...string)) {
+	s, c := net.Pipe()
+	go srv.ServeConn(s)
+	t.Cleanup(func() { c.Close() })
+	r := proto.NewReader(c, time.Second)
+	w := proto.NewWriter(c, time.Second)
+	send := func(words ...string) {
+		w.BeginSentence()
+		for _, word := range words {
+			w.WriteWord(word)
+		}
+		err := w.EndSentence()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	send("/login", "=name=admin", "=password=")
+	sen, err := r.ReadSentence(true)
+	if err != nil || sen.Word != "!done" {
+		t.Fatalf("login=%v, %v", sen, err)
+	}
+	return r, send
+}
+
+func expect(t *testing.T, r proto.Reader, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		sen, err := r.ReadSentence(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := sen.Word
+		if sen.Tag != "" {
+			got += " " + sen.Tag
+		}
+		if got != w {
+			t.Fatalf("got %s; want %s", sen, w)
+		}
+	}
+}
+
+func TestUntaggedCancel(t *testing.T) {
+	r, send := rawConn(t, &server.Server{Handler: server.HandlerFunc(echoHandler)})
+	// untagged commands do not block the read loop, so /cancel is read
+	send("/wait")
+	send("/cancel")
+	expect(t, r, "!trap", "!done", "!done")
+	// and they are still answered in order, queued ones are cancelled
+	// without running
+	send("/wait")
+	send("/echo", "=a=1")
+	send("/cancel")
+	expect(t, r, "!trap", "!done", "!trap", "!done", "!done")
+}
+
+func TestSlowCancel(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	r, send := rawConn(t, &server.Server{Handler: server.HandlerFunc(func(w server.ResponseWriter, cmd *server.Command) {
+		if cmd.Word == "/slow" {
+			// ignores the cancellation until released
+			close(started)
+			<-release
+			return
+		}
+		echoHandler(w, cmd)
+	})})
+	send("/slow", ".tag=s")
+	<-started
+	send("/cancel", "=tag=s", ".tag=c")
+	// a handler slow to notice the cancellation does not hold up others
+	send("/echo", ".tag=e")
+	expect(t, r, "!done e")
+}
@@ -0,0 +1,54 @@
+/*
+Package servertest provides API servers on the loopback interface for tests,
+similar to net/http/httptest.
+
+	d := sim.New()
+	c := servertest.Dial(t, d, routeros.WithAsync())
+	c.Run("/ip/address/print")
+
+Anything with a Serve method taking a net.Listener can be started, e.g. a
+*server.Server or a *sim.Device.
+*/
+package servertest
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/swoga/go-routeros"
+)
+
+// Server serves the API protocol on the connections of a listener, like
+// server.Server and sim.Device.
+type Server interface {
+	Serve(l net.Listener) error
+}
+
+// Start serves srv on a free loopback port until the test ends and returns
+// its address.
+func Start(t testing.TB, srv Server) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go srv.Serve(l)
+	return l.Addr().String()
+}
+
+// Dial starts srv like Start and returns a client logged in as admin with an
+// empty password and configured with opts. The client is closed when the
+// test ends.
+func Dial(t testing.TB, srv Server, opts ...routeros.Option) *routeros.Client {
+	t.Helper()
+	addr := Start(t, srv)
+	opts = append([]routeros.Option{routeros.WithCredentials("admin", "")}, opts...)
+	c, err := routeros.Connect(context.Background(), addr, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
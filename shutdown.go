@@ -0,0 +1,148 @@
+package routeros
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrClosed is reported by Err after Close or Shutdown.
+	ErrClosed = errors.New("RouterOS: client closed")
+
+	errShuttingDown = errors.New("RouterOS: client is shutting down")
+)
+
+// Err returns the reason the client ended once Done is closed: ErrClosed
+// after Close or Shutdown, or the error that broke the connection. It
+// returns nil while the client is usable.
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		return c.endErr
+	default:
+		return nil
+	}
+}
+
+// Shutdown closes the client gracefully. New commands are rejected, active
+// listen commands are cancelled on the device, and running commands are
+// given until ctx is done to complete. Then /quit is sent so the device ends
+// the session, and the connection is closed. It returns ctx.Err() if
+// commands were still running when ctx was done, joined with the errors of
+// cancelling listens other than the device rejecting the /cancel.
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	if c.closing || c.shuttingDown {
+		c.mu.Unlock()
+		return nil
+	}
+	c.shuttingDown = true
+	var listens []string
+	for tag, r := range c.tags {
+		if _, ok := r.(*ListenReply); ok {
+			listens = append(listens, tag)
+		}
+	}
+	// the /cancel commands are pending as well, begin would reject them
+	c.pending += len(listens)
+	var drained chan struct{}
+	if c.pending > 0 {
+		drained = make(chan struct{})
+		c.drained = drained
+	}
+	c.mu.Unlock()
+
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, tag := range listens {
+		// The reply is not awaited here, the listen ends when the device
+		// confirms the cancellation.
+		go func() {
+			defer c.finish()
+			_, err := c.run([]string{"/cancel", "=tag=" + tag})
+			var devErr *DeviceError
+			if err != nil && !errors.As(err, &devErr) {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("RouterOS: cancelling listen %s: %w", tag, err))
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var err error
+	if drained != nil {
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	mu.Lock()
+	err = errors.Join(append([]error{err}, errs...)...)
+	mu.Unlock()
+
+	c.mu.Lock()
+	c.closing = true
+	c.mu.Unlock()
+	c.quit(ctx)
+	c.end(ErrClosed)
+	c.conn.Close()
+	return err
+}
+
+// quit sends /quit and waits until the device has closed the session or
+// ctx is done.
+func (c *Client) quit(ctx context.Context) {
+	c.w.BeginSentence()
+	c.w.WriteWord("/quit")
+	if c.w.EndSentence() != nil || ctx.Err() != nil {
+		return
+	}
+	if c.async {
+		timeout, timer := newTimeoutTimer(c.timeout)
+		if timer != nil {
+			defer timer.Stop()
+		}
+		select {
+		case <-c.loopDone:
+		case <-ctx.Done():
+		case <-timeout:
+		}
+		return
+	}
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.Close()
+	})
+	defer stop()
+	c.readReply()
+}
+
+// begin registers a command. It fails when the client is shutting down.
+func (c *Client) begin() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.shuttingDown {
+		return errShuttingDown
+	}
+	c.pending++
+	return nil
+}
+
+// finish unregisters a command.
+func (c *Client) finish() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.finishLocked()
+}
+
+func (c *Client) finishLocked() {
+	c.pending--
+	if c.pending == 0 && c.drained != nil {
+		close(c.drained)
+		c.drained = nil
+	}
+}
@@ -0,0 +1,99 @@
+package routeros_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+)
+
+func TestShutdown(t *testing.T) {
+	d, addr := startSim(t)
+	c, err := routeros.Connect(context.Background(), addr, routeros.WithCredentials("admin", ""), routeros.WithAsync())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := c.Listen("/interface/listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for d.Listeners("/interface") == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = c.Shutdown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range l.Chan() {
+	}
+	if l.Err() != nil || l.Done == nil || l.Done.Map["category"] != "2" {
+		t.Fatalf("listen Err=%v Done=%v; want interrupted", l.Err(), l.Done)
+	}
+	if d.Listeners("/interface") != 0 {
+		t.Fatal("listen still active on the device")
+	}
+	select {
+	case <-c.Done():
+	default:
+		t.Fatal("Done not closed")
+	}
+	if c.Err() != routeros.ErrClosed {
+		t.Fatalf("Err()=%v; want ErrClosed", c.Err())
+	}
+	_, err = c.Run("/system/identity/print")
+	if err == nil {
+		t.Fatal("Run after Shutdown succeeded")
+	}
+}
+
+func TestShutdownSync(t *testing.T) {
+	_, addr := startSim(t)
+	c, err := routeros.Connect(context.Background(), addr, routeros.WithCredentials("admin", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Err() != nil {
+		t.Fatalf("Err()=%v before Shutdown", c.Err())
+	}
+	err = c.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Err() != routeros.ErrClosed {
+		t.Fatalf("Err()=%v; want ErrClosed", c.Err())
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	d, addr := startSim(t)
+	c, err := routeros.Connect(context.Background(), addr, routeros.WithCredentials("admin", ""), routeros.WithAsync())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nobody reads the listen, so its cancellation cannot be delivered
+	_, err = c.ListenArgsQueue([]string{"/interface/listen"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for d.Listeners("/interface") == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	d.Set("/interface", "ether1", map[string]string{"comment": "x"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = c.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown()=%v; want deadline exceeded", err)
+	}
+	if c.Err() != routeros.ErrClosed {
+		t.Fatalf("Err()=%v; want ErrClosed", c.Err())
+	}
+}
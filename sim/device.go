@@ -0,0 +1,510 @@
+/*
+Package sim implements an in-memory RouterOS device speaking the API wire
+protocol.
+
+It supports a subset of the RouterOS menus with their usual commands (print,
+get, add, set, remove, move, enable, disable and listen) and is meant for
+testing code built on routeros.Client without hardware:
+
+	d := sim.New()
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go d.Serve(l)
+	c, _ := routeros.Dial(l.Addr().String(), "admin", "")
+
+Device implements server.Handler, so it can also be served by a custom
+server.Server.
+*/
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errInvalidQuery = &trapError{message: "invalid query"}
+
+// trapError is replied to a command as !trap followed by !done.
+type trapError struct {
+	category int
+	message  string
+}
+
+func (err *trapError) Error() string {
+	return err.message
+}
+
+type pair struct {
+	key, value string
+}
+
+// Device is a simulated RouterOS device. The zero value is not usable, call
+// New instead.
+type Device struct {
+	// Users maps the user names accepted by /login to their passwords.
+	Users map[string]string
+	// Timeout is the write timeout for replies.
+	Timeout time.Duration
+
+	mu     sync.Mutex
+	menus  map[string]*menu
+	lastID map[*menu]uint64
+	start  time.Time
+}
+
+// New returns a Device with the default menus, five ethernet interfaces
+// ether1 to ether5, the routeros package and the user admin with an empty
+// password.
+func New() *Device {
+	d := &Device{
+		Users:   map[string]string{"admin": ""},
+		Timeout: 10 * time.Second,
+		menus:   make(map[string]*menu),
+		lastID:  make(map[*menu]uint64),
+		start:   time.Now(),
+	}
+	for _, m := range defaultMenus() {
+		d.menus[m.path] = m
+		if m.singleton {
+			m.items = []*item{{props: defaults(m)}}
+		}
+	}
+	for i := 1; i <= 5; i++ {
+		name := "ether" + strconv.Itoa(i)
+		_, err := d.Add("/interface", map[string]string{
+			"name":         name,
+			"default-name": name,
+			"mac-address":  fmt.Sprintf("02:00:00:00:00:%02X", i),
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+	_, err := d.Add("/system/package", map[string]string{
+		"name":       "routeros",
+		"version":    "7.15.3",
+		"build-time": "Jul/24/2024 12:00:00",
+	})
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func defaults(m *menu) map[string]string {
+	props := make(map[string]string)
+	for _, f := range m.fields {
+		if f.def != "" {
+			props[f.name] = f.def
+		}
+	}
+	return props
+}
+
+func (d *Device) menu(path string) (*menu, error) {
+	m, ok := d.menus[path]
+	if !ok {
+		return nil, &trapError{message: "no such command prefix"}
+	}
+	return m, nil
+}
+
+// Add adds an item to the menu at path and returns its .id. Unlike the API
+// command it may set read-only properties and add to any table menu.
+func (d *Device) Add(path string, props map[string]string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, err := d.menu(path)
+	if err != nil {
+		return "", err
+	}
+	return d.add(m, props, "", true)
+}
+
+// Set updates the item with the given .id or name in the menu at path. For
+// singleton menus such as /system/identity id is ignored.
+func (d *Device) Set(path, id string, props map[string]string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, err := d.menu(path)
+	if err != nil {
+		return err
+	}
+	return d.set(m, []string{id}, props, true)
+}
+
+// Remove removes the item with the given .id or name from the menu at path.
+func (d *Device) Remove(path, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, err := d.menu(path)
+	if err != nil {
+		return err
+	}
+	return d.remove(m, []string{id})
+}
+
+// Items returns a copy of the items in the menu at path including their .id.
+func (d *Device) Items(path string) []map[string]string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, ok := d.menus[path]
+	if !ok {
+		return nil
+	}
+	var items []map[string]string
+	for _, it := range m.items {
+		items = append(items, toMap(d.render(m, it)))
+	}
+	return items
+}
+
+// Listeners returns the number of active listen and follow commands on the
+// menu at path. Tagged commands are handled concurrently, so tests can wait for a
+// listen to become active before changing the menu.
+func (d *Device) Listeners(path string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, ok := d.menus[path]
+	if !ok {
+		return 0
+	}
+	return len(m.listeners)
+}
+
+func toMap(ps []pair) map[string]string {
+	props := make(map[string]string, len(ps))
+	for _, p := range ps {
+		props[p.key] = p.value
+	}
+	return props
+}
+
+func (d *Device) render(m *menu, it *item) []pair {
+	if m.path == "/system/resource" {
+		it.props["uptime"] = formatDuration(time.Since(d.start))
+	}
+	return m.pairs(it)
+}
+
+func (d *Device) add(m *menu, given map[string]string, placeBefore string, internal bool) (string, error) {
+	if m.singleton || (!m.addable && !internal) {
+		return "", &trapError{message: "no such command"}
+	}
+	err := m.checkProps(given, internal)
+	if err != nil {
+		return "", err
+	}
+	props := defaults(m)
+	for k, v := range given {
+		if v != "" {
+			props[k] = v
+		}
+	}
+	err = m.checkRequired(props)
+	if err != nil {
+		return "", err
+	}
+	if m.validate != nil {
+		err = m.validate(d, props)
+		if err != nil {
+			return "", err
+		}
+	}
+	err = m.checkUnique(props, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	pos := len(m.items)
+	if placeBefore != "" {
+		if !m.ordered {
+			return "", &trapError{message: "unknown parameter place-before"}
+		}
+		before := m.find(placeBefore)
+		if before == nil {
+			return "", &trapError{message: "no such item"}
+		}
+		pos = m.index(before)
+	}
+	d.lastID[m]++
+	it := &item{id: d.lastID[m], props: props}
+	m.items = slices.Insert(m.items, pos, it)
+	d.notify(m, it, false)
+	return it.idString(), nil
+}
+
+func (d *Device) lookup(m *menu, refs []string) ([]*item, error) {
+	if m.singleton {
+		return m.items, nil
+	}
+	var items []*item
+	for _, ref := range refs {
+		it := m.find(ref)
+		if it == nil {
+			return nil, &trapError{message: "no such item"}
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+func (d *Device) set(m *menu, refs []string, given map[string]string, internal bool) error {
+	items, err := d.lookup(m, refs)
+	if err != nil {
+		return err
+	}
+	err = m.checkProps(given, internal)
+	if err != nil {
+		return err
+	}
+	// all items are validated before any is changed, so a failing set
+	// leaves the menu untouched
+	pending := make(map[*item]map[string]string, len(items))
+	for _, it := range items {
+		props := make(map[string]string, len(it.props))
+		for k, v := range it.props {
+			props[k] = v
+		}
+		for k, v := range given {
+			if v == "" {
+				f, _ := m.field(k)
+				v = f.def
+			}
+			props[k] = v
+		}
+		err = m.checkRequired(props)
+		if err != nil {
+			return err
+		}
+		if m.validate != nil {
+			err = m.validate(d, props)
+			if err != nil {
+				return err
+			}
+		}
+		pending[it] = props
+	}
+	for _, it := range items {
+		err = m.checkUnique(pending[it], it, pending)
+		if err != nil {
+			return err
+		}
+	}
+	for _, it := range items {
+		it.props = pending[it]
+		d.notify(m, it, false)
+	}
+	return nil
+}
+
+func (d *Device) remove(m *menu, refs []string) error {
+	if m.singleton {
+		return &trapError{message: "no such command"}
+	}
+	items, err := d.lookup(m, refs)
+	if err != nil {
+		return err
+	}
+	for _, it := range items {
+		i := m.index(it)
+		if i < 0 {
+			continue
+		}
+		m.items = slices.Delete(m.items, i, i+1)
+		d.notify(m, it, true)
+	}
+	return nil
+}
+
+func (d *Device) move(m *menu, refs []string, destination string) error {
+	if !m.ordered {
+		return &trapError{message: "no such command"}
+	}
+	items, err := d.lookup(m, refs)
+	if err != nil {
+		return err
+	}
+	var dest *item
+	if destination != "" {
+		dest = m.find(destination)
+		if dest == nil {
+			return &trapError{message: "no such item"}
+		}
+	}
+	m.items = slices.DeleteFunc(m.items, func(it *item) bool {
+		return slices.Contains(items, it)
+	})
+	pos := len(m.items)
+	if dest != nil && !slices.Contains(items, dest) {
+		pos = m.index(dest)
+	}
+	m.items = slices.Insert(m.items, pos, items...)
+	return nil
+}
+
+// notify must be called with d.mu held.
+func (d *Device) notify(m *menu, it *item, dead bool) {
+	if len(m.listeners) == 0 {
+		return
+	}
+	ps := []pair{{".id", it.idString()}, {".dead", "yes"}}
+	if !dead {
+		ps = d.render(m, it)
+	}
+	for l := range m.listeners {
+		l.push(ps)
+	}
+}
+
+// exec runs a command other than listen, /login, /cancel and /quit.
+func (d *Device) exec(path string, args []pair, query []string) ([][]pair, []pair, error) {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return nil, nil, &trapError{message: "no such command prefix"}
+	}
+	cmd := path[i+1:]
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, err := d.menu(path[:i])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attrs := make(map[string]string)
+	for _, a := range args {
+		attrs[a.key] = a.value
+	}
+	refs := splitRefs(attrs)
+	props := make(map[string]string)
+	for k, v := range attrs {
+		switch k {
+		case ".id", "numbers", "place-before", "destination":
+		default:
+			props[k] = v
+		}
+	}
+
+	switch cmd {
+	case "print", "getall":
+		return d.print(m, attrs, query)
+	case "get":
+		return d.get(m, refs, attrs["value-name"])
+	case "add":
+		id, err := d.add(m, props, attrs["place-before"], false)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, []pair{{"ret", id}}, nil
+	case "set":
+		if !m.singleton && len(refs) == 0 {
+			return nil, nil, &trapError{message: "no such item"}
+		}
+		return nil, nil, d.set(m, refs, props, false)
+	case "enable", "disable":
+		if _, ok := m.field("disabled"); !ok || m.singleton {
+			return nil, nil, &trapError{message: "no such command"}
+		}
+		return nil, nil, d.set(m, refs, map[string]string{"disabled": strconv.FormatBool(cmd == "disable")}, false)
+	case "remove":
+		if !m.addable {
+			return nil, nil, &trapError{message: "no such command"}
+		}
+		return nil, nil, d.remove(m, refs)
+	case "move":
+		return nil, nil, d.move(m, refs, attrs["destination"])
+	}
+	return nil, nil, &trapError{message: "no such command"}
+}
+
+func splitRefs(attrs map[string]string) []string {
+	ref := attrs[".id"]
+	if ref == "" {
+		ref = attrs["numbers"]
+	}
+	if ref == "" {
+		return nil
+	}
+	return strings.Split(ref, ",")
+}
+
+func (d *Device) print(m *menu, attrs map[string]string, query []string) ([][]pair, []pair, error) {
+	var res [][]pair
+	for _, it := range m.items {
+		ps := d.render(m, it)
+		ok, err := match(query, toMap(ps))
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			res = append(res, proplist(ps, attrs[".proplist"]))
+		}
+	}
+	if _, ok := attrs["count-only"]; ok {
+		return nil, []pair{{"ret", strconv.Itoa(len(res))}}, nil
+	}
+	return res, nil, nil
+}
+
+func (d *Device) get(m *menu, refs []string, name string) ([][]pair, []pair, error) {
+	if !m.singleton && len(refs) != 1 {
+		return nil, nil, &trapError{message: "no such item"}
+	}
+	items, err := d.lookup(m, refs)
+	if err != nil {
+		return nil, nil, err
+	}
+	ps := d.render(m, items[0])
+	if name == "" {
+		return [][]pair{ps}, nil, nil
+	}
+	if _, ok := m.field(name); !ok && name != ".id" {
+		return nil, nil, &trapError{message: "no such property: " + name}
+	}
+	return nil, []pair{{"ret", toMap(ps)[name]}}, nil
+}
+
+// proplist returns the pairs of ps selected by the comma separated list of
+// property names. All pairs are returned if list is empty.
+func proplist(ps []pair, list string) []pair {
+	if list == "" {
+		return ps
+	}
+	names := strings.Split(list, ",")
+	var res []pair
+	for _, p := range ps {
+		if slices.Contains(names, p.key) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// formatDuration formats d the way RouterOS prints uptime, e.g. 1w2d3h4m5s.
+func formatDuration(d time.Duration) string {
+	s := int64(d / time.Second)
+	var b strings.Builder
+	for _, u := range []struct {
+		suffix string
+		secs   int64
+	}{{"w", 7 * 24 * 3600}, {"d", 24 * 3600}, {"h", 3600}, {"m", 60}} {
+		if s >= u.secs {
+			fmt.Fprintf(&b, "%d%s", s/u.secs, u.suffix)
+			s %= u.secs
+		}
+	}
+	fmt.Fprintf(&b, "%ds", s)
+	return b.String()
+}
+
+func asTrap(err error) *trapError {
+	var t *trapError
+	if errors.As(err, &t) {
+		return t
+	}
+	return &trapError{message: err.Error()}
+}
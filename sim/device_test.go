@@ -0,0 +1,286 @@
+package sim_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/sim"
+)
+
+func newClient(t *testing.T, d *sim.Device) *routeros.Client {
+	server, client := net.Pipe()
+	go d.ServeConn(server)
+
+	c, err := routeros.NewClient(client, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	err = c.Login("admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func run(t *testing.T, c *routeros.Client, sentence ...string) *routeros.Reply {
+	t.Helper()
+	r, err := c.RunArgs(sentence)
+	if err != nil {
+		t.Fatalf("%#q: %s", sentence, err)
+	}
+	return r
+}
+
+func runError(t *testing.T, c *routeros.Client, want string, sentence ...string) {
+	t.Helper()
+	_, err := c.RunArgs(sentence)
+	if err == nil {
+		t.Fatalf("%#q succeeded; want error %q", sentence, want)
+	}
+	if err.Error() != "from RouterOS device: "+want {
+		t.Fatalf("%#q: %s; want %q", sentence, err, want)
+	}
+}
+
+func names(r *routeros.Reply, key string) string {
+	var s []string
+	for _, re := range r.Re {
+		s = append(s, re.Map[key])
+	}
+	return strings.Join(s, ",")
+}
+
+func waitListeners(t *testing.T, d *sim.Device, path string) {
+	t.Helper()
+	for i := 0; d.Listeners(path) == 0; i++ {
+		if i == 100 {
+			t.Fatalf("no listen on %s", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLoginFailure(t *testing.T) {
+	d := sim.New()
+	server, client := net.Pipe()
+	go d.ServeConn(server)
+	c, _ := routeros.NewClient(client, time.Second)
+	defer c.Close()
+
+	err := c.Login("admin", "wrong")
+	if err == nil || err.Error() != "from RouterOS device: invalid user name or password (6)" {
+		t.Fatalf("Login()=%v; want invalid user name or password", err)
+	}
+	runError(t, c, "not logged in", "/interface/print")
+}
+
+func TestPrintQuery(t *testing.T) {
+	d := sim.New()
+	c := newClient(t, d)
+	d.Set("/interface", "ether2", map[string]string{"disabled": "true"})
+	d.Set("/interface", "ether3", map[string]string{"running": "false"})
+
+	for _, test := range []struct {
+		query []string
+		want  string
+	}{
+		{nil, "ether1,ether2,ether3,ether4,ether5"},
+		{[]string{"?disabled=true"}, "ether2"},
+		{[]string{"?disabled=true", "?running=false", "?#|"}, "ether2,ether3"},
+		{[]string{"?disabled=false", "?#!"}, "ether2"},
+		{[]string{"?name=ether1", "?name=ether5", "?#|!"}, "ether2,ether3,ether4"},
+		{[]string{"?comment"}, ""},
+		{[]string{"?-comment", "?>mtu=1499", "?<.id=*3"}, "ether1,ether2"},
+	} {
+		r := run(t, c, append([]string{"/interface/print"}, test.query...)...)
+		if got := names(r, "name"); got != test.want {
+			t.Errorf("print %#q=%s; want %s", test.query, got, test.want)
+		}
+	}
+
+	runError(t, c, "invalid query", "/interface/print", "?#&")
+
+	r := run(t, c, "/interface/print", "?name=ether1", "=.proplist=name,mtu")
+	if got := r.Re[0].String(); got != "!re @ [{`name` `ether1`} {`mtu` `1500`}]" {
+		t.Errorf("print with .proplist=%s", got)
+	}
+
+	r = run(t, c, "/interface/print", "=count-only=", "?disabled=false")
+	if r.Done.Map["ret"] != "4" {
+		t.Errorf("count-only=%s; want 4", r.Done.Map["ret"])
+	}
+}
+
+func TestAddSetRemove(t *testing.T) {
+	c := newClient(t, sim.New())
+
+	r := run(t, c, "/ip/address/add", "=address=192.0.2.1/24", "=interface=ether1")
+	id := r.Done.Map["ret"]
+	if id != "*1" {
+		t.Fatalf("add returned %q; want *1", id)
+	}
+	runError(t, c, "failure: already have such address", "/ip/address/add", "=address=192.0.2.1/24", "=interface=ether1")
+	runError(t, c, "input does not match any value of interface", "/ip/address/add", "=address=192.0.2.2/24", "=interface=xxx")
+	runError(t, c, "failure: interface not specified", "/ip/address/add", "=address=192.0.2.2/24")
+	runError(t, c, "unknown parameter foo", "/ip/address/add", "=address=192.0.2.2/24", "=interface=ether1", "=foo=bar")
+
+	r = run(t, c, "/ip/address/print", "?.id="+id)
+	if r.Re[0].Map["network"] != "192.0.2.0" {
+		t.Errorf("network=%s; want 192.0.2.0", r.Re[0].Map["network"])
+	}
+
+	run(t, c, "/ip/address/set", "=.id="+id, "=comment=uplink")
+	r = run(t, c, "/ip/address/get", "=.id="+id, "=value-name=comment")
+	if r.Done.Map["ret"] != "uplink" {
+		t.Errorf("comment=%s; want uplink", r.Done.Map["ret"])
+	}
+
+	runError(t, c, "no such item", "/ip/address/set", "=.id=*99", "=comment=x")
+	run(t, c, "/ip/address/remove", "=.id="+id)
+	runError(t, c, "no such item", "/ip/address/remove", "=.id="+id)
+	runError(t, c, "no such command", "/interface/add", "=name=x")
+	runError(t, c, "no such command prefix", "/xxx/print")
+
+	run(t, c, "/ip/dns/static/add", "=name=example.com", "=address=192.0.2.1")
+	runError(t, c, "failure: entry already exists", "/ip/dns/static/add", "=name=example.com", "=address=192.0.2.1")
+
+	run(t, c, "/system/identity/set", "=name=router1")
+	r = run(t, c, "/system/identity/print")
+	if r.Re[0].Map["name"] != "router1" {
+		t.Errorf("identity=%s; want router1", r.Re[0].Map["name"])
+	}
+	r = run(t, c, "/system/resource/print")
+	if r.Re[0].Map["uptime"] == "" || r.Re[0].Map["board-name"] != "CHR" {
+		t.Errorf("resource=%s", r.Re[0])
+	}
+}
+
+func TestSetAllOrNothing(t *testing.T) {
+	d := sim.New()
+	c := newClient(t, d)
+	run(t, c, "/ip/address/add", "=address=192.0.2.1/24", "=interface=ether1")
+	run(t, c, "/ip/address/add", "=address=192.0.2.1/24", "=interface=ether2")
+
+	// the second item would duplicate the first after the set
+	runError(t, c, "failure: already have such address", "/ip/address/set", "=.id=*1,*2", "=interface=ether3")
+	r := run(t, c, "/ip/address/print")
+	if got := names(r, "interface"); got != "ether1,ether2" {
+		t.Fatalf("interfaces=%s; want ether1,ether2 unchanged", got)
+	}
+
+	run(t, c, "/ip/address/set", "=.id=*1,*2", "=comment=lab")
+	r = run(t, c, "/ip/address/print")
+	if got := names(r, "comment"); got != "lab,lab" {
+		t.Fatalf("comments=%s; want lab,lab", got)
+	}
+}
+
+func TestMove(t *testing.T) {
+	c := newClient(t, sim.New())
+
+	for _, chain := range []string{"a", "b", "c"} {
+		run(t, c, "/ip/firewall/filter/add", "=chain="+chain)
+	}
+	run(t, c, "/ip/firewall/filter/add", "=chain=d", "=place-before=*1")
+	r := run(t, c, "/ip/firewall/filter/print")
+	if got := names(r, "chain"); got != "d,a,b,c" {
+		t.Fatalf("chains=%s; want d,a,b,c", got)
+	}
+
+	run(t, c, "/ip/firewall/filter/move", "=numbers=*3,*4", "=destination=*1")
+	r = run(t, c, "/ip/firewall/filter/print")
+	if got := names(r, "chain"); got != "c,d,a,b" {
+		t.Fatalf("chains=%s; want c,d,a,b", got)
+	}
+
+	run(t, c, "/ip/firewall/filter/move", "=numbers=*3")
+	r = run(t, c, "/ip/firewall/filter/print")
+	if got := names(r, "chain"); got != "d,a,b,c" {
+		t.Fatalf("chains=%s; want d,a,b,c", got)
+	}
+}
+
+func TestListen(t *testing.T) {
+	d := sim.New()
+	c := newClient(t, d)
+
+	l, err := c.Listen("/ip/firewall/address-list/listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitListeners(t, d, "/ip/firewall/address-list")
+
+	id, err := d.Add("/ip/firewall/address-list", map[string]string{"list": "block", "address": "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sen := <-l.Chan()
+	if sen.Map[".id"] != id || sen.Map["list"] != "block" {
+		t.Fatalf("listen=%s; want added item", sen)
+	}
+
+	err = d.Remove("/ip/firewall/address-list", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sen = <-l.Chan()
+	if sen.Map[".id"] != id || sen.Map[".dead"] != "yes" {
+		t.Fatalf("listen=%s; want .dead", sen)
+	}
+
+	_, err = l.Cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range l.Chan() {
+	}
+	if l.Err() != nil {
+		t.Fatal(l.Err())
+	}
+	if l.Done.Map["category"] != "2" {
+		t.Fatalf("Done=%s; want !trap category 2", l.Done)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	d := sim.New()
+	c := newClient(t, d)
+
+	l, err := c.Listen("/interface/print", "=follow=", "?name=ether1", "=.proplist=name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sen := <-l.Chan()
+	if sen.Map["name"] != "ether1" || sen.Map[".section"] != "0" || len(sen.Map) != 2 {
+		t.Fatalf("follow=%s; want ether1 in section 0", sen)
+	}
+	sen = <-l.Chan()
+	if len(sen.Map) != 1 || sen.Map[".section"] != "0" {
+		t.Fatalf("follow=%s; want end of section 0", sen)
+	}
+
+	d.Set("/interface", "ether2", map[string]string{"comment": "x"})
+	d.Set("/interface", "ether1", map[string]string{"comment": "x"})
+	sen = <-l.Chan()
+	if sen.Map["name"] != "ether1" || sen.Map[".section"] == "0" {
+		t.Fatalf("follow=%s; want changed ether1", sen)
+	}
+	sen = <-l.Chan()
+	if len(sen.Map) != 1 {
+		t.Fatalf("follow=%s; want end of section", sen)
+	}
+
+	_, err = l.Cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range l.Chan() {
+	}
+	if l.Err() != nil {
+		t.Fatal(l.Err())
+	}
+}
@@ -0,0 +1,334 @@
+package sim
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// field describes one property of a menu item.
+type field struct {
+	name     string
+	def      string
+	required bool
+	readOnly bool
+}
+
+// menu is a RouterOS menu such as /ip/address. Table menus hold a list of
+// items addressed by .id, singleton menus (e.g. /system/identity) hold
+// exactly one item without .id.
+type menu struct {
+	path      string
+	fields    []field
+	singleton bool
+	// addable reports whether add and remove are allowed over the API.
+	addable bool
+	// ordered reports whether move is supported.
+	ordered bool
+	// unique lists the properties whose combined values must not repeat.
+	unique []string
+	// duplicate is the trap message for a violation of unique.
+	duplicate string
+	// validate checks and completes the properties of a new or updated item.
+	validate func(d *Device, props map[string]string) error
+
+	items     []*item
+	listeners map[*listener]struct{}
+}
+
+type item struct {
+	id    uint64
+	props map[string]string
+}
+
+func (it *item) idString() string {
+	return formatID(it.id)
+}
+
+func formatID(id uint64) string {
+	return "*" + strings.ToUpper(strconv.FormatUint(id, 16))
+}
+
+func (m *menu) field(name string) (field, bool) {
+	for _, f := range m.fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// pairs returns the properties of it in field order. The .id is included for
+// table menus.
+func (m *menu) pairs(it *item) []pair {
+	var ps []pair
+	if !m.singleton {
+		ps = append(ps, pair{".id", it.idString()})
+	}
+	for _, f := range m.fields {
+		v, ok := it.props[f.name]
+		if !ok || v == "" {
+			continue
+		}
+		ps = append(ps, pair{f.name, v})
+	}
+	return ps
+}
+
+func (m *menu) find(ref string) *item {
+	for _, it := range m.items {
+		if it.idString() == ref {
+			return it
+		}
+	}
+	if _, ok := m.field("name"); ok {
+		for _, it := range m.items {
+			if it.props["name"] == ref {
+				return it
+			}
+		}
+	}
+	return nil
+}
+
+func (m *menu) index(it *item) int {
+	for i, x := range m.items {
+		if x == it {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkProps verifies that all keys of props are known writable fields.
+func (m *menu) checkProps(props map[string]string, internal bool) error {
+	for k := range props {
+		f, ok := m.field(k)
+		if !ok || (f.readOnly && !internal) {
+			return &trapError{message: "unknown parameter " + k}
+		}
+	}
+	return nil
+}
+
+// checkUnique fails if an item other than self has the unique properties of
+// props. pending holds the new properties of items changed along with self.
+func (m *menu) checkUnique(props map[string]string, self *item, pending map[*item]map[string]string) error {
+	if len(m.unique) == 0 {
+		return nil
+	}
+outer:
+	for _, it := range m.items {
+		if it == self {
+			continue
+		}
+		other := it.props
+		if p, ok := pending[it]; ok {
+			other = p
+		}
+		for _, k := range m.unique {
+			if other[k] != props[k] {
+				continue outer
+			}
+		}
+		return &trapError{message: m.duplicate}
+	}
+	return nil
+}
+
+func (m *menu) checkRequired(props map[string]string) error {
+	for _, f := range m.fields {
+		if f.required && props[f.name] == "" {
+			return &trapError{message: "failure: " + f.name + " not specified"}
+		}
+	}
+	return nil
+}
+
+func defaultMenus() []*menu {
+	flags := []field{
+		{name: "invalid", def: "false", readOnly: true},
+		{name: "dynamic", def: "false", readOnly: true},
+		{name: "disabled", def: "false"},
+		{name: "comment"},
+	}
+	return []*menu{
+		{
+			path: "/interface",
+			fields: []field{
+				{name: "name", required: true},
+				{name: "default-name", readOnly: true},
+				{name: "type", def: "ether", readOnly: true},
+				{name: "mtu", def: "1500"},
+				{name: "actual-mtu", def: "1500", readOnly: true},
+				{name: "mac-address", readOnly: true},
+				{name: "rx-byte", def: "0", readOnly: true},
+				{name: "tx-byte", def: "0", readOnly: true},
+				{name: "rx-packet", def: "0", readOnly: true},
+				{name: "tx-packet", def: "0", readOnly: true},
+				{name: "running", def: "true", readOnly: true},
+				{name: "disabled", def: "false"},
+				{name: "comment"},
+			},
+			unique:    []string{"name"},
+			duplicate: "failure: already have interface with such name",
+		},
+		{
+			path: "/ip/address",
+			fields: append([]field{
+				{name: "address", required: true},
+				{name: "network"},
+				{name: "interface", required: true},
+				{name: "actual-interface", readOnly: true},
+			}, flags...),
+			addable:   true,
+			unique:    []string{"address", "interface"},
+			duplicate: "failure: already have such address",
+			validate:  validateAddress,
+		},
+		{
+			path: "/ip/firewall/address-list",
+			fields: []field{
+				{name: "list", required: true},
+				{name: "address", required: true},
+				{name: "timeout"},
+				{name: "creation-time", readOnly: true},
+				{name: "dynamic", def: "false", readOnly: true},
+				{name: "disabled", def: "false"},
+				{name: "comment"},
+			},
+			addable:   true,
+			unique:    []string{"list", "address"},
+			duplicate: "failure: already have such entry",
+		},
+		{
+			path: "/ip/firewall/filter",
+			fields: append([]field{
+				{name: "chain", required: true},
+				{name: "action", def: "accept"},
+				{name: "protocol"},
+				{name: "src-address"},
+				{name: "dst-address"},
+				{name: "src-address-list"},
+				{name: "dst-address-list"},
+				{name: "dst-port"},
+				{name: "in-interface"},
+				{name: "out-interface"},
+				{name: "connection-state"},
+				{name: "log", def: "false"},
+				{name: "bytes", def: "0", readOnly: true},
+				{name: "packets", def: "0", readOnly: true},
+			}, flags...),
+			addable: true,
+			ordered: true,
+		},
+		{
+			path: "/ip/route",
+			fields: []field{
+				{name: "dst-address", required: true},
+				{name: "gateway"},
+				{name: "distance", def: "1"},
+				{name: "routing-table", def: "main"},
+				{name: "dynamic", def: "false", readOnly: true},
+				{name: "disabled", def: "false"},
+				{name: "comment"},
+			},
+			addable: true,
+		},
+		{
+			path: "/ip/dns/static",
+			fields: []field{
+				{name: "name", required: true},
+				{name: "type", def: "A"},
+				{name: "address"},
+				{name: "cname"},
+				{name: "text"},
+				{name: "ttl", def: "1d"},
+				{name: "dynamic", def: "false", readOnly: true},
+				{name: "disabled", def: "false"},
+				{name: "comment"},
+			},
+			addable:   true,
+			unique:    []string{"name", "type", "address", "cname", "text"},
+			duplicate: "failure: entry already exists",
+		},
+		{
+			path:      "/system/identity",
+			fields:    []field{{name: "name", def: "MikroTik"}},
+			singleton: true,
+		},
+		{
+			path: "/system/resource",
+			fields: []field{
+				{name: "uptime", readOnly: true},
+				{name: "version", def: "7.15.3 (stable)", readOnly: true},
+				{name: "build-time", def: "Jul/24/2024 12:00:00", readOnly: true},
+				{name: "free-memory", def: "218103808", readOnly: true},
+				{name: "total-memory", def: "268435456", readOnly: true},
+				{name: "cpu", def: "QEMU Virtual CPU", readOnly: true},
+				{name: "cpu-count", def: "1", readOnly: true},
+				{name: "cpu-frequency", def: "2400", readOnly: true},
+				{name: "cpu-load", def: "0", readOnly: true},
+				{name: "free-hdd-space", def: "83886080", readOnly: true},
+				{name: "total-hdd-space", def: "100663296", readOnly: true},
+				{name: "architecture-name", def: "x86_64", readOnly: true},
+				{name: "board-name", def: "CHR", readOnly: true},
+				{name: "platform", def: "MikroTik", readOnly: true},
+			},
+			singleton: true,
+		},
+		{
+			path: "/system/routerboard",
+			fields: []field{
+				{name: "routerboard", def: "false", readOnly: true},
+				{name: "model", readOnly: true},
+				{name: "serial-number", readOnly: true},
+				{name: "firmware-type", readOnly: true},
+				{name: "current-firmware", readOnly: true},
+			},
+			singleton: true,
+		},
+		{
+			path: "/system/license",
+			fields: []field{
+				{name: "system-id", def: "ZxTq2m3aLpV", readOnly: true},
+				{name: "level", def: "p1", readOnly: true},
+			},
+			singleton: true,
+		},
+		{
+			path: "/system/package",
+			fields: []field{
+				{name: "name", readOnly: true},
+				{name: "version", readOnly: true},
+				{name: "build-time", readOnly: true},
+				{name: "scheduled", readOnly: true},
+				{name: "disabled", def: "false"},
+			},
+		},
+	}
+}
+
+func validateAddress(d *Device, props map[string]string) error {
+	ip, ipnet, err := net.ParseCIDR(props["address"])
+	if err != nil {
+		ip = net.ParseIP(props["address"])
+		if ip == nil {
+			return &trapError{message: "invalid value for argument address"}
+		}
+		bits := 32
+		if ip.To4() == nil {
+			bits = 128
+		}
+		ipnet = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	}
+	ones, _ := ipnet.Mask.Size()
+	props["address"] = fmt.Sprintf("%s/%d", ip, ones)
+	props["network"] = ipnet.IP.String()
+	if d.menus["/interface"].find(props["interface"]) == nil {
+		return &trapError{message: "input does not match any value of interface"}
+	}
+	props["actual-interface"] = props["interface"]
+	return nil
+}
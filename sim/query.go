@@ -0,0 +1,103 @@
+package sim
+
+import (
+	"strconv"
+	"strings"
+)
+
+// match evaluates the query words of a print command against props as
+// described in the RouterOS API documentation: every word pushes a boolean
+// onto a stack, "#" words combine stack entries and the result is the logical
+// AND of all remaining entries.
+func match(query []string, props map[string]string) (bool, error) {
+	var stack []bool
+	pop := func() (bool, error) {
+		if len(stack) == 0 {
+			return false, errInvalidQuery
+		}
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return v, nil
+	}
+	for _, q := range query {
+		switch {
+		case strings.HasPrefix(q, "#"):
+			for _, op := range q[1:] {
+				switch {
+				case op == '!':
+					v, err := pop()
+					if err != nil {
+						return false, err
+					}
+					stack = append(stack, !v)
+				case op == '&' || op == '|':
+					a, err := pop()
+					if err != nil {
+						return false, err
+					}
+					b, err := pop()
+					if err != nil {
+						return false, err
+					}
+					if op == '&' {
+						stack = append(stack, a && b)
+					} else {
+						stack = append(stack, a || b)
+					}
+				case op == '.':
+					if len(stack) == 0 {
+						return false, errInvalidQuery
+					}
+					stack = append(stack, stack[len(stack)-1])
+				case op >= '0' && op <= '9':
+					i := int(op - '0')
+					if i >= len(stack) {
+						return false, errInvalidQuery
+					}
+					stack = append(stack, stack[i])
+				default:
+					return false, errInvalidQuery
+				}
+			}
+		case strings.HasPrefix(q, "-"):
+			stack = append(stack, props[q[1:]] == "")
+		case strings.HasPrefix(q, ">"), strings.HasPrefix(q, "<"):
+			name, value, ok := strings.Cut(q[1:], "=")
+			if !ok {
+				return false, errInvalidQuery
+			}
+			c := compare(props[name], value)
+			stack = append(stack, (q[0] == '>' && c > 0) || (q[0] == '<' && c < 0))
+		default:
+			name, value, ok := strings.Cut(strings.TrimPrefix(q, "="), "=")
+			if !ok {
+				stack = append(stack, props[name] != "")
+				continue
+			}
+			stack = append(stack, props[name] == value)
+		}
+	}
+	for _, v := range stack {
+		if !v {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// compare compares a and b numerically when both are integers and
+// lexically otherwise.
+func compare(a, b string) int {
+	x, errA := strconv.ParseInt(a, 10, 64)
+	y, errB := strconv.ParseInt(b, 10, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,39 @@
+package sim
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	props := map[string]string{"name": "ether1", "mtu": "1500", "type": "ether"}
+	for i, test := range []struct {
+		query []string
+		want  bool
+		err   bool
+	}{
+		{nil, true, false},
+		{[]string{"name=ether1"}, true, false},
+		{[]string{"=name=ether1"}, true, false},
+		{[]string{"name=ether2"}, false, false},
+		{[]string{"name"}, true, false},
+		{[]string{"comment"}, false, false},
+		{[]string{"-comment"}, true, false},
+		{[]string{">mtu=999"}, true, false},
+		{[]string{"<mtu=999"}, false, false},
+		{[]string{"name=ether2", "type=ether", "#|"}, true, false},
+		{[]string{"name=ether2", "type=ether", "#&"}, false, false},
+		{[]string{"name=ether2", "#!"}, true, false},
+		{[]string{"name=ether1", "#.!"}, false, false},
+		{[]string{"name=ether2", "type=ether", "#0&"}, false, false},
+		{[]string{"#!"}, false, true},
+		{[]string{"name", "#x"}, false, true},
+		{[]string{">mtu"}, false, true},
+	} {
+		got, err := match(test.query, props)
+		if (err != nil) != test.err {
+			t.Errorf("#%d: match(%#q) error=%v; want error %v", i, test.query, err, test.err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("#%d: match(%#q)=%v; want %v", i, test.query, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package sim
+
+import (
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/swoga/go-routeros/proto"
+	"github.com/swoga/go-routeros/server"
+)
+
+func (d *Device) server() *server.Server {
+	return &server.Server{
+		Handler: d,
+		Auth:    server.Users(d.Users),
+		Timeout: d.Timeout,
+	}
+}
+
+// Serve accepts connections on l and serves each of them in a new goroutine.
+// It returns when l.Accept fails, e.g. because l has been closed.
+func (d *Device) Serve(l net.Listener) error {
+	return d.server().Serve(l)
+}
+
+// ServeConn serves the API protocol on conn until the client quits or the
+// connection fails. conn is closed on return.
+func (d *Device) ServeConn(conn net.Conn) error {
+	return d.server().ServeConn(conn)
+}
+
+// ServeAPI implements server.Handler, so d can be used with a custom
+// server.Server.
+func (d *Device) ServeAPI(w server.ResponseWriter, cmd *server.Command) {
+	if strings.HasSuffix(cmd.Word, "/listen") {
+		d.listen(w, cmd)
+		return
+	}
+	if path, ok := strings.CutSuffix(cmd.Word, "/print"); ok {
+		_, follow := cmd.Map["follow"]
+		_, followOnly := cmd.Map["follow-only"]
+		if follow || followOnly {
+			d.follow(w, cmd, path, followOnly)
+			return
+		}
+	}
+
+	args := make([]pair, 0, len(cmd.List))
+	for _, p := range cmd.List {
+		args = append(args, pair{p.Key, p.Value})
+	}
+	res, done, err := d.exec(cmd.Word, args, cmd.Query)
+	if err != nil {
+		trap(w, err)
+		return
+	}
+	for _, ps := range res {
+		w.Re(protoPairs(ps)...)
+	}
+	w.Done(protoPairs(done)...)
+}
+
+func trap(w server.ResponseWriter, err error) {
+	t := asTrap(err)
+	category := t.category
+	if category == 0 {
+		category = server.NoCategory
+	}
+	w.Trap(category, t.message)
+	w.Done()
+}
+
+func protoPairs(ps []pair) []proto.Pair {
+	res := make([]proto.Pair, 0, len(ps))
+	for _, p := range ps {
+		res = append(res, proto.Pair{Key: p.key, Value: p.value})
+	}
+	return res
+}
+
+// listen streams the changes of a menu until the command is cancelled.
+func (d *Device) listen(w server.ResponseWriter, cmd *server.Command) {
+	d.mu.Lock()
+	m, err := d.menu(strings.TrimSuffix(cmd.Word, "/listen"))
+	if err != nil {
+		d.mu.Unlock()
+		trap(w, err)
+		return
+	}
+	l := &listener{notify: make(chan struct{}, 1)}
+	if m.listeners == nil {
+		m.listeners = make(map[*listener]struct{})
+	}
+	m.listeners[l] = struct{}{}
+	d.mu.Unlock()
+
+	defer func() {
+		d.mu.Lock()
+		delete(m.listeners, l)
+		d.mu.Unlock()
+	}()
+
+	list := cmd.Map[".proplist"]
+	ctx := cmd.Context()
+	for {
+		select {
+		case <-l.notify:
+			for _, ps := range l.drain() {
+				if ps[len(ps)-1].key != ".dead" {
+					ps = proplist(ps, list)
+				}
+				err := w.Re(protoPairs(ps)...)
+				if err != nil {
+					return
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// follow implements print with =follow= or =follow-only=. Unless followOnly
+// is set, the matching entries are printed first, then each batch of changes
+// until the command is cancelled. The snapshot and every batch with matching
+// changes form a section: its rows carry .section, and a sentence carrying
+// only .section ends it.
+func (d *Device) follow(w server.ResponseWriter, cmd *server.Command, path string, followOnly bool) {
+	d.mu.Lock()
+	m, err := d.menu(path)
+	if err != nil {
+		d.mu.Unlock()
+		trap(w, err)
+		return
+	}
+	var snapshot [][]pair
+	for _, it := range m.items {
+		ps := d.render(m, it)
+		ok, err := match(cmd.Query, toMap(ps))
+		if err != nil {
+			d.mu.Unlock()
+			trap(w, err)
+			return
+		}
+		if ok {
+			snapshot = append(snapshot, ps)
+		}
+	}
+	l := &listener{notify: make(chan struct{}, 1)}
+	if m.listeners == nil {
+		m.listeners = make(map[*listener]struct{})
+	}
+	m.listeners[l] = struct{}{}
+	d.mu.Unlock()
+
+	defer func() {
+		d.mu.Lock()
+		delete(m.listeners, l)
+		d.mu.Unlock()
+	}()
+
+	list := cmd.Map[".proplist"]
+	section := 0
+	emit := func(rows [][]pair, always bool) error {
+		s := pair{".section", strconv.Itoa(section)}
+		n := 0
+		for _, ps := range rows {
+			if ps[len(ps)-1].key != ".dead" {
+				ok, _ := match(cmd.Query, toMap(ps))
+				if !ok {
+					continue
+				}
+				ps = proplist(ps, list)
+			}
+			err := w.Re(protoPairs(append(slices.Clip(ps), s))...)
+			if err != nil {
+				return err
+			}
+			n++
+		}
+		if n == 0 && !always {
+			return nil
+		}
+		section++
+		return w.Re(protoPairs([]pair{s})...)
+	}
+
+	if !followOnly && emit(snapshot, true) != nil {
+		return
+	}
+	ctx := cmd.Context()
+	for {
+		select {
+		case <-l.notify:
+			if emit(l.drain(), false) != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// listener queues the changes of a menu for one listen command. Changes are
+// queued without blocking so a slow client never stalls the Device.
+type listener struct {
+	notify chan struct{}
+
+	mu    sync.Mutex
+	queue [][]pair
+}
+
+func (l *listener) push(ps []pair) {
+	l.mu.Lock()
+	l.queue = append(l.queue, ps)
+	l.mu.Unlock()
+	select {
+	case l.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (l *listener) drain() [][]pair {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	q := l.queue
+	l.queue = nil
+	return q
+}
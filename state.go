@@ -0,0 +1,70 @@
+package routeros
+
+// State is the connection state of a Client.
+type State int32
+
+const (
+	// StateConnected is entered when the connection has been established.
+	StateConnected State = iota + 1
+	// StateLoggedIn is entered after a successful Login.
+	StateLoggedIn
+	// StateAsync is entered when asynchronous mode starts, explicitly by
+	// Async or implicitly by Listen or Keepalive.
+	StateAsync
+	// StateClosed is entered when the client has ended, see Err.
+	StateClosed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateLoggedIn:
+		return "logged in"
+	case StateAsync:
+		return "async"
+	case StateClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// WithStateChange registers f with Client.OnStateChange before connecting,
+// so f also sees StateConnected and StateLoggedIn.
+func WithStateChange(f func(State)) Option {
+	return func(cc *connectConfig) {
+		cc.stateFuncs = append(cc.stateFuncs, f)
+	}
+}
+
+// OnStateChange registers f to be called with the new state on every state
+// change. f is called synchronously by the goroutine causing the change and
+// must not block.
+func (c *Client) OnStateChange(f func(State)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stateFuncs = append(c.stateFuncs, f)
+}
+
+// State returns the current state.
+func (c *Client) State() State {
+	return State(c.state.Load())
+}
+
+// Wait blocks until the client has ended and returns the reason, see Err.
+// Errors of the asynchronous read loop are reported here regardless of
+// whether asynchronous mode was started by Async or implicitly.
+func (c *Client) Wait() error {
+	<-c.done
+	return c.endErr
+}
+
+func (c *Client) setState(s State) {
+	c.state.Store(int32(s))
+	c.mu.Lock()
+	funcs := c.stateFuncs
+	c.mu.Unlock()
+	for _, f := range funcs {
+		f(s)
+	}
+}
@@ -0,0 +1,80 @@
+package routeros_test
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+)
+
+func TestStateChange(t *testing.T) {
+	_, addr := startSim(t)
+	var (
+		mu     sync.Mutex
+		states []routeros.State
+	)
+	c, err := routeros.Connect(context.Background(), addr,
+		routeros.WithCredentials("admin", ""),
+		routeros.WithAsync(),
+		routeros.WithStateChange(func(s routeros.State) {
+			mu.Lock()
+			states = append(states, s)
+			mu.Unlock()
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.State() != routeros.StateAsync {
+		t.Fatalf("State()=%s; want async", c.State())
+	}
+	c.Close()
+	if err := c.Wait(); err != routeros.ErrClosed {
+		t.Fatalf("Wait()=%v; want ErrClosed", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []routeros.State{routeros.StateConnected, routeros.StateLoggedIn, routeros.StateAsync, routeros.StateClosed}
+	if !slices.Equal(states, want) {
+		t.Fatalf("states=%v; want %v", states, want)
+	}
+}
+
+func TestWaitImplicitAsync(t *testing.T) {
+	_, l := startDropSim(t)
+	c, err := routeros.Connect(context.Background(), l.Addr().String(), routeros.WithCredentials("admin", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	closed := make(chan struct{})
+	c.OnStateChange(func(s routeros.State) {
+		if s == routeros.StateClosed {
+			close(closed)
+		}
+	})
+
+	// Listen starts asynchronous mode without handing out its error channel
+	_, err = c.Listen("/interface/listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.drop()
+
+	errC := make(chan error)
+	go func() { errC <- c.Wait() }()
+	select {
+	case err = <-errC:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait did not return after the connection broke")
+	}
+	if err == nil || err == routeros.ErrClosed || err != c.Err() {
+		t.Fatalf("Wait()=%v, Err()=%v; want the read error", err, c.Err())
+	}
+	<-closed
+}
@@ -0,0 +1,147 @@
+package routeros
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/swoga/go-routeros/proto"
+)
+
+// WithTagPrefix sets Client.TagPrefix, e.g. to tell the commands of several
+// clients apart in the logs of a device.
+func WithTagPrefix(prefix string) Option {
+	return func(cc *connectConfig) {
+		cc.tagPrefix = prefix
+	}
+}
+
+// WithUnknownTag registers f with Client.OnUnknownTag before connecting.
+func WithUnknownTag(f func(*proto.Sentence)) Option {
+	return func(cc *connectConfig) {
+		cc.unknownTagFuncs = append(cc.unknownTagFuncs, f)
+	}
+}
+
+// OnUnknownTag registers f to be called with each sentence the asynchronous
+// read loop cannot assign to a command, e.g. a reply arriving after its
+// command timed out or an untagged !fatal. f is called by the read loop and
+// must not block.
+func (c *Client) OnUnknownTag(f func(*proto.Sentence)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unknownTagFuncs = append(c.unknownTagFuncs, f)
+}
+
+// InFlightCommand describes an asynchronous command awaiting its reply.
+type InFlightCommand struct {
+	Tag     string
+	Command string
+	Listen  bool
+	Started time.Time
+	// Sentences is the number of reply sentences received so far.
+	Sentences int
+	// Queued is the number of sentences waiting in the channel of a
+	// listen command.
+	Queued int
+}
+
+// InFlight returns the asynchronous commands awaiting their reply, oldest
+// first.
+func (c *Client) InFlight() []InFlightCommand {
+	c.mu.Lock()
+	res := make([]InFlightCommand, 0, len(c.tags))
+	for _, r := range c.tags {
+		b := r.base()
+		_, listen := r.(*ListenReply)
+		res = append(res, InFlightCommand{
+			Tag:       b.tag,
+			Command:   b.command,
+			Listen:    listen,
+			Started:   b.started,
+			Sentences: int(b.sentences.Load()),
+			Queued:    len(b.reC),
+		})
+	}
+	c.mu.Unlock()
+	slices.SortFunc(res, func(a, b InFlightCommand) int {
+		return a.Started.Compare(b.Started)
+	})
+	return res
+}
+
+// splitTag removes a .tag word from sentence and returns its value. The tag
+// of a command can be supplied that way in asynchronous mode.
+func splitTag(sentence []string) ([]string, string) {
+	for i, word := range sentence {
+		if tag, ok := strings.CutPrefix(word, ".tag="); ok {
+			return slices.Delete(slices.Clone(sentence), i, i+1), tag
+		}
+	}
+	return sentence, ""
+}
+
+// register adds r to the commands in flight, with tag or a generated tag of
+// kind "r" or "l" if tag is empty. A tag is in use until the device's !done
+// for it arrives, even after a !trap ended its command. Commands are registered before they are
+// sent, so their replies cannot arrive first.
+func (c *Client) register(r sentenceProcessor, kind, tag string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tags == nil {
+		return errAsyncLoopEnded
+	}
+	if tag == "" {
+		tag = c.newTag(kind)
+	} else if c.tagInUse(tag) {
+		return errTagInUse(tag)
+	}
+	b := r.base()
+	b.tag = tag
+	b.started = time.Now()
+	c.tags[tag] = r
+	return nil
+}
+
+// unregister removes the command with tag after sending it failed. It
+// reports false if the command has already been ended by the read loop.
+func (c *Client) unregister(tag string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.tags[tag]
+	delete(c.tags, tag)
+	return ok
+}
+
+func errTagInUse(tag string) error {
+	return fmt.Errorf("RouterOS: tag %q is in use", tag)
+}
+
+// newTag returns an unused tag for a command of kind "r" or "l". It must be
+// called with c.mu held.
+func (c *Client) newTag(kind string) string {
+	for {
+		tag := c.TagPrefix + kind + strconv.FormatUint(c.nextTag(), 10)
+		if !c.tagInUse(tag) {
+			return tag
+		}
+	}
+}
+
+// tagInUse reports whether tag belongs to a command in flight or to a
+// trapped command whose !done is still to come. It must be called with c.mu
+// held.
+func (c *Client) tagInUse(tag string) bool {
+	_, ok := c.tags[tag]
+	_, trailing := c.trailing[tag]
+	return ok || trailing
+}
+
+func command(sentence []string) string {
+	if len(sentence) == 0 {
+		return ""
+	}
+	return sentence[0]
+}
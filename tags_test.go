@@ -0,0 +1,117 @@
+package routeros_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/proto"
+)
+
+func TestTags(t *testing.T) {
+	c, s := newPair(t)
+	defer c.Close()
+	c.TagPrefix = "app-"
+
+	var (
+		mu      sync.Mutex
+		unknown []string
+	)
+	c.OnUnknownTag(func(sen *proto.Sentence) {
+		mu.Lock()
+		unknown = append(unknown, sen.String())
+		mu.Unlock()
+	})
+	c.Async()
+
+	inFlight := make(chan []routeros.InFlightCommand, 1)
+	go func() {
+		defer s.Close()
+		s.readSentence(t, "/ip/address/listen @mine []")
+		s.writeSentence(t, "!re", ".tag=mine", "=address=1.2.3.4/32")
+		s.readSentence(t, "/system/identity/print @app-r1 []")
+		inFlight <- c.InFlight()
+		s.writeSentence(t, "!re", ".tag=stale", "=name=x")
+		s.writeSentence(t, "!trap", ".tag=app-r1", "=message=failure")
+		s.writeSentence(t, "!done", ".tag=app-r1")
+		s.readSentence(t, "/system/resource/print @app-r2 []")
+		s.writeSentence(t, "!done", ".tag=app-r2")
+	}()
+
+	listen, err := c.Listen("/ip/address/listen", ".tag=mine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-listen.Chan()
+	_, err = c.Listen("/ip/address/listen", ".tag=mine")
+	if err == nil {
+		t.Fatal("duplicate tag accepted")
+	}
+
+	_, err = c.Run("/system/identity/print")
+	if err == nil {
+		t.Fatal("trap not reported")
+	}
+	_, err = c.Run("/system/resource/print")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmds := <-inFlight
+	if len(cmds) != 2 {
+		t.Fatalf("InFlight()=%+v; want two commands", cmds)
+	}
+	if cmds[0].Tag != "mine" || cmds[0].Command != "/ip/address/listen" || cmds[0].Sentences != 1 {
+		t.Errorf("InFlight()[0]=%+v; want the listen with one sentence", cmds[0])
+	}
+	if cmds[1].Tag != "app-r1" || cmds[1].Command != "/system/identity/print" || cmds[1].Started.Before(cmds[0].Started) {
+		t.Errorf("InFlight()[1]=%+v; want the print", cmds[1])
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := "!re @stale [{`name` `x`}]"
+	if len(unknown) != 1 || unknown[0] != want {
+		t.Fatalf("unknown tags=%q; want [%s]", unknown, want)
+	}
+}
+
+func TestTagReuseAfterTrap(t *testing.T) {
+	c, s := newPair(t)
+	defer c.Close()
+	unknown := make(chan struct{}, 1)
+	c.OnUnknownTag(func(*proto.Sentence) { unknown <- struct{}{} })
+	c.Async()
+
+	doneSent := make(chan struct{})
+	go func() {
+		defer s.Close()
+		s.readSentence(t, "/system/identity/print @mine []")
+		s.writeSentence(t, "!trap", ".tag=mine", "=message=failure")
+		<-doneSent
+		s.writeSentence(t, "!done", ".tag=mine")
+		s.writeSentence(t, "!re", ".tag=stale")
+		s.readSentence(t, "/system/resource/print @mine []")
+		s.writeSentence(t, "!re", ".tag=mine", "=uptime=1s")
+		s.writeSentence(t, "!done", ".tag=mine")
+	}()
+
+	_, err := c.Run("/system/identity/print", ".tag=mine")
+	if err == nil {
+		t.Fatal("trap not reported")
+	}
+	_, err = c.Run("/system/resource/print", ".tag=mine")
+	if err == nil || err.Error() != `RouterOS: tag "mine" is in use` {
+		t.Fatalf("Run()=%v; want tag in use until its !done", err)
+	}
+	close(doneSent)
+	<-unknown
+
+	r, err := c.Run("/system/resource/print", ".tag=mine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Re) != 1 || r.Re[0].Map["uptime"] != "1s" {
+		t.Fatalf("Run()=%v; want the reply of the new command", r)
+	}
+}
@@ -0,0 +1,92 @@
+package routeros
+
+import (
+	"context"
+	"iter"
+
+	"github.com/swoga/go-routeros/proto"
+)
+
+// Event is a change reported by ListenTyped. For deletions Deleted is set,
+// ID holds the .id of the removed entry and Item is the zero value.
+type Event[T any] struct {
+	ID      string
+	Item    T
+	Deleted bool
+}
+
+// PrintTyped runs a print command on path and decodes each !re sentence into
+// a T, see Decode.
+func PrintTyped[T any](ctx context.Context, r Runner, path string, args ...string) ([]T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	reply, err := r.Print(path, args...)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]T, len(reply.Re))
+	for i, sen := range reply.Re {
+		err = Decode(sen, &items[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// ListenTyped starts a listen command and returns an iterator over its
+// events, decoding each !re sentence into a T, see Decode. Entries removed on
+// the device (.dead=yes) are reported as Deleted events.
+//
+// Sentences that fail to decode yield their error and iteration continues.
+// When ctx is done, the command is cancelled and ctx.Err() is yielded. As
+// with ListenReply.All, breaking out of the loop cancels the command.
+func ListenTyped[T any](ctx context.Context, c *Client, sentence ...string) (iter.Seq2[Event[T], error], error) {
+	l, err := c.ListenArgsQueue(sentence, c.Queue)
+	if err != nil {
+		return nil, err
+	}
+	all := allContext(ctx, l)
+	return func(yield func(Event[T], error) bool) {
+		for sen, err := range all {
+			if err != nil {
+				yield(Event[T]{}, err)
+				return
+			}
+			ev, err := decodeEvent[T](sen)
+			if !yield(ev, err) {
+				return
+			}
+		}
+	}, nil
+}
+
+// allContext is like l.All, but cancels l when ctx is done and yields
+// ctx.Err() at the end in that case.
+func allContext(ctx context.Context, l *ListenReply) iter.Seq2[*proto.Sentence, error] {
+	stop := context.AfterFunc(ctx, func() {
+		l.Cancel()
+	})
+	return func(yield func(*proto.Sentence, error) bool) {
+		defer stop()
+		for sen, err := range l.All() {
+			if !yield(sen, err) || err != nil {
+				return
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+func decodeEvent[T any](sen *proto.Sentence) (Event[T], error) {
+	ev := Event[T]{ID: sen.Map[".id"]}
+	if sen.Map[".dead"] == "yes" {
+		ev.Deleted = true
+		return ev, nil
+	}
+	err := Decode(sen, &ev.Item)
+	return ev, err
+}
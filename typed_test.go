@@ -0,0 +1,151 @@
+package routeros_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swoga/go-routeros"
+	"github.com/swoga/go-routeros/proto"
+)
+
+type iface struct {
+	ID         string `routeros:".id"`
+	Name       string
+	MTU        int
+	MACAddress string
+	Disabled   bool
+	RxByte     uint64
+	Comment    string `routeros:"-"`
+}
+
+type address struct {
+	ID        string `routeros:".id"`
+	Address   string
+	Interface string
+}
+
+func TestDecode(t *testing.T) {
+	type entry struct {
+		Name     string        `routeros:"name"`
+		Count    int8          `routeros:"count"`
+		Rate     float64       `routeros:"rate"`
+		Uptime   time.Duration `routeros:"uptime"`
+		Lists    []string      `routeros:"lists"`
+		Running  *bool         `routeros:"running"`
+		Disabled bool          `routeros:"disabled"`
+	}
+	for _, test := range []struct {
+		words []string
+		want  entry
+		err   bool
+	}{
+		{[]string{"=name=a", "=count=3", "=rate=1.5", "=unknown=x"}, entry{Name: "a", Count: 3, Rate: 1.5}, false},
+		{[]string{"=uptime=1d2h", "=lists=a,b", "=disabled=yes"}, entry{Uptime: 26 * time.Hour, Lists: []string{"a", "b"}, Disabled: true}, false},
+		{[]string{"=count=300"}, entry{}, true},
+		{[]string{"=disabled=maybe"}, entry{}, true},
+		{[]string{"=uptime=soon"}, entry{}, true},
+	} {
+		sen := &proto.Sentence{Word: "!re", Map: map[string]string{}}
+		for _, w := range test.words {
+			k, v, _ := strings.Cut(w[1:], "=")
+			sen.Map[k] = v
+		}
+		var e entry
+		err := routeros.Decode(sen, &e)
+		if test.err {
+			if err == nil {
+				t.Errorf("Decode(%v) succeeded", test.words)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Decode(%v): %v", test.words, err)
+			continue
+		}
+		if e.Name != test.want.Name || e.Count != test.want.Count || e.Rate != test.want.Rate ||
+			e.Uptime != test.want.Uptime || !slices.Equal(e.Lists, test.want.Lists) || e.Disabled != test.want.Disabled {
+			t.Errorf("Decode(%v)=%+v; want %+v", test.words, e, test.want)
+		}
+	}
+
+	var running struct {
+		Running *bool
+	}
+	err := routeros.Decode(&proto.Sentence{Map: map[string]string{"running": "true"}}, &running)
+	if err != nil || running.Running == nil || !*running.Running {
+		t.Fatalf("Decode pointer: %v", err)
+	}
+	if routeros.Decode(&proto.Sentence{}, running) == nil {
+		t.Fatal("Decode into non-pointer succeeded")
+	}
+}
+
+func TestPrintTyped(t *testing.T) {
+	d, addr := startSim(t)
+	d.Set("/interface", "ether2", map[string]string{"disabled": "true", "comment": "uplink"})
+	c, err := routeros.Connect(context.Background(), addr, routeros.WithCredentials("admin", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ifaces, err := routeros.PrintTyped[iface](context.Background(), c, "/interface", "?disabled=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []iface{{ID: "*2", Name: "ether2", MTU: 1500, MACAddress: "02:00:00:00:00:02", Disabled: true}}
+	if !slices.Equal(ifaces, want) {
+		t.Fatalf("PrintTyped=%+v; want %+v", ifaces, want)
+	}
+}
+
+func TestListenTyped(t *testing.T) {
+	d, addr := startSim(t)
+	c, err := routeros.Connect(context.Background(), addr, routeros.WithCredentials("admin", ""), routeros.WithAsync())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := routeros.ListenTyped[address](ctx, c, "/ip/address/listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for d.Listeners("/ip/address") == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	id, err := d.Add("/ip/address", map[string]string{"address": "192.0.2.1/24", "interface": "ether1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Remove("/ip/address", id)
+
+	var got []routeros.Event[address]
+	for ev, err := range events {
+		if err != nil {
+			if err != context.Canceled {
+				t.Fatal(err)
+			}
+			break
+		}
+		got = append(got, ev)
+		if ev.Deleted {
+			cancel()
+		}
+	}
+	want := []routeros.Event[address]{
+		{ID: id, Item: address{ID: id, Address: "192.0.2.1/24", Interface: "ether1"}},
+		{ID: id, Deleted: true},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("events=%+v; want %+v", got, want)
+	}
+	for d.Listeners("/ip/address") != 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+}